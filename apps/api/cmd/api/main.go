@@ -6,6 +6,7 @@ import (
 
 	"jellystreaming/internal/config"
 	"jellystreaming/internal/database"
+	"jellystreaming/internal/middleware"
 	"jellystreaming/internal/routes"
 )
 
@@ -19,6 +20,10 @@ func main() {
 	}
 	defer database.Close()
 
+	if err := middleware.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Setup routes
 	routes.Setup(cfg)
 
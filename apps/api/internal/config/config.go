@@ -1,6 +1,11 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Config holds all application configuration
 type Config struct {
@@ -17,6 +22,84 @@ type Config struct {
 	SonarrAPIKey    string
 	MongoDBURI      string
 	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	MFAIssuer       string
+	StreamTokenTTL  time.Duration
+
+	// Password policy applied wherever a password is set
+	PasswordMinLength        int
+	PasswordRequireUppercase bool
+	PasswordRequireLowercase bool
+	PasswordRequireDigit     bool
+	PasswordRequireSymbol    bool
+	PasswordBlockCommon      bool
+
+	// Quality profiles that count as 4K and require the request.4k permission
+	Radarr4KQualityProfileIDs []int
+	Sonarr4KQualityProfileIDs []int
+
+	// Login throttling: failures beyond the free attempts lock the username or
+	// IP for LoginBackoffBase, doubling per failure up to LoginLockoutMax
+	LoginMaxAttempts     int
+	LoginIPMaxAttempts   int
+	LoginBackoffBase     time.Duration
+	LoginLockoutMax      time.Duration
+	LoginFailureWindow   time.Duration
+	FailedLoginRetention time.Duration
+
+	// How long audit events are kept; zero keeps them forever
+	AuditRetention time.Duration
+
+	// Default request quota per rolling QuotaDays; zero limits are unlimited
+	QuotaMovieLimit  int
+	QuotaSeasonLimit int
+	QuotaDays        int
+
+	// How long the cached Jellyfin/Radarr/Sonarr library index used to
+	// annotate TMDB lists is reused before it is rebuilt
+	LibraryIndexTTL time.Duration
+
+	// Outgoing email for notifications (disabled when SMTPHost is empty).
+	// SMTPSecurity is starttls, tls (implicit TLS) or none.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPSecurity string
+
+	// Public URL of the web app, used for links in notifications
+	AppURL string
+	// Directory of <event>.tmpl files overriding the built-in notification templates
+	NotificationTemplatesDir string
+
+	// Shared secret Radarr and Sonarr send with their webhooks (disabled when empty)
+	WebhookSecret string
+	// Token the Jellyfin Webhook plugin sends with its events (disabled when empty)
+	JellyfinWebhookToken string
+
+	// Reverse proxies whose X-Forwarded-For header is trusted (IPs or CIDRs)
+	TrustedProxies []string
+
+	// Authenticate users against Jellyfin's AuthenticateByName
+	JellyfinAuthEnabled bool
+	// Link a first Jellyfin login to an existing account of the same name
+	// that has no local password
+	JellyfinLinkByUsername bool
+
+	// OpenID Connect single sign-on (disabled when OIDCIssuerURL is empty)
+	OIDCIssuerURL         string
+	OIDCClientID          string
+	OIDCClientSecret      string
+	OIDCRedirectURL       string
+	OIDCScopes            []string
+	OIDCUsernameClaim     string
+	OIDCGroupsClaim       string
+	OIDCAdminGroup        string
+	OIDCAutoCreate        bool
+	OIDCLinkByUsername    bool
+	OIDCPostLoginRedirect string
 }
 
 // Load loads configuration from environment variables
@@ -35,6 +118,67 @@ func Load() *Config {
 		SonarrAPIKey:    getEnv("SONARR_API_KEY", ""),
 		MongoDBURI:      getEnv("MONGODB_URI", ""),
 		JWTSecret:       getEnv("JWT_SECRET", ""),
+		AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		MFAIssuer:       getEnv("MFA_ISSUER", "JellyStreaming"),
+		StreamTokenTTL:  getEnvDuration("STREAM_TOKEN_TTL", 6*time.Hour),
+
+		PasswordMinLength:        getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordRequireUppercase: getEnvBool("PASSWORD_REQUIRE_UPPERCASE", false),
+		PasswordRequireLowercase: getEnvBool("PASSWORD_REQUIRE_LOWERCASE", false),
+		PasswordRequireDigit:     getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+		PasswordRequireSymbol:    getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		PasswordBlockCommon:      getEnvBool("PASSWORD_BLOCK_COMMON", true),
+
+		Radarr4KQualityProfileIDs: getEnvInts("RADARR_4K_QUALITY_PROFILE_IDS"),
+		Sonarr4KQualityProfileIDs: getEnvInts("SONARR_4K_QUALITY_PROFILE_IDS"),
+
+		LoginMaxAttempts:     getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginIPMaxAttempts:   getEnvInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+		LoginBackoffBase:     getEnvDuration("LOGIN_BACKOFF_BASE", 30*time.Second),
+		LoginLockoutMax:      getEnvDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+		LoginFailureWindow:   getEnvDuration("LOGIN_FAILURE_WINDOW", 24*time.Hour),
+		FailedLoginRetention: getEnvDuration("FAILED_LOGIN_RETENTION", 90*24*time.Hour),
+
+		AuditRetention: getEnvDuration("AUDIT_RETENTION", 0),
+
+		QuotaMovieLimit:  getEnvInt("QUOTA_MOVIE_LIMIT", 0),
+		QuotaSeasonLimit: getEnvInt("QUOTA_SEASON_LIMIT", 0),
+		QuotaDays:        getEnvInt("QUOTA_DAYS", 7),
+
+		LibraryIndexTTL: getEnvDuration("LIBRARY_INDEX_TTL", 5*time.Minute),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+		SMTPSecurity: getEnv("SMTP_SECURITY", "starttls"),
+
+		AppURL:                   strings.TrimRight(getEnv("APP_URL", ""), "/"),
+		NotificationTemplatesDir: getEnv("NOTIFICATION_TEMPLATES_DIR", ""),
+
+		WebhookSecret:        getEnv("WEBHOOK_SECRET", ""),
+		JellyfinWebhookToken: getEnv("JELLYFIN_WEBHOOK_TOKEN", ""),
+
+		TrustedProxies: strings.FieldsFunc(getEnv("TRUSTED_PROXIES", ""), func(r rune) bool {
+			return r == ',' || r == ' '
+		}),
+
+		JellyfinAuthEnabled:    getEnvBool("JELLYFIN_AUTH_ENABLED", false),
+		JellyfinLinkByUsername: getEnvBool("JELLYFIN_LINK_BY_USERNAME", false),
+
+		OIDCIssuerURL:         getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:          getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:      getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:       getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:            strings.Fields(getEnv("OIDC_SCOPES", "openid profile email groups")),
+		OIDCUsernameClaim:     getEnv("OIDC_USERNAME_CLAIM", "preferred_username"),
+		OIDCGroupsClaim:       getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCAdminGroup:        getEnv("OIDC_ADMIN_GROUP", ""),
+		OIDCAutoCreate:        getEnvBool("OIDC_AUTO_CREATE", true),
+		OIDCLinkByUsername:    getEnvBool("OIDC_LINK_BY_USERNAME", false),
+		OIDCPostLoginRedirect: getEnv("OIDC_POST_LOGIN_REDIRECT", "/login"),
 	}
 }
 
@@ -45,3 +189,45 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvBool retrieves a boolean environment variable or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration retrieves a duration (e.g. "15m", "720h") from an environment
+// variable or returns a default value when unset or invalid
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvInt retrieves an integer environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// getEnvInts retrieves a comma-separated list of integers, skipping invalid entries
+func getEnvInts(key string) []int {
+	var values []int
+	for _, field := range strings.Split(os.Getenv(key), ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(field)); err == nil {
+			values = append(values, n)
+		}
+	}
+	return values
+}
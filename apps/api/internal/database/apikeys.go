@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/models"
+)
+
+// ErrAPIKeyInvalid is returned for unknown, expired or revoked API keys
+var ErrAPIKeyInvalid = errors.New("API key invalid, expired or revoked")
+
+// apiKeyTouchInterval limits how often last-used details are written
+const apiKeyTouchInterval = time.Minute
+
+// HashAPIKey returns the stored form of an API key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// activeAPIKeyFilter matches keys that are neither revoked nor expired
+func activeAPIKeyFilter() bson.M {
+	return bson.M{
+		"revokedAt": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"expiresAt": bson.M{"$exists": false}},
+			bson.M{"expiresAt": bson.M{"$gt": time.Now()}},
+		},
+	}
+}
+
+// AuthenticateAPIKey resolves an active API key and its owner, and records
+// when and from where the key was last used
+func AuthenticateAPIKey(ctx context.Context, key, ip string) (*models.APIKey, *models.User, error) {
+	filter := activeAPIKeyFilter()
+	filter["keyHash"] = HashAPIKey(key)
+
+	var apiKey models.APIKey
+	err := APIKeysCollection.FindOne(ctx, filter).Decode(&apiKey)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var user models.User
+	err = UsersCollection.FindOne(ctx, bson.M{"_id": apiKey.UserID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval || apiKey.LastUsedIP != ip {
+		// Best effort: a failed write must not reject the request
+		APIKeysCollection.UpdateOne(ctx, bson.M{"_id": apiKey.ID}, bson.M{
+			"$set": bson.M{"lastUsedAt": now, "lastUsedIp": ip},
+		})
+	}
+
+	return &apiKey, &user, nil
+}
+
+// CheckAPIKey verifies that an API key is still active
+func CheckAPIKey(ctx context.Context, keyID string) error {
+	objectID, err := primitive.ObjectIDFromHex(keyID)
+	if err != nil {
+		return ErrAPIKeyInvalid
+	}
+
+	filter := activeAPIKeyFilter()
+	filter["_id"] = objectID
+
+	count, err := APIKeysCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrAPIKeyInvalid
+	}
+
+	return nil
+}
+
+// ListUserAPIKeys returns the active API keys of a user, newest first
+func ListUserAPIKeys(ctx context.Context, userID primitive.ObjectID) ([]models.APIKey, error) {
+	filter := activeAPIKeyFilter()
+	filter["userId"] = userID
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := APIKeysCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RevokeUserAPIKeys revokes every API key of a user, or a single one when
+// keyID is set, and returns how many keys were revoked
+func RevokeUserAPIKeys(ctx context.Context, userID primitive.ObjectID, keyID *primitive.ObjectID) (int64, error) {
+	filter := bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}}
+	if keyID != nil {
+		filter["_id"] = *keyID
+	}
+
+	result, err := APIKeysCollection.UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{"revokedAt": time.Now()},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,20 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"jellystreaming/internal/models"
+)
+
+// RecordAudit stores an audit event; with a retention of zero it is kept forever
+func RecordAudit(ctx context.Context, event models.AuditEvent, retention time.Duration) error {
+	event.CreatedAt = time.Now()
+	if retention > 0 {
+		expiresAt := event.CreatedAt.Add(retention)
+		event.ExpiresAt = &expiresAt
+	}
+
+	_, err := AuditCollection.InsertOne(ctx, event)
+	return err
+}
@@ -15,9 +15,24 @@ import (
 )
 
 var (
-	client          *mongo.Client
-	UsersCollection *mongo.Collection
-	JWTSecret       []byte
+	client                  *mongo.Client
+	UsersCollection         *mongo.Collection
+	SessionsCollection      *mongo.Collection
+	OIDCStatesCollection    *mongo.Collection
+	RolesCollection         *mongo.Collection
+	InvitesCollection       *mongo.Collection
+	APIKeysCollection       *mongo.Collection
+	AuditCollection         *mongo.Collection
+	RequestsCollection      *mongo.Collection
+	NotificationsCollection *mongo.Collection
+	SettingsCollection      *mongo.Collection
+	PlaybackCollection      *mongo.Collection
+	WatchlistCollection     *mongo.Collection
+
+	LoginThrottlesCollection *mongo.Collection
+	FailedLoginsCollection   *mongo.Collection
+
+	JWTSecret []byte
 )
 
 // Init initializes MongoDB connection
@@ -47,7 +62,21 @@ func Init(mongoURI, jwtSecret string) error {
 		return err
 	}
 
-	UsersCollection = client.Database("jellystreaming").Collection("users")
+	db := client.Database("jellystreaming")
+	UsersCollection = db.Collection("users")
+	SessionsCollection = db.Collection("sessions")
+	OIDCStatesCollection = db.Collection("oidc_states")
+	RolesCollection = db.Collection("roles")
+	InvitesCollection = db.Collection("invites")
+	APIKeysCollection = db.Collection("api_keys")
+	AuditCollection = db.Collection("audit")
+	RequestsCollection = db.Collection("requests")
+	NotificationsCollection = db.Collection("notifications")
+	SettingsCollection = db.Collection("settings")
+	PlaybackCollection = db.Collection("playback_progress")
+	WatchlistCollection = db.Collection("watchlist")
+	LoginThrottlesCollection = db.Collection("login_throttles")
+	FailedLoginsCollection = db.Collection("failed_logins")
 
 	// Create unique index on username
 	indexModel := mongo.IndexModel{
@@ -59,6 +88,178 @@ func Init(mongoURI, jwtSecret string) error {
 		log.Printf("Warning: Could not create unique index on username: %v", err)
 	}
 
+	// Sessions are looked up by refresh token hash and by user, and are
+	// removed by MongoDB once they expire
+	sessionIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "refreshTokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "previousTokenHash", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	if _, err := SessionsCollection.Indexes().CreateMany(ctx, sessionIndexes); err != nil {
+		log.Printf("Warning: Could not create session indexes: %v", err)
+	}
+
+	// Users provisioned through single sign-on are identified by issuer + subject
+	oidcUserIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "oidcIssuer", Value: 1}, {Key: "oidcSubject", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"oidcSubject": bson.M{"$exists": true},
+		}),
+	}
+	if _, err := UsersCollection.Indexes().CreateOne(ctx, oidcUserIndex); err != nil {
+		log.Printf("Warning: Could not create OIDC subject index: %v", err)
+	}
+
+	// At most one account may be linked to a given Jellyfin user
+	jellyfinUserIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "jellyfinUserId", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"jellyfinUserId": bson.M{"$exists": true},
+		}),
+	}
+	if _, err := UsersCollection.Indexes().CreateOne(ctx, jellyfinUserIndex); err != nil {
+		log.Printf("Warning: Could not create Jellyfin user index: %v", err)
+	}
+
+	oidcStateIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "state", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	if _, err := OIDCStatesCollection.Indexes().CreateMany(ctx, oidcStateIndexes); err != nil {
+		log.Printf("Warning: Could not create OIDC state indexes: %v", err)
+	}
+
+	roleIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := RolesCollection.Indexes().CreateOne(ctx, roleIndex); err != nil {
+		log.Printf("Warning: Could not create unique index on role name: %v", err)
+	}
+	if err := ensureBuiltInRoles(ctx); err != nil {
+		log.Printf("Warning: Could not create built-in roles: %v", err)
+	}
+
+	inviteIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "code", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+	}
+	if _, err := InvitesCollection.Indexes().CreateMany(ctx, inviteIndexes); err != nil {
+		log.Printf("Warning: Could not create invite indexes: %v", err)
+	}
+
+	apiKeyIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "keyHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	}
+	if _, err := APIKeysCollection.Indexes().CreateMany(ctx, apiKeyIndexes); err != nil {
+		log.Printf("Warning: Could not create API key indexes: %v", err)
+	}
+
+	// Failure counters and failed login records are removed once they expire
+	throttleIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	if _, err := LoginThrottlesCollection.Indexes().CreateMany(ctx, throttleIndexes); err != nil {
+		log.Printf("Warning: Could not create login throttle indexes: %v", err)
+	}
+
+	failedLoginIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "username", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "ip", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	if _, err := FailedLoginsCollection.Indexes().CreateMany(ctx, failedLoginIndexes); err != nil {
+		log.Printf("Warning: Could not create failed login indexes: %v", err)
+	}
+
+	auditIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "actor.username", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "target.type", Value: 1}, {Key: "target.id", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	if _, err := AuditCollection.Indexes().CreateMany(ctx, auditIndexes); err != nil {
+		log.Printf("Warning: Could not create audit indexes: %v", err)
+	}
+
+	requestIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "requestedBy", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "mediaType", Value: 1}, {Key: "tmdbId", Value: 1}}},
+	}
+	if _, err := RequestsCollection.Indexes().CreateMany(ctx, requestIndexes); err != nil {
+		log.Printf("Warning: Could not create request indexes: %v", err)
+	}
+
+	// Inbox notifications are listed per user, newest first, and counted unread
+	notificationIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "read", Value: 1}}},
+	}
+	if _, err := NotificationsCollection.Indexes().CreateMany(ctx, notificationIndexes); err != nil {
+		log.Printf("Warning: Could not create notification indexes: %v", err)
+	}
+
+	// Playback positions of users without a Jellyfin account, one per item
+	playbackIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "itemId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "updatedAt", Value: -1}}},
+	}
+	if _, err := PlaybackCollection.Indexes().CreateMany(ctx, playbackIndexes); err != nil {
+		log.Printf("Warning: Could not create playback indexes: %v", err)
+	}
+
+	// Each title is on a user's watchlist once, listed in the user's order
+	watchlistIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "mediaType", Value: 1}, {Key: "tmdbId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "position", Value: 1}}},
+	}
+	if _, err := WatchlistCollection.Indexes().CreateMany(ctx, watchlistIndexes); err != nil {
+		log.Printf("Warning: Could not create watchlist indexes: %v", err)
+	}
+
 	log.Println("Connected to MongoDB successfully")
 
 	// Create default admin user if no users exist
@@ -69,7 +270,8 @@ func Init(mongoURI, jwtSecret string) error {
 	return nil
 }
 
-// createDefaultAdmin creates a default admin user if none exists
+// createDefaultAdmin creates a default admin user if none exists. The account
+// must change its password before it can use anything else.
 func createDefaultAdmin() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -86,12 +288,13 @@ func createDefaultAdmin() error {
 		}
 
 		defaultAdmin := models.User{
-			Username:  "admin",
-			Email:     "",
-			Password:  string(hashedPassword),
-			IsAdmin:   true,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			Username:           "admin",
+			Email:              "",
+			Password:           string(hashedPassword),
+			IsAdmin:            true,
+			MustChangePassword: true,
+			CreatedAt:          time.Now(),
+			UpdatedAt:          time.Now(),
 		}
 
 		_, err = UsersCollection.InsertOne(ctx, defaultAdmin)
@@ -100,7 +303,26 @@ func createDefaultAdmin() error {
 		}
 
 		log.Println("Created default admin user (username: admin, password: admin)")
-		log.Println("Please change the default admin password immediately!")
+		log.Println("The password must be changed at first login")
+		return nil
+	}
+
+	// Installations created before the flag existed may still use admin/admin
+	var admin models.User
+	err = UsersCollection.FindOne(ctx, bson.M{"username": "admin", "mustChangePassword": bson.M{"$ne": true}}).Decode(&admin)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if admin.Password != "" && CheckPassword("admin", admin.Password) {
+		if _, err := UsersCollection.UpdateOne(ctx, bson.M{"_id": admin.ID}, bson.M{
+			"$set": bson.M{"mustChangePassword": true},
+		}); err != nil {
+			return err
+		}
+		log.Println("The admin account still uses the default password; it must be changed at next login")
 	}
 
 	return nil
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/models"
+)
+
+// UsernameThrottleKey returns the throttle key for a username; names are
+// folded so that case variations share one counter
+func UsernameThrottleKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+// IPThrottleKey returns the throttle key for a client IP
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginLockedFor returns the remaining time of the longest active lockout among keys
+func LoginLockedFor(ctx context.Context, keys ...string) (time.Duration, error) {
+	now := time.Now()
+	cursor, err := LoginThrottlesCollection.Find(ctx, bson.M{
+		"key":         bson.M{"$in": keys},
+		"lockedUntil": bson.M{"$gt": now},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var throttles []models.LoginThrottle
+	if err := cursor.All(ctx, &throttles); err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, throttle := range throttles {
+		if remaining := throttle.LockedUntil.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait, nil
+}
+
+// RecordLoginFailure counts a failure for key. Once more than freeAttempts
+// failures have occurred within window, the key is locked for baseDelay,
+// doubling with every further failure up to maxDelay.
+func RecordLoginFailure(ctx context.Context, key string, freeAttempts int, baseDelay, maxDelay, window time.Duration) (*models.LoginThrottle, error) {
+	now := time.Now()
+
+	var throttle models.LoginThrottle
+	err := LoginThrottlesCollection.FindOneAndUpdate(ctx,
+		bson.M{"key": key},
+		bson.M{
+			"$inc":         bson.M{"failures": 1},
+			"$set":         bson.M{"lastFailureAt": now, "expiresAt": now.Add(window)},
+			"$setOnInsert": bson.M{"firstFailureAt": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&throttle)
+	if err != nil {
+		return nil, err
+	}
+
+	excess := throttle.Failures - freeAttempts
+	if excess <= 0 {
+		return &throttle, nil
+	}
+
+	delay := baseDelay
+	for i := 1; i < excess && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	lockedUntil := now.Add(delay)
+	expiresAt := now.Add(window)
+	if lockedUntil.After(expiresAt) {
+		expiresAt = lockedUntil
+	}
+
+	_, err = LoginThrottlesCollection.UpdateOne(ctx, bson.M{"_id": throttle.ID}, bson.M{
+		"$set": bson.M{"lockedUntil": lockedUntil, "expiresAt": expiresAt},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	throttle.LockedUntil = &lockedUntil
+	throttle.ExpiresAt = expiresAt
+	return &throttle, nil
+}
+
+// ClearLoginThrottle removes the failure counter and any lockout for key
+func ClearLoginThrottle(ctx context.Context, key string) (bool, error) {
+	result, err := LoginThrottlesCollection.DeleteOne(ctx, bson.M{"key": key})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// RecordFailedLogin stores an audit record of a rejected login
+func RecordFailedLogin(ctx context.Context, entry models.FailedLogin, retention time.Duration) error {
+	entry.CreatedAt = time.Now()
+	entry.ExpiresAt = entry.CreatedAt.Add(retention)
+	_, err := FailedLoginsCollection.InsertOne(ctx, entry)
+	return err
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/models"
+)
+
+// ensureBuiltInRoles creates the built-in roles on first start; their
+// permissions can be edited afterwards
+func ensureBuiltInRoles(ctx context.Context) error {
+	now := time.Now()
+	_, err := RolesCollection.UpdateOne(ctx,
+		bson.M{"name": models.DefaultRoleName},
+		bson.M{"$setOnInsert": models.Role{
+			Name:        models.DefaultRoleName,
+			Description: "Default role for users without any other role",
+			Permissions: []string{
+				models.PermissionRequestMovie,
+				models.PermissionRequestTV,
+				models.PermissionDownloadsView,
+			},
+			BuiltIn:   true,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// ResolvePermissions returns the effective permissions of a user: every
+// permission for administrators, otherwise the union of the user's roles
+// (or of the default role when none is assigned)
+func ResolvePermissions(ctx context.Context, user *models.User) ([]string, error) {
+	if user.IsAdmin {
+		return models.AllPermissionNames(), nil
+	}
+
+	roleNames := user.Roles
+	if len(roleNames) == 0 {
+		roleNames = []string{models.DefaultRoleName}
+	}
+
+	return RolePermissions(ctx, roleNames)
+}
+
+// RolePermissions returns the union of the permissions of the named roles
+func RolePermissions(ctx context.Context, roleNames []string) ([]string, error) {
+	cursor, err := RolesCollection.Find(ctx, bson.M{"name": bson.M{"$in": roleNames}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var roles []models.Role
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	permissions := []string{}
+	for _, role := range roles {
+		for _, permission := range role.Permissions {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/models"
+)
+
+// ErrSessionInactive is returned when a session has been revoked or has expired
+var ErrSessionInactive = errors.New("session revoked or expired")
+
+// activeSessionFilter matches sessions that are neither revoked nor expired
+func activeSessionFilter() bson.M {
+	return bson.M{
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+}
+
+// CheckSession verifies that a session exists and is still active
+func CheckSession(ctx context.Context, sessionID string) error {
+	objectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return ErrSessionInactive
+	}
+
+	filter := activeSessionFilter()
+	filter["_id"] = objectID
+
+	count, err := SessionsCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionInactive
+	}
+
+	return nil
+}
+
+// RevokeSession revokes a single session belonging to a user
+func RevokeSession(ctx context.Context, userID, sessionID primitive.ObjectID) (bool, error) {
+	filter := activeSessionFilter()
+	filter["_id"] = sessionID
+	filter["userId"] = userID
+
+	result, err := SessionsCollection.UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{"revokedAt": time.Now()},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// RevokeUserSessions revokes every active session of a user, optionally
+// keeping one session (e.g. the one making the request) alive
+func RevokeUserSessions(ctx context.Context, userID primitive.ObjectID, exceptSessionID string) (int64, error) {
+	filter := activeSessionFilter()
+	filter["userId"] = userID
+
+	if exceptSessionID != "" {
+		if exceptID, err := primitive.ObjectIDFromHex(exceptSessionID); err == nil {
+			filter["_id"] = bson.M{"$ne": exceptID}
+		}
+	}
+
+	result, err := SessionsCollection.UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{"revokedAt": time.Now()},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// ListUserSessions returns the active sessions of a user, most recent first
+func ListUserSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	filter := activeSessionFilter()
+	filter["userId"] = userID
+
+	opts := options.Find().SetSort(bson.D{{Key: "lastUsedAt", Value: -1}})
+	cursor, err := SessionsCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
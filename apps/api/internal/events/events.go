@@ -0,0 +1,102 @@
+// Package events is an in-process publish/subscribe bus that lets features
+// react to things happening elsewhere, such as downloads finishing in Radarr
+// or Sonarr, without depending on each other.
+package events
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Event types published by the Radarr and Sonarr webhooks
+const (
+	RadarrGrab        = "radarr.grab"
+	RadarrDownload    = "radarr.download"
+	RadarrUpgrade     = "radarr.upgrade"
+	RadarrRename      = "radarr.rename"
+	RadarrMovieDelete = "radarr.movie_delete"
+	RadarrHealth      = "radarr.health"
+
+	SonarrGrab         = "sonarr.grab"
+	SonarrDownload     = "sonarr.download"
+	SonarrUpgrade      = "sonarr.upgrade"
+	SonarrRename       = "sonarr.rename"
+	SonarrSeriesDelete = "sonarr.series_delete"
+	SonarrHealth       = "sonarr.health"
+)
+
+// Event types published by the Jellyfin webhook; the payload is a
+// *models.JellyfinEvent
+const (
+	JellyfinItemAdded     = "jellyfin.item_added"
+	JellyfinPlaybackStart = "jellyfin.playback_start"
+	JellyfinPlaybackStop  = "jellyfin.playback_stop"
+	JellyfinUserDataSaved = "jellyfin.user_data_saved"
+)
+
+// Event types published by the request workflow; the payload is a
+// *models.MediaRequest
+const (
+	RequestApproved  = "request.approved"
+	RequestDeclined  = "request.declined"
+	RequestAvailable = "request.available"
+)
+
+// Event types published by the notification inbox; the payload is a
+// *models.Notification
+const (
+	NotificationCreated = "notification.created"
+)
+
+// All subscribes a handler to every event type
+const All = "*"
+
+// Event is something that happened; Payload is a pointer to the typed model
+// for the event type (for example *models.RadarrWebhookPayload)
+type Event struct {
+	Type    string
+	Payload interface{}
+	Time    time.Time
+}
+
+// Handler receives published events
+type Handler func(Event)
+
+var (
+	mu          sync.RWMutex
+	subscribers = make(map[string][]Handler)
+)
+
+// Subscribe registers handler for events of the given type, or for every
+// event with All. Subscriptions last for the life of the process.
+func Subscribe(eventType string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscribers[eventType] = append(subscribers[eventType], handler)
+}
+
+// Publish delivers an event to its subscribers. Each handler runs in its own
+// goroutine so a slow subscriber cannot hold up the publisher, and a
+// panicking one is logged rather than crashing the server.
+func Publish(eventType string, payload interface{}) {
+	event := Event{Type: eventType, Payload: payload, Time: time.Now()}
+
+	mu.RLock()
+	handlers := make([]Handler, 0, len(subscribers[eventType])+len(subscribers[All]))
+	handlers = append(handlers, subscribers[eventType]...)
+	handlers = append(handlers, subscribers[All]...)
+	mu.RUnlock()
+
+	for _, handler := range handlers {
+		go func(handler Handler) {
+			defer func() {
+				if err := recover(); err != nil {
+					log.Printf("Event handler for %s panicked: %v\n%s", event.Type, err, debug.Stack())
+				}
+			}()
+			handler(event)
+		}(handler)
+	}
+}
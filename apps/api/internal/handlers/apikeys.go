@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+)
+
+const (
+	// maxAPIKeysPerUser bounds how many active keys one account may hold
+	maxAPIKeysPerUser = 25
+	// apiKeyHintLength is how much of a key is kept to help users recognize it
+	apiKeyHintLength = len(models.APIKeyPrefix) + 6
+)
+
+// generateAPIKey returns a new random API key
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return models.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ListAPIKeys returns the caller's active API keys
+func (h *AuthHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := r.Context().Value("userID").(string)
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	keys, err := database.ListUserAPIKeys(ctx, objectID)
+	if err != nil {
+		http.Error(w, "Error fetching API keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// CreateAPIKey creates a named API key limited to the requested scopes. The
+// key itself is only returned in this response.
+func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		http.Error(w, "Name must be 1-64 characters", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		http.Error(w, "expiresInDays cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	scopes := uniqueStrings(req.Scopes)
+	if err := validatePermissions(r, scopes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := r.Context().Value("userID").(string)
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	existing, err := database.ListUserAPIKeys(ctx, objectID)
+	if err != nil {
+		http.Error(w, "Error fetching API keys", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxAPIKeysPerUser {
+		http.Error(w, "Too many API keys, revoke one first", http.StatusConflict)
+		return
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		http.Error(w, "Error generating API key", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	apiKey := models.APIKey{
+		UserID:    objectID,
+		Name:      req.Name,
+		KeyHash:   database.HashAPIKey(key),
+		Hint:      key[:apiKeyHintLength],
+		Scopes:    scopes,
+		CreatedAt: now,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	result, err := database.APIKeysCollection.InsertOne(ctx, apiKey)
+	if err != nil {
+		log.Printf("Error creating API key: %v", err)
+		http.Error(w, "Error creating API key", http.StatusInternalServerError)
+		return
+	}
+	apiKey.ID = result.InsertedID.(primitive.ObjectID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.CreateAPIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+// RevokeAPIKey revokes one of the caller's API keys
+func (h *AuthHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keyID, err := primitive.ObjectIDFromHex(strings.TrimPrefix(r.URL.Path, "/api/auth/api-keys/"))
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := r.Context().Value("userID").(string)
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := database.RevokeUserAPIKeys(ctx, objectID, &keyID)
+	if err != nil {
+		http.Error(w, "Error revoking API key", http.StatusInternalServerError)
+		return
+	}
+	if revoked == 0 {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "API key revoked successfully"})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// arrRequest sends a JSON request to a Radarr or Sonarr API and returns the
+// response status and body
+func arrRequest(baseURL, apiKey, method, path string, payload interface{}, timeout time.Duration) (int, []byte, error) {
+	var reader io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return 0, nil, fmt.Errorf("error encoding request: %v", err)
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequest(method, baseURL+path, reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("X-Api-Key", apiKey)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error reading response: %v", err)
+	}
+
+	return resp.StatusCode, body, nil
+}
+
+// arrGetJSON performs a GET against a Radarr or Sonarr API and decodes the
+// JSON response into out
+func arrGetJSON(baseURL, apiKey, path string, out interface{}) error {
+	status, body, err := arrRequest(baseURL, apiKey, "GET", path, nil, 15*time.Second)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("API returned status %d: %s", status, string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("error parsing response: %v", err)
+	}
+	return nil
+}
+
+// firstRootFolder returns the first root folder configured in Radarr or
+// Sonarr, or an empty string when none can be read
+func firstRootFolder(baseURL, apiKey string) string {
+	var folders []struct {
+		Path string `json:"path"`
+	}
+	if err := arrGetJSON(baseURL, apiKey, "/api/v3/rootfolder", &folders); err != nil || len(folders) == 0 {
+		return ""
+	}
+	return folders[0].Path
+}
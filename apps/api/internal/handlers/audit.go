@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/middleware"
+	"jellystreaming/internal/models"
+)
+
+// auditExportLimit bounds the number of events in a single export
+const auditExportLimit = 10000
+
+// auditRedacted is recorded instead of the value of a changed secret
+const auditRedacted = "(changed)"
+
+// AuditHandler exposes the audit log to admins
+type AuditHandler struct{}
+
+// NewAuditHandler creates a new AuditHandler
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{}
+}
+
+// recordAudit stores an audit event for the authenticated caller. Failures are
+// logged rather than returned: the audited action has already happened.
+func recordAudit(cfg *config.Config, r *http.Request, event models.AuditEvent) {
+	event.Actor.UserID, _ = r.Context().Value("userID").(string)
+	event.Actor.Username, _ = r.Context().Value("username").(string)
+	event.Actor.APIKeyID, _ = r.Context().Value("apiKeyID").(string)
+	event.IP = middleware.ClientIP(r)
+	event.UserAgent = r.UserAgent()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := database.RecordAudit(ctx, event, cfg.AuditRetention); err != nil {
+		log.Printf("Error recording audit event %s: %v", event.Action, err)
+	}
+}
+
+// auditChanges lists the fields that differ between two snapshots, in field order
+func auditChanges(before, after map[string]interface{}) []models.AuditChange {
+	fields := make([]string, 0, len(before)+len(after))
+	for field := range before {
+		fields = append(fields, field)
+	}
+	for field := range after {
+		if _, ok := before[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []models.AuditChange{}
+	for _, field := range fields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			changes = append(changes, models.AuditChange{Field: field, Before: before[field], After: after[field]})
+		}
+	}
+	return changes
+}
+
+// userAuditFields is the snapshot of a user compared by audit events
+func userAuditFields(user *models.User) map[string]interface{} {
+	fields := map[string]interface{}{
+		"username":           user.Username,
+		"email":              user.Email,
+		"isAdmin":            user.IsAdmin,
+		"mustChangePassword": user.MustChangePassword,
+		"mfaEnabled":         user.MFAEnabled,
+		"jellyfinUserId":     user.JellyfinUserID,
+		"roles":              user.Roles,
+	}
+	if user.Quota != nil {
+		fields["quota"] = *user.Quota
+	}
+	return fields
+}
+
+// userAuditTarget identifies a user in audit events
+func userAuditTarget(user *models.User) models.AuditTarget {
+	return models.AuditTarget{Type: "user", ID: user.ID.Hex(), Name: user.Username}
+}
+
+// roleAuditFields is the snapshot of a role compared in role.update events
+func roleAuditFields(role *models.Role) map[string]interface{} {
+	return map[string]interface{}{
+		"description": role.Description,
+		"permissions": role.Permissions,
+	}
+}
+
+// roleAuditTarget identifies a role in audit events
+func roleAuditTarget(role *models.Role) models.AuditTarget {
+	return models.AuditTarget{Type: "role", ID: role.ID.Hex(), Name: role.Name}
+}
+
+// auditFilter builds a query from ?actor, action, targetType, targetId, ip, since and until
+func auditFilter(r *http.Request) (bson.M, error) {
+	query := r.URL.Query()
+	filter := bson.M{}
+	if actor := query.Get("actor"); actor != "" {
+		filter["actor.username"] = actor
+	}
+	if action := query.Get("action"); action != "" {
+		filter["action"] = action
+	}
+	if targetType := query.Get("targetType"); targetType != "" {
+		filter["target.type"] = targetType
+	}
+	if targetID := query.Get("targetId"); targetID != "" {
+		filter["target.id"] = targetID
+	}
+	if ip := query.Get("ip"); ip != "" {
+		filter["ip"] = ip
+	}
+
+	createdAt := bson.M{}
+	for param, operator := range map[string]string{"since": "$gte", "until": "$lt"} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter, expected RFC 3339", param)
+		}
+		createdAt[operator] = t
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+
+	return filter, nil
+}
+
+// List returns a page of audit events, newest first
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := auditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := queryInt(r, "limit", 50)
+	if limit == 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	skip := queryInt(r, "skip", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := database.AuditCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		http.Error(w, "Error counting audit events", http.StatusInternalServerError)
+		return
+	}
+
+	cursor, err := database.AuditCollection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)))
+	if err != nil {
+		http.Error(w, "Error fetching audit events", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	response := models.AuditEventsResponse{Items: []models.AuditEvent{}, Total: total}
+	if err := cursor.All(ctx, &response.Items); err != nil {
+		http.Error(w, "Error decoding audit events", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Export downloads the matching audit events as ?format=csv or json (default)
+func (h *AuditHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := auditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := database.AuditCollection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetLimit(auditExportLimit))
+	if err != nil {
+		http.Error(w, "Error fetching audit events", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		http.Error(w, "Error decoding audit events", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"time", "action", "actor", "actor_id", "api_key_id", "target_type", "target_id", "target_name", "status", "ip", "changes"})
+	for _, event := range events {
+		changes := ""
+		if len(event.Changes) > 0 {
+			encoded, _ := json.Marshal(event.Changes)
+			changes = string(encoded)
+		}
+		status := ""
+		if event.Status != 0 {
+			status = strconv.Itoa(event.Status)
+		}
+		writer.Write([]string{
+			event.CreatedAt.UTC().Format(time.RFC3339),
+			event.Action,
+			csvSafe(event.Actor.Username),
+			event.Actor.UserID,
+			event.Actor.APIKeyID,
+			event.Target.Type,
+			csvSafe(event.Target.ID),
+			csvSafe(event.Target.Name),
+			status,
+			event.IP,
+			changes,
+		})
+	}
+	writer.Flush()
+}
+
+// csvSafe keeps user-controlled values from being interpreted as spreadsheet formulas
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
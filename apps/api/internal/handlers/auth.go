@@ -3,8 +3,10 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -13,27 +15,64 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 
+	"jellystreaming/internal/config"
 	"jellystreaming/internal/database"
 	"jellystreaming/internal/middleware"
 	"jellystreaming/internal/models"
+	"jellystreaming/internal/oidc"
+	"jellystreaming/internal/password"
 )
 
+// errInvalidCredentials is returned when a username/password pair is rejected
+var errInvalidCredentials = errors.New("invalid username or password")
+
 // AuthHandler handles authentication-related requests
-type AuthHandler struct{}
+type AuthHandler struct {
+	config *config.Config
+	oidc   *oidc.Provider // nil when single sign-on is not configured
+}
 
 // NewAuthHandler creates a new AuthHandler
-func NewAuthHandler() *AuthHandler {
-	return &AuthHandler{}
+func NewAuthHandler(cfg *config.Config) *AuthHandler {
+	h := &AuthHandler{config: cfg}
+
+	if cfg.OIDCIssuerURL != "" && cfg.OIDCClientID != "" && cfg.OIDCRedirectURL != "" {
+		h.oidc = oidc.New(oidc.Config{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		})
+		log.Printf("OIDC single sign-on enabled with issuer %s", cfg.OIDCIssuerURL)
+	}
+
+	return h
+}
+
+// passwordPolicy returns the configured password policy
+func passwordPolicy(cfg *config.Config) password.Policy {
+	return password.Policy{
+		MinLength:        cfg.PasswordMinLength,
+		RequireUppercase: cfg.PasswordRequireUppercase,
+		RequireLowercase: cfg.PasswordRequireLowercase,
+		RequireDigit:     cfg.PasswordRequireDigit,
+		RequireSymbol:    cfg.PasswordRequireSymbol,
+		BlockCommon:      cfg.PasswordBlockCommon,
+	}
 }
 
-// generateToken creates a JWT token for a user
-func generateToken(user *models.User) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// generateToken creates a short-lived JWT access token bound to a session
+func generateToken(user *models.User, sessionID string, permissions []string, ttl time.Duration) (string, error) {
+	expirationTime := time.Now().Add(ttl)
 
 	claims := &middleware.Claims{
-		UserID:   user.ID.Hex(),
-		Username: user.Username,
-		IsAdmin:  user.IsAdmin,
+		UserID:             user.ID.Hex(),
+		Username:           user.Username,
+		IsAdmin:            user.IsAdmin,
+		SessionID:          sessionID,
+		Permissions:        permissions,
+		MustChangePassword: user.MustChangePassword,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -62,42 +101,93 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	var user models.User
-	err := database.UsersCollection.FindOne(ctx, bson.M{"username": req.Username}).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+	if !h.checkLoginThrottle(ctx, w, r, req.Username) {
+		return
+	}
+
+	var user *models.User
+	var err error
+
+	// Jellyfin is tried first when enabled; local accounts (such as the
+	// bootstrap admin) remain available as a fallback
+	if h.config.JellyfinAuthEnabled {
+		user, err = h.loginWithJellyfin(ctx, req.Username, req.Password)
+		if errors.Is(err, errJellyfinAccountConflict) {
+			http.Error(w, err.Error(), http.StatusConflict)
 			return
 		}
+		if err != nil && !errors.Is(err, errInvalidCredentials) {
+			log.Printf("Jellyfin authentication unavailable, falling back to local accounts: %v", err)
+		}
+	}
+
+	if user == nil {
+		user, err = loginLocal(ctx, req.Username, req.Password)
+	}
+	if errors.Is(err, errInvalidCredentials) {
+		h.recordLoginFailure(ctx, r, req.Username, models.FailedLoginInvalidCredentials)
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
 		log.Printf("Error finding user: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if !database.CheckPassword(req.Password, user.Password) {
-		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+	// With two-factor enabled the password alone only earns a short-lived challenge;
+	// failure counters are only cleared once the second factor is verified
+	if user.MFAEnabled {
+		mfaToken, err := generateMFAToken(user)
+		if err != nil {
+			log.Printf("Error generating MFA token: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			ExpiresIn:   int(mfaTokenTTL.Seconds()),
+		})
 		return
 	}
 
-	token, err := generateToken(&user)
+	h.clearLoginFailures(ctx, req.Username)
+
+	response, err := h.startSession(r, user)
 	if err != nil {
-		log.Printf("Error generating token: %v", err)
+		log.Printf("Error starting session: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	response := models.LoginResponse{
-		Token: token,
-		User:  user.ToResponse(),
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// loginLocal checks a username and password against local accounts
+func loginLocal(ctx context.Context, username, password string) (*models.User, error) {
+	var user models.User
+	err := database.UsersCollection.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !database.CheckPassword(password, user.Password) {
+		return nil, errInvalidCredentials
+	}
+
+	return &user, nil
+}
+
 // VerifyToken verifies a JWT token and returns user info
 func (h *AuthHandler) VerifyToken(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -152,8 +242,17 @@ func (h *AuthHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	permissions, err := database.ResolvePermissions(ctx, &user)
+	if err != nil {
+		http.Error(w, "Error resolving permissions", http.StatusInternalServerError)
+		return
+	}
+
+	response := user.ToResponse()
+	response.Permissions = permissions
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user.ToResponse())
+	json.NewEncoder(w).Encode(response)
 }
 
 // ChangePassword allows users to change their own password
@@ -176,11 +275,6 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(req.NewPassword) < 4 {
-		http.Error(w, "New password must be at least 4 characters", http.StatusBadRequest)
-		return
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -202,6 +296,15 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.NewPassword == req.CurrentPassword {
+		http.Error(w, "New password must differ from the current password", http.StatusBadRequest)
+		return
+	}
+	if err := passwordPolicy(h.config).Validate(req.NewPassword, user.Username); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	hashedPassword, err := database.HashPassword(req.NewPassword)
 	if err != nil {
 		http.Error(w, "Error hashing password", http.StatusInternalServerError)
@@ -216,15 +319,42 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 				"password":  hashedPassword,
 				"updatedAt": time.Now(),
 			},
+			"$unset": bson.M{"mustChangePassword": ""},
 		},
 	)
 	if err != nil {
 		http.Error(w, "Error updating password", http.StatusInternalServerError)
 		return
 	}
+	user.MustChangePassword = false
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditPasswordChange,
+		Target:  userAuditTarget(&user),
+		Changes: []models.AuditChange{{Field: "password", After: auditRedacted}},
+	})
+
+	// Sign out every other device that knew the old password
+	sessionID, _ := r.Context().Value("sessionID").(string)
+	if _, err := database.RevokeUserSessions(ctx, objectID, sessionID); err != nil {
+		log.Printf("Error revoking sessions after password change: %v", err)
+	}
+
+	// The caller's token may still carry the password change restriction
+	tokens, err := h.tokenResponse(&user, sessionID, "")
+	if err != nil {
+		log.Printf("Error issuing token after password change: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Password updated successfully"})
+	json.NewEncoder(w).Encode(models.ChangePasswordResponse{
+		Message:   "Password updated successfully",
+		Token:     tokens.Token,
+		ExpiresIn: tokens.ExpiresIn,
+		User:      tokens.User,
+	})
 }
 
 // ListUsers returns all users (admin only)
@@ -277,14 +407,30 @@ func (h *AuthHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(req.Password) < 4 {
-		http.Error(w, "Password must be at least 4 characters", http.StatusBadRequest)
+	if err := passwordPolicy(h.config).Validate(req.Password, req.Username); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	callerIsAdmin, _ := r.Context().Value("isAdmin").(bool)
+	if req.IsAdmin && !callerIsAdmin {
+		http.Error(w, "Only admins can create admins", http.StatusForbidden)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	roles := uniqueStrings(req.Roles)
+	if err := validateRoleAssignment(ctx, r, roles); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateQuota(req.Quota); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	count, err := database.UsersCollection.CountDocuments(ctx, bson.M{"username": req.Username})
 	if err != nil {
 		http.Error(w, "Error checking username", http.StatusInternalServerError)
@@ -302,12 +448,15 @@ func (h *AuthHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	newUser := models.User{
-		Username:  req.Username,
-		Email:     req.Email,
-		Password:  hashedPassword,
-		IsAdmin:   req.IsAdmin,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Username:           req.Username,
+		Email:              req.Email,
+		Password:           hashedPassword,
+		IsAdmin:            req.IsAdmin,
+		Roles:              roles,
+		MustChangePassword: req.MustChangePassword,
+		Quota:              req.Quota,
+		CreatedAt:          time.Now(),
+		UpdatedAt:          time.Now(),
 	}
 
 	result, err := database.UsersCollection.InsertOne(ctx, newUser)
@@ -319,6 +468,12 @@ func (h *AuthHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 	newUser.ID = result.InsertedID.(primitive.ObjectID)
 
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditUserCreate,
+		Target:  userAuditTarget(&newUser),
+		Changes: auditChanges(nil, userAuditFields(&newUser)),
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(newUser.ToResponse())
@@ -348,6 +503,24 @@ func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var existingUser models.User
+	err = database.UsersCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&existingUser)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	// Users with users.manage but without admin rights cannot touch admins
+	callerIsAdmin, _ := r.Context().Value("isAdmin").(bool)
+	if !callerIsAdmin && (existingUser.IsAdmin || (req.IsAdmin != nil && *req.IsAdmin)) {
+		http.Error(w, "Only admins can modify admins", http.StatusForbidden)
+		return
+	}
+
+	// Demoting a user, taking permissions away through their roles or
+	// resetting their password ends all of their sessions
+	revokeSessions := false
+
 	update := bson.M{"$set": bson.M{"updatedAt": time.Now()}}
 
 	if req.Email != nil {
@@ -355,8 +528,8 @@ func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.Password != nil && *req.Password != "" {
-		if len(*req.Password) < 4 {
-			http.Error(w, "Password must be at least 4 characters", http.StatusBadRequest)
+		if err := passwordPolicy(h.config).Validate(*req.Password, existingUser.Username); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 		hashedPassword, err := database.HashPassword(*req.Password)
@@ -365,13 +538,80 @@ func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		update["$set"].(bson.M)["password"] = hashedPassword
+		revokeSessions = true
+	}
+
+	if req.JellyfinUserID != nil {
+		// A stored Jellyfin token belongs to the previously linked user
+		if *req.JellyfinUserID == "" {
+			update["$unset"] = bson.M{"jellyfinUserId": "", "jellyfinToken": ""}
+		} else {
+			var jellyfinUser models.JellyfinUser
+			if err := jellyfinGetJSON(h.config, "/Users/"+url.PathEscape(*req.JellyfinUserID), nil, &jellyfinUser); err != nil {
+				log.Printf("Error looking up Jellyfin user %s: %v", *req.JellyfinUserID, err)
+				http.Error(w, "Jellyfin user not found", http.StatusBadRequest)
+				return
+			}
+			update["$set"].(bson.M)["jellyfinUserId"] = jellyfinUser.Id
+			if jellyfinUser.Id != existingUser.JellyfinUserID {
+				update["$unset"] = bson.M{"jellyfinToken": ""}
+			}
+		}
+	}
+
+	var previousPermissions []string
+	if req.Roles != nil {
+		previousPermissions, err = database.ResolvePermissions(ctx, &existingUser)
+		if err != nil {
+			http.Error(w, "Error resolving permissions", http.StatusInternalServerError)
+			return
+		}
+		roles := uniqueStrings(*req.Roles)
+		if err := validateRoleAssignment(ctx, r, append(roles, existingUser.Roles...)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(roles) == 0 {
+			if update["$unset"] == nil {
+				update["$unset"] = bson.M{}
+			}
+			update["$unset"].(bson.M)["roles"] = ""
+		} else {
+			update["$set"].(bson.M)["roles"] = roles
+		}
+	}
+
+	if req.MustChangePassword != nil {
+		update["$set"].(bson.M)["mustChangePassword"] = *req.MustChangePassword
+	}
+
+	if req.Quota != nil {
+		if *req.Quota == (models.RequestQuota{}) {
+			if update["$unset"] == nil {
+				update["$unset"] = bson.M{}
+			}
+			update["$unset"].(bson.M)["quota"] = ""
+		} else {
+			if err := validateQuota(req.Quota); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			update["$set"].(bson.M)["quota"] = *req.Quota
+		}
 	}
 
 	if req.IsAdmin != nil {
 		update["$set"].(bson.M)["isAdmin"] = *req.IsAdmin
+		if existingUser.IsAdmin && !*req.IsAdmin {
+			revokeSessions = true
+		}
 	}
 
 	result, err := database.UsersCollection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "Jellyfin user is already linked to another account", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error updating user", http.StatusInternalServerError)
 		return
@@ -382,6 +622,13 @@ func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.ResetMFA != nil && *req.ResetMFA {
+		if err := disableMFA(ctx, objectID); err != nil {
+			http.Error(w, "Error resetting two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	var updatedUser models.User
 	err = database.UsersCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&updatedUser)
 	if err != nil {
@@ -389,6 +636,30 @@ func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Losing a permission through a role change also ends all sessions
+	if req.Roles != nil && !revokeSessions {
+		lost, err := permissionsLost(ctx, &updatedUser, previousPermissions)
+		if err != nil {
+			log.Printf("Error resolving permissions of user %s: %v", userID, err)
+		}
+		revokeSessions = lost
+	}
+	if revokeSessions {
+		if _, err := database.RevokeUserSessions(ctx, objectID, ""); err != nil {
+			log.Printf("Error revoking sessions for user %s: %v", userID, err)
+		}
+	}
+
+	changes := auditChanges(userAuditFields(&existingUser), userAuditFields(&updatedUser))
+	if req.Password != nil && *req.Password != "" {
+		changes = append(changes, models.AuditChange{Field: "password", After: auditRedacted})
+	}
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditUserUpdate,
+		Target:  userAuditTarget(&updatedUser),
+		Changes: changes,
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedUser.ToResponse())
 }
@@ -418,6 +689,11 @@ func (h *AuthHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if callerIsAdmin, _ := r.Context().Value("isAdmin").(bool); user.IsAdmin && !callerIsAdmin {
+		http.Error(w, "Only admins can delete admins", http.StatusForbidden)
+		return
+	}
+
 	if user.IsAdmin {
 		adminCount, err := database.UsersCollection.CountDocuments(ctx, bson.M{"isAdmin": true})
 		if err != nil {
@@ -441,6 +717,19 @@ func (h *AuthHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := database.RevokeUserSessions(ctx, objectID, ""); err != nil {
+		log.Printf("Error revoking sessions for deleted user %s: %v", userID, err)
+	}
+	if _, err := database.RevokeUserAPIKeys(ctx, objectID, nil); err != nil {
+		log.Printf("Error revoking API keys for deleted user %s: %v", userID, err)
+	}
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditUserDelete,
+		Target:  userAuditTarget(&user),
+		Changes: auditChanges(userAuditFields(&user), nil),
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "User deleted successfully"})
 }
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+)
+
+// AvailabilityHandler resolves where a title stands across Jellyfin,
+// Radarr/Sonarr and the request queue
+type AvailabilityHandler struct {
+	config *config.Config
+	tmdb   *TMDBHandler
+}
+
+// NewAvailabilityHandler creates an availability handler that looks titles
+// up through tmdb and its library index
+func NewAvailabilityHandler(cfg *config.Config, tmdb *TMDBHandler) *AvailabilityHandler {
+	return &AvailabilityHandler{
+		config: cfg,
+		tmdb:   tmdb,
+	}
+}
+
+// mediaIDs are the provider IDs a title is matched on
+type mediaIDs struct {
+	Tmdb int
+	Tvdb int
+	Imdb string
+}
+
+// libraryItemID finds the Jellyfin item of a title in an index map, trying
+// each of its provider IDs
+func libraryItemID(library map[string]string, ids mediaIDs) string {
+	keys := []string{tmdbKey(ids.Tmdb)}
+	if ids.Tvdb > 0 {
+		keys = append(keys, libraryKey("Tvdb", strconv.Itoa(ids.Tvdb)))
+	}
+	if ids.Imdb != "" {
+		keys = append(keys, libraryKey("Imdb", ids.Imdb))
+	}
+	for _, key := range keys {
+		if itemID := library[key]; itemID != "" {
+			return itemID
+		}
+	}
+	return ""
+}
+
+// tmdbExternalIDs looks up the IMDb (and for shows, TVDB) IDs of a TMDB title
+func (h *AvailabilityHandler) tmdbExternalIDs(mediaType string, tmdbID int) (string, int, error) {
+	kind := "movie"
+	if mediaType == models.MediaTypeTV {
+		kind = "tv"
+	}
+
+	body, status, err := h.tmdb.makeRequest(fmt.Sprintf("https://api.themoviedb.org/3/%s/%d/external_ids", kind, tmdbID))
+	if err != nil {
+		return "", 0, err
+	}
+	if status != http.StatusOK {
+		return "", 0, fmt.Errorf("TMDB returned status %d", status)
+	}
+
+	var ids struct {
+		ImdbId string `json:"imdb_id"`
+		TvdbId int    `json:"tvdb_id"`
+	}
+	if err := json.Unmarshal(body, &ids); err != nil {
+		return "", 0, fmt.Errorf("error parsing response: %v", err)
+	}
+	return ids.ImdbId, ids.TvdbId, nil
+}
+
+// jellyfinLibraryItems lists every movie or series a Jellyfin user can see
+// with its provider IDs
+func jellyfinLibraryItems(cfg *config.Config, jellyfinUserID, mediaType string) ([]models.JellyfinMovie, error) {
+	itemType := "Movie"
+	if mediaType == models.MediaTypeTV {
+		itemType = "Series"
+	}
+
+	// Series decode into the fields they share with movies
+	var items models.JellyfinResponse
+	err := jellyfinGetJSON(cfg, "/Users/"+url.PathEscape(jellyfinUserID)+"/Items", url.Values{
+		"IncludeItemTypes": {itemType},
+		"Recursive":        {"true"},
+		"Fields":           {"ProviderIds"},
+		"EnableImages":     {"false"},
+		"EnableUserData":   {"false"},
+	}, &items)
+	if err != nil {
+		return nil, err
+	}
+	return items.Items, nil
+}
+
+// jellyfinLibraryItem fetches one movie or series as a Jellyfin user
+func jellyfinLibraryItem(cfg *config.Config, jellyfinUserID, itemID string) (*models.JellyfinMovie, error) {
+	var items models.JellyfinResponse
+	err := jellyfinGetJSON(cfg, "/Users/"+url.PathEscape(jellyfinUserID)+"/Items", url.Values{
+		"Ids":    {itemID},
+		"Fields": {"ProviderIds"},
+	}, &items)
+	if err != nil {
+		return nil, err
+	}
+	if len(items.Items) == 0 {
+		return nil, nil
+	}
+	return &items.Items[0], nil
+}
+
+// radarrStatus finds the movie in Radarr and sums up its queue items
+func (h *AvailabilityHandler) radarrStatus(tmdbID int) (*models.RadarrMovie, *models.AvailabilityDownload, error) {
+	var movies []models.RadarrMovie
+	path := fmt.Sprintf("/api/v3/movie?tmdbId=%d", tmdbID)
+	if err := arrGetJSON(h.config.RadarrURL, h.config.RadarrAPIKey, path, &movies); err != nil {
+		return nil, nil, err
+	}
+	if len(movies) == 0 {
+		return nil, nil, nil
+	}
+	movie := &movies[0]
+
+	var queue []models.RadarrQueueItem
+	path = fmt.Sprintf("/api/v3/queue/details?movieId=%d", movie.Id)
+	if err := arrGetJSON(h.config.RadarrURL, h.config.RadarrAPIKey, path, &queue); err != nil {
+		return movie, nil, err
+	}
+
+	var download *models.AvailabilityDownload
+	for _, item := range queue {
+		download = addQueueItem(download, item.Status, item.Size, item.Sizeleft, item.Timeleft)
+	}
+	return movie, download, nil
+}
+
+// sonarrStatus finds the series in Sonarr by TVDB ID (or TMDB ID when the
+// TVDB ID is unknown) and sums up its queue items
+func (h *AvailabilityHandler) sonarrStatus(ids mediaIDs) (*models.SonarrSeries, *models.AvailabilityDownload, error) {
+	var list []models.SonarrSeries
+	path := "/api/v3/series"
+	if ids.Tvdb > 0 {
+		path += fmt.Sprintf("?tvdbId=%d", ids.Tvdb)
+	}
+	if err := arrGetJSON(h.config.SonarrURL, h.config.SonarrAPIKey, path, &list); err != nil {
+		return nil, nil, err
+	}
+
+	var series *models.SonarrSeries
+	for i := range list {
+		if (ids.Tvdb > 0 && list[i].TvdbId == ids.Tvdb) || (ids.Tmdb > 0 && list[i].TmdbId == ids.Tmdb) {
+			series = &list[i]
+			break
+		}
+	}
+	if series == nil {
+		return nil, nil, nil
+	}
+
+	var queue []models.SonarrQueueItem
+	path = fmt.Sprintf("/api/v3/queue/details?seriesId=%d", series.Id)
+	if err := arrGetJSON(h.config.SonarrURL, h.config.SonarrAPIKey, path, &queue); err != nil {
+		return series, nil, err
+	}
+
+	var download *models.AvailabilityDownload
+	for _, item := range queue {
+		download = addQueueItem(download, item.Status, item.Size, item.Sizeleft, item.Timeleft)
+	}
+	return series, download, nil
+}
+
+// addQueueItem adds one queue item to a download summary
+func addQueueItem(download *models.AvailabilityDownload, status string, size, sizeleft int64, timeleft string) *models.AvailabilityDownload {
+	if download == nil {
+		download = &models.AvailabilityDownload{Status: status, Timeleft: timeleft}
+	}
+	download.Items++
+	download.Size += size
+	download.Sizeleft += sizeleft
+	if status == "downloading" {
+		download.Status = status
+	}
+	if download.Size > 0 {
+		download.Progress = float64(download.Size-download.Sizeleft) / float64(download.Size) * 100
+	}
+	return download
+}
+
+// openRequest returns the newest pending or approved request for the title,
+// matched on its TMDB ID or, for shows, its TVDB ID
+func openRequest(ctx context.Context, mediaType string, ids mediaIDs) (*models.MediaRequest, error) {
+	match := bson.A{bson.M{"tmdbId": ids.Tmdb}}
+	if mediaType == models.MediaTypeTV && ids.Tvdb > 0 {
+		match = append(match, bson.M{"tvdbId": ids.Tvdb})
+	}
+
+	var request models.MediaRequest
+	err := database.RequestsCollection.FindOne(ctx, bson.M{
+		"mediaType": mediaType,
+		"$or":       match,
+		"status":    bson.M{"$in": bson.A{models.RequestStatusPending, models.RequestStatusApproved}},
+	}, options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})).Decode(&request)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetAvailability returns the status of a title in one call. Query:
+// tmdbId (required) and type=movie|tv. The library is what the caller's
+// Jellyfin user can see.
+func (h *AvailabilityHandler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	tmdbID, err := strconv.Atoi(query.Get("tmdbId"))
+	if err != nil || tmdbID <= 0 {
+		http.Error(w, "Valid tmdbId required", http.StatusBadRequest)
+		return
+	}
+	mediaType := query.Get("type")
+	if mediaType != models.MediaTypeMovie && mediaType != models.MediaTypeTV {
+		http.Error(w, "type must be movie or tv", http.StatusBadRequest)
+		return
+	}
+
+	availability := &models.Availability{MediaType: mediaType, TmdbId: tmdbID}
+	var mu sync.Mutex
+	fail := func(service string, err error) {
+		log.Printf("Error checking %s availability of %s %d: %v", service, mediaType, tmdbID, err)
+		mu.Lock()
+		availability.Errors = append(availability.Errors, service)
+		mu.Unlock()
+	}
+
+	ids := mediaIDs{Tmdb: tmdbID}
+	if h.config.TMDBToken != "" {
+		imdbID, tvdbID, err := h.tmdbExternalIDs(mediaType, tmdbID)
+		if err != nil {
+			fail("tmdb", err)
+		}
+		ids.Imdb, ids.Tvdb = imdbID, tvdbID
+	}
+
+	var (
+		wg           sync.WaitGroup
+		library      map[string]string
+		radarrMovie  *models.RadarrMovie
+		sonarrSeries *models.SonarrSeries
+		download     *models.AvailabilityDownload
+		request      *models.MediaRequest
+	)
+
+	// The library is what the caller's Jellyfin user can see; without one
+	// nothing is available to them
+	jellyfinUserID, _ := resolveJellyfinUserID(h.config, r)
+	if jellyfinUserID != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			snapshot := h.tmdb.index.get(jellyfinUserID)
+			library = snapshot.jellyfinMovies
+			if mediaType == models.MediaTypeTV {
+				library = snapshot.jellyfinSeries
+			}
+		}()
+	}
+
+	if mediaType == models.MediaTypeMovie && h.config.RadarrURL != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			movie, queued, err := h.radarrStatus(tmdbID)
+			if err != nil {
+				fail("radarr", err)
+			}
+			radarrMovie, download = movie, queued
+		}()
+	}
+	if mediaType == models.MediaTypeTV && h.config.SonarrURL != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			series, queued, err := h.sonarrStatus(ids)
+			if err != nil {
+				fail("sonarr", err)
+			}
+			sonarrSeries, download = series, queued
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		found, err := openRequest(ctx, mediaType, ids)
+		if err != nil {
+			fail("requests", err)
+		}
+		request = found
+	}()
+
+	wg.Wait()
+
+	// Radarr and Sonarr fill in IDs TMDB did not have
+	if radarrMovie != nil && ids.Imdb == "" {
+		ids.Imdb = radarrMovie.ImdbId
+	}
+	if sonarrSeries != nil {
+		if ids.Tvdb == 0 {
+			ids.Tvdb = sonarrSeries.TvdbId
+		}
+		if ids.Imdb == "" {
+			ids.Imdb = sonarrSeries.ImdbId
+		}
+	}
+	availability.TvdbId, availability.ImdbId = ids.Tvdb, ids.Imdb
+
+	if itemID := libraryItemID(library, ids); itemID != "" {
+		item, err := jellyfinLibraryItem(h.config, jellyfinUserID, itemID)
+		if err != nil {
+			fail("jellyfin", err)
+		}
+		availability.Jellyfin = item
+	}
+	availability.Radarr = radarrMovie
+	availability.Sonarr = sonarrSeries
+	availability.Download = download
+	if request != nil {
+		userID, _ := r.Context().Value("userID").(string)
+		availability.Request = &models.AvailabilityRequest{
+			ID:                  request.ID,
+			Status:              request.Status,
+			RequestedByUsername: request.RequestedByUsername,
+			Mine:                request.RequestedBy.Hex() == userID,
+			CreatedAt:           request.CreatedAt,
+		}
+	}
+
+	switch {
+	case availability.Jellyfin != nil:
+		availability.Status = models.AvailabilityAvailable
+	case download != nil:
+		availability.Status = models.AvailabilityDownloading
+	case (radarrMovie != nil && radarrMovie.Monitored) || (sonarrSeries != nil && sonarrSeries.Monitored):
+		availability.Status = models.AvailabilityMonitored
+	case request != nil:
+		availability.Status = models.AvailabilityRequested
+	default:
+		availability.Status = models.AvailabilityNotAvailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(availability)
+}
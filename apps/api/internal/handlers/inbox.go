@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/events"
+	"jellystreaming/internal/models"
+)
+
+// notificationIDFromPath extracts the notification ID from
+// /api/notifications/{id}[/read]
+func notificationIDFromPath(p string) (primitive.ObjectID, error) {
+	id, _, _ := strings.Cut(strings.TrimPrefix(p, "/api/notifications/"), "/")
+	return primitive.ObjectIDFromHex(id)
+}
+
+// ListNotifications returns the user's inbox, newest first. Filters:
+// unread=true.
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	filter := bson.M{"userId": userID}
+	if r.URL.Query().Get("unread") == "true" {
+		filter["read"] = false
+	}
+
+	limit := queryInt(r, "limit", 50)
+	if limit == 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	skip := queryInt(r, "skip", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response := models.NotificationsResponse{Items: []models.Notification{}}
+	if response.Total, err = database.NotificationsCollection.CountDocuments(ctx, filter); err != nil {
+		http.Error(w, "Error counting notifications", http.StatusInternalServerError)
+		return
+	}
+	if response.Unread, err = unreadCount(ctx, userID); err != nil {
+		http.Error(w, "Error counting notifications", http.StatusInternalServerError)
+		return
+	}
+
+	cursor, err := database.NotificationsCollection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)))
+	if err != nil {
+		http.Error(w, "Error fetching notifications", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &response.Items); err != nil {
+		http.Error(w, "Error decoding notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UnreadCount returns how many unread items the user's inbox holds
+func (h *NotificationHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := unreadCount(ctx, userID)
+	if err != nil {
+		http.Error(w, "Error counting notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"count": count})
+}
+
+// MarkRead marks one of the user's notifications as read
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	notificationID, err := notificationIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := database.NotificationsCollection.UpdateOne(ctx,
+		bson.M{"_id": notificationID, "userId": userID},
+		bson.M{"$set": bson.M{"read": true, "readAt": time.Now()}},
+	)
+	if err != nil {
+		http.Error(w, "Error updating notification", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+	if result.ModifiedCount > 0 {
+		go h.pushUnread(userID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Notification marked as read"})
+}
+
+// MarkAllRead marks every unread notification of the user as read
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := database.NotificationsCollection.UpdateMany(ctx,
+		bson.M{"userId": userID, "read": false},
+		bson.M{"$set": bson.M{"read": true, "readAt": time.Now()}},
+	)
+	if err != nil {
+		http.Error(w, "Error updating notifications", http.StatusInternalServerError)
+		return
+	}
+	if result.ModifiedCount > 0 {
+		go h.pushUnread(userID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"updated": result.ModifiedCount})
+}
+
+// DeleteNotification removes one of the user's notifications
+func (h *NotificationHandler) DeleteNotification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := contextUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	notificationID, err := notificationIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := database.NotificationsCollection.DeleteOne(ctx, bson.M{"_id": notificationID, "userId": userID})
+	if err != nil {
+		http.Error(w, "Error deleting notification", http.StatusInternalServerError)
+		return
+	}
+	if result.DeletedCount == 0 {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+	go h.pushUnread(userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Notification deleted successfully"})
+}
+
+// Broadcast puts an announcement in the inbox of every user, or of the users
+// holding a role
+func (h *NotificationHandler) Broadcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.BroadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Title == "" || req.Body == "" {
+		http.Error(w, "Title and body required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Title) > 200 || len(req.Body) > 5000 {
+		http.Error(w, "Title or body too long", http.StatusBadRequest)
+		return
+	}
+	// The link is rendered in the app, so only allow app paths and web URLs
+	if req.Link != "" && !strings.HasPrefix(req.Link, "/") &&
+		!strings.HasPrefix(req.Link, "https://") && !strings.HasPrefix(req.Link, "http://") {
+		http.Error(w, "Link must be a path or an http(s) URL", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	target := models.AuditTarget{Type: "users", Name: "everyone"}
+	if req.Role != "" {
+		if err := database.RolesCollection.FindOne(ctx, bson.M{"name": req.Role}).Err(); err != nil {
+			http.Error(w, "Role not found", http.StatusBadRequest)
+			return
+		}
+		filter["roles"] = req.Role
+		if req.Role == models.DefaultRoleName {
+			// Users without any role hold the default role
+			filter = bson.M{"$or": bson.A{
+				bson.M{"roles": req.Role},
+				bson.M{"roles": bson.M{"$exists": false}},
+				bson.M{"roles": bson.A{}},
+			}}
+		}
+		target = models.AuditTarget{Type: "role", Name: req.Role}
+	}
+
+	cursor, err := database.UsersCollection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		http.Error(w, "Error fetching users", http.StatusInternalServerError)
+		return
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		http.Error(w, "Error decoding users", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	notifications := make([]models.Notification, len(users))
+	documents := make([]interface{}, len(users))
+	for i, user := range users {
+		notifications[i] = models.Notification{
+			ID:        primitive.NewObjectID(),
+			UserID:    user.ID,
+			Event:     models.NotificationAnnouncement,
+			Title:     req.Title,
+			Body:      req.Body,
+			Link:      req.Link,
+			CreatedAt: now,
+		}
+		documents[i] = notifications[i]
+	}
+	if len(documents) > 0 {
+		if _, err := database.NotificationsCollection.InsertMany(ctx, documents); err != nil {
+			http.Error(w, "Error sending announcement", http.StatusInternalServerError)
+			return
+		}
+	}
+	for i := range notifications {
+		events.Publish(events.NotificationCreated, &notifications[i])
+	}
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditBroadcast,
+		Target:  target,
+		Changes: []models.AuditChange{{Field: "title", After: req.Title}},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Announcement sent",
+		"recipients": len(notifications),
+	})
+}
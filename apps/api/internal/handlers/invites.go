@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+)
+
+// errInviteNotRedeemable is returned for unknown, expired, exhausted or revoked codes
+var errInviteNotRedeemable = errors.New("invalid or expired invite code")
+
+// inviteCodeLength gives 31^12 possible codes, far beyond what the
+// registration throttle lets anyone guess
+const inviteCodeLength = 12
+
+// InviteHandler handles invite management requests
+type InviteHandler struct {
+	config *config.Config
+}
+
+// NewInviteHandler creates a new InviteHandler
+func NewInviteHandler(cfg *config.Config) *InviteHandler {
+	return &InviteHandler{config: cfg}
+}
+
+// generateInviteCode returns a random code using the recovery code alphabet
+func generateInviteCode() (string, error) {
+	b := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = recoveryCodeAlphabet[int(b[i])%len(recoveryCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// validateQuota rejects negative limits and quotas without a period
+func validateQuota(quota *models.RequestQuota) error {
+	if quota == nil {
+		return nil
+	}
+	if quota.MovieLimit < 0 || quota.SeasonLimit < 0 {
+		return errors.New("quota limits cannot be negative")
+	}
+	if quota.Days < 1 {
+		return errors.New("quota days must be at least 1")
+	}
+	return nil
+}
+
+// ListInvites returns every invite, newest first, optionally filtered by ?status=
+func (h *InviteHandler) ListInvites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := database.InvitesCollection.Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		http.Error(w, "Error fetching invites", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var all []models.Invite
+	if err := cursor.All(ctx, &all); err != nil {
+		http.Error(w, "Error decoding invites", http.StatusInternalServerError)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	now := time.Now()
+	invites := []models.Invite{}
+	for _, invite := range all {
+		invite.Status = invite.CurrentStatus(now)
+		if status != "" && invite.Status != status {
+			continue
+		}
+		if invite.Redemptions == nil {
+			invite.Redemptions = []models.InviteRedemption{}
+		}
+		invites = append(invites, invite)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invites)
+}
+
+// GetInvite returns one invite with the accounts registered through it
+func (h *InviteHandler) GetInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	inviteID, err := primitive.ObjectIDFromHex(strings.TrimPrefix(r.URL.Path, "/api/invites/"))
+	if err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var invite models.Invite
+	if err := database.InvitesCollection.FindOne(ctx, bson.M{"_id": inviteID}).Decode(&invite); err != nil {
+		http.Error(w, "Invite not found", http.StatusNotFound)
+		return
+	}
+	invite.Status = invite.CurrentStatus(time.Now())
+	if invite.Redemptions == nil {
+		invite.Redemptions = []models.InviteRedemption{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invite)
+}
+
+// CreateInvite generates a new invite code
+func (h *InviteHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.CreateInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.MaxUses < 0 || req.ExpiresInHours < 0 {
+		http.Error(w, "maxUses and expiresInHours cannot be negative", http.StatusBadRequest)
+		return
+	}
+	if err := validateQuota(req.Quota); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Invites cannot hand out more than their creator holds
+	if req.Role != "" {
+		if err := validateRoleAssignment(ctx, r, []string{req.Role}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		http.Error(w, "Error generating invite code", http.StatusInternalServerError)
+		return
+	}
+
+	userID, _ := r.Context().Value("userID").(string)
+	creatorID, _ := primitive.ObjectIDFromHex(userID)
+	creator, _ := r.Context().Value("username").(string)
+
+	now := time.Now()
+	invite := models.Invite{
+		Code:        code,
+		Description: req.Description,
+		Role:        req.Role,
+		Quota:       req.Quota,
+		MaxUses:     req.MaxUses,
+		CreatedBy:   creator,
+		CreatedByID: creatorID,
+		CreatedAt:   now,
+	}
+	if req.ExpiresInHours > 0 {
+		expiresAt := now.Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	result, err := database.InvitesCollection.InsertOne(ctx, invite)
+	if err != nil {
+		log.Printf("Error creating invite: %v", err)
+		http.Error(w, "Error creating invite", http.StatusInternalServerError)
+		return
+	}
+	invite.ID = result.InsertedID.(primitive.ObjectID)
+	invite.Status = invite.CurrentStatus(now)
+	invite.Redemptions = []models.InviteRedemption{}
+
+	changes := []models.AuditChange{{Field: "maxUses", After: invite.MaxUses}}
+	if invite.Role != "" {
+		changes = append(changes, models.AuditChange{Field: "role", After: invite.Role})
+	}
+	if invite.ExpiresAt != nil {
+		changes = append(changes, models.AuditChange{Field: "expiresAt", After: *invite.ExpiresAt})
+	}
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditInviteCreate,
+		Target:  models.AuditTarget{Type: "invite", ID: invite.ID.Hex(), Name: invite.Description},
+		Changes: changes,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
+}
+
+// RevokeInvite stops an invite from being redeemed; the record is kept so
+// admins can still see who registered with it
+func (h *InviteHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	inviteID, err := primitive.ObjectIDFromHex(strings.TrimPrefix(r.URL.Path, "/api/invites/"))
+	if err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revoker, _ := r.Context().Value("username").(string)
+	var invite models.Invite
+	err = database.InvitesCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": inviteID, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true, "revokedBy": revoker, "revokedAt": time.Now()}},
+	).Decode(&invite)
+	if err == mongo.ErrNoDocuments {
+		count, err := database.InvitesCollection.CountDocuments(ctx, bson.M{"_id": inviteID})
+		if err != nil {
+			http.Error(w, "Error revoking invite", http.StatusInternalServerError)
+			return
+		}
+		if count == 0 {
+			http.Error(w, "Invite not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Invite is already revoked", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error revoking invite", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditInviteRevoke,
+		Target:  models.AuditTarget{Type: "invite", ID: invite.ID.Hex(), Name: invite.Description},
+		Changes: []models.AuditChange{{Field: "revoked", Before: false, After: true}},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Invite revoked successfully"})
+}
+
+// redeemInvite consumes one use of a valid invite and returns it
+func redeemInvite(ctx context.Context, code string) (*models.Invite, error) {
+	now := time.Now()
+	filter := bson.M{
+		"code":    code,
+		"revoked": false,
+		"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.M{"expiresAt": bson.M{"$exists": false}},
+				bson.M{"expiresAt": bson.M{"$gt": now}},
+			}},
+			bson.M{"$or": bson.A{
+				bson.M{"maxUses": 0},
+				bson.M{"$expr": bson.M{"$lt": bson.A{"$uses", "$maxUses"}}},
+			}},
+		},
+	}
+
+	var invite models.Invite
+	err := database.InvitesCollection.FindOneAndUpdate(ctx, filter,
+		bson.M{"$inc": bson.M{"uses": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&invite)
+	if err == mongo.ErrNoDocuments {
+		return nil, errInviteNotRedeemable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// releaseInvite gives back a use when the account could not be created
+func releaseInvite(ctx context.Context, inviteID primitive.ObjectID) {
+	if _, err := database.InvitesCollection.UpdateOne(ctx, bson.M{"_id": inviteID},
+		bson.M{"$inc": bson.M{"uses": -1}}); err != nil {
+		log.Printf("Error releasing invite %s: %v", inviteID.Hex(), err)
+	}
+}
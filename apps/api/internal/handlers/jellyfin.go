@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,7 +12,10 @@ import (
 	"net/url"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+
 	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
 	"jellystreaming/internal/models"
 )
 
@@ -23,62 +29,185 @@ func NewJellyfinHandler(cfg *config.Config) *JellyfinHandler {
 	return &JellyfinHandler{config: cfg}
 }
 
-// fetchMovies fetches movies from Jellyfin
-func (h *JellyfinHandler) fetchMovies(limit int, startIndex int) (*models.JellyfinResponse, error) {
-	url := fmt.Sprintf(
-		"%s/Users/%s/Items?SortBy=DateCreated,SortName,ProductionYear&SortOrder=Descending&IncludeItemTypes=Movie&Recursive=true&Fields=PrimaryImageAspectRatio,MediaSourceCount&ImageTypeLimit=1&EnableImageTypes=Primary,Backdrop,Banner,Thumb&StartIndex=%d&ParentId=%s&Limit=%d",
-		h.config.JellyfinURL,
-		h.config.JellyfinUserID,
-		startIndex,
-		h.config.ParentID,
-		limit,
-	)
+// jellyfinGetJSON performs a GET request against the Jellyfin API with the
+// server API key and decodes the JSON response into out
+func jellyfinGetJSON(cfg *config.Config, path string, query url.Values, out interface{}) error {
+	jellyfinURL := cfg.JellyfinURL + path
+	if len(query) > 0 {
+		jellyfinURL += "?" + query.Encode()
+	}
 
 	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest("GET", jellyfinURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+		return fmt.Errorf("error creating request: %v", err)
 	}
 
-	if h.config.JellyfinAPIKey != "" {
-		req.Header.Set("X-Emby-Token", h.config.JellyfinAPIKey)
+	if cfg.JellyfinAPIKey != "" {
+		req.Header.Set("X-Emby-Token", cfg.JellyfinAPIKey)
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+		return fmt.Errorf("error making request: %v", err)
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("jellyfin API returned status %d: %s", resp.StatusCode, string(body))
+		return &jellyfinStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("error parsing response: %v", err)
+	}
+
+	return nil
+}
+
+// jellyfinPostJSON performs a POST request against the Jellyfin API with the
+// server API key, sending body as JSON. The response body is discarded.
+func jellyfinPostJSON(cfg *config.Config, path string, query url.Values, body interface{}) error {
+	return jellyfinPostJSONAs(cfg, cfg.JellyfinAPIKey, path, query, body)
+}
+
+// jellyfinPostJSONAs is jellyfinPostJSON with a Jellyfin access token, such
+// as a user's own token in place of the server API key
+func jellyfinPostJSONAs(cfg *config.Config, token, path string, query url.Values, body interface{}) error {
+	jellyfinURL := cfg.JellyfinURL + path
+	if len(query) > 0 {
+		jellyfinURL += "?" + query.Encode()
+	}
+
+	jsonData, err := json.Marshal(body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
+		return fmt.Errorf("error encoding request: %v", err)
 	}
 
-	var jellyfinResp models.JellyfinResponse
-	if err := json.Unmarshal(body, &jellyfinResp); err != nil {
-		return nil, fmt.Errorf("error parsing response: %v", err)
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequest("POST", jellyfinURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Emby-Token", token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &jellyfinStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	return nil
+}
+
+// jellyfinStatusError is returned when Jellyfin answers with a non-200 status
+type jellyfinStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *jellyfinStatusError) Error() string {
+	return fmt.Sprintf("jellyfin API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// errNoJellyfinUser is returned for unlinked accounts when no shared
+// JELLYFIN_USER_ID is configured
+var errNoJellyfinUser = errors.New("no Jellyfin user is linked to this account")
+
+// jellyfinUserID returns the Jellyfin user the caller acts as
+func (h *JellyfinHandler) jellyfinUserID(r *http.Request) (string, error) {
+	return resolveJellyfinUserID(h.config, r)
+}
+
+// resolveJellyfinUserID returns the caller's linked Jellyfin account, or the
+// shared JELLYFIN_USER_ID for unlinked accounts
+func resolveJellyfinUserID(cfg *config.Config, r *http.Request) (string, error) {
+	userID, _ := r.Context().Value("userID").(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("error loading user: %v", err)
+	}
+
+	if user.JellyfinUserID != "" {
+		return user.JellyfinUserID, nil
+	}
+	if cfg.JellyfinUserID != "" {
+		return cfg.JellyfinUserID, nil
 	}
 
+	return "", errNoJellyfinUser
+}
+
+// fetchMovies fetches a page of movies from the given libraries as the given
+// Jellyfin user
+func (h *JellyfinHandler) fetchMovies(jellyfinUserID string, libraryIDs []string, q libraryQuery) (*models.JellyfinResponse, error) {
+	raws, total, err := fetchLibraryPage(h.config, jellyfinUserID, "Movie", "PrimaryImageAspectRatio,MediaSourceCount,Genres", libraryIDs, q)
+	if err != nil {
+		return nil, err
+	}
+
+	jellyfinResp := models.JellyfinResponse{Items: make([]models.JellyfinMovie, len(raws))}
+	for i, raw := range raws {
+		if err := json.Unmarshal(raw, &jellyfinResp.Items[i]); err != nil {
+			return nil, fmt.Errorf("error parsing response: %v", err)
+		}
+	}
+
+	jellyfinResp.TotalRecordCount = total
+	jellyfinResp.StartIndex = q.StartIndex
+	jellyfinResp.Page = q.StartIndex/q.Limit + 1
+	jellyfinResp.Limit = q.Limit
+	jellyfinResp.NextCursor = q.nextCursor(len(raws), total)
 	return &jellyfinResp, nil
 }
 
-// GetMovies handles movie list requests
+// GetMovies handles movie list requests; see parseLibraryQuery for the
+// paging, sorting and filter parameters
 func (h *JellyfinHandler) GetMovies(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	limit := 100
-	startIndex := 0
+	q, err := parseLibraryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jellyfinUserID, err := h.jellyfinUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	libraryIDs, err := requestedLibraries(h.config, r, models.MediaTypeMovie)
+	if err == errLibraryNotEnabled {
+		http.Error(w, "Library not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error loading library settings", http.StatusInternalServerError)
+		return
+	}
 
-	movies, err := h.fetchMovies(limit, startIndex)
+	movies, err := h.fetchMovies(jellyfinUserID, libraryIDs, q)
 	if err != nil {
 		log.Printf("Error fetching movies: %v", err)
 		http.Error(w, fmt.Sprintf("Error fetching movies: %v", err), http.StatusInternalServerError)
@@ -96,11 +225,18 @@ func (h *JellyfinHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	jellyfinUserID, err := h.jellyfinUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// The API key stays on the server; media is served through /api/stream
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"jellyfinUrl": h.config.JellyfinURL,
-		"userId":      h.config.JellyfinUserID,
-		"apiKey":      h.config.JellyfinAPIKey,
+		"userId":      jellyfinUserID,
+		"streamUrl":   "/api/stream",
 	})
 }
 
@@ -117,9 +253,15 @@ func (h *JellyfinHandler) SearchMovies(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	jellyfinUserID, err := h.jellyfinUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	log.Printf("Searching Jellyfin for: %s", title)
 
-	baseURL := fmt.Sprintf("%s/Users/%s/Items", h.config.JellyfinURL, h.config.JellyfinUserID)
+	baseURL := fmt.Sprintf("%s/Users/%s/Items", h.config.JellyfinURL, jellyfinUserID)
 	jellyfinURL, err := url.Parse(baseURL)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error parsing URL: %v", err), http.StatusInternalServerError)
@@ -133,7 +275,10 @@ func (h *JellyfinHandler) SearchMovies(w http.ResponseWriter, r *http.Request) {
 	query.Set("Fields", "PrimaryImageAspectRatio,ProductionYear,ProviderIds")
 	query.Set("ImageTypeLimit", "1")
 	query.Set("EnableImageTypes", "Primary,Backdrop")
-	query.Set("ParentId", h.config.ParentID)
+	// Jellyfin searches one library or all of them
+	if libraryIDs, err := requestedLibraries(h.config, r, models.MediaTypeMovie); err == nil && len(libraryIDs) == 1 {
+		query.Set("ParentId", libraryIDs[0])
+	}
 	query.Set("Limit", "20")
 	jellyfinURL.RawQuery = query.Encode()
 
@@ -185,68 +330,123 @@ func (h *JellyfinHandler) SearchMovies(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(jellyfinResp)
 }
 
-// fetchSeries fetches TV series from Jellyfin
-func (h *JellyfinHandler) fetchSeries(limit int, startIndex int) (*models.JellyfinSeriesResponse, error) {
-	url := fmt.Sprintf(
-		"%s/Users/%s/Items?SortBy=DateCreated,SortName&SortOrder=Descending&IncludeItemTypes=Series&Recursive=true&Fields=PrimaryImageAspectRatio,ProviderIds&ImageTypeLimit=1&EnableImageTypes=Primary,Backdrop,Banner,Thumb&StartIndex=%d&ParentId=%s&Limit=%d",
-		h.config.JellyfinURL,
-		h.config.JellyfinUserID,
-		startIndex,
-		h.config.TVShowsParentID,
-		limit,
-	)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
+// fetchSeries fetches a page of TV series from the given libraries as the
+// given Jellyfin user
+func (h *JellyfinHandler) fetchSeries(jellyfinUserID string, libraryIDs []string, q libraryQuery) (*models.JellyfinSeriesResponse, error) {
+	raws, total, err := fetchLibraryPage(h.config, jellyfinUserID, "Series", "PrimaryImageAspectRatio,ProviderIds,Genres", libraryIDs, q)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+		return nil, err
 	}
 
-	if h.config.JellyfinAPIKey != "" {
-		req.Header.Set("X-Emby-Token", h.config.JellyfinAPIKey)
+	jellyfinResp := models.JellyfinSeriesResponse{Items: make([]models.JellyfinSeries, len(raws))}
+	for i, raw := range raws {
+		if err := json.Unmarshal(raw, &jellyfinResp.Items[i]); err != nil {
+			return nil, fmt.Errorf("error parsing response: %v", err)
+		}
 	}
 
-	resp, err := client.Do(req)
+	jellyfinResp.TotalRecordCount = total
+	jellyfinResp.StartIndex = q.StartIndex
+	jellyfinResp.Page = q.StartIndex/q.Limit + 1
+	jellyfinResp.Limit = q.Limit
+	jellyfinResp.NextCursor = q.nextCursor(len(raws), total)
+	return &jellyfinResp, nil
+}
+
+// GetSeries handles TV series list requests; see parseLibraryQuery for the
+// paging, sorting and filter parameters
+func (h *JellyfinHandler) GetSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := parseLibraryQuery(r)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(q.Containers) > 0 {
+		http.Error(w, "container filter applies to movies only", http.StatusBadRequest)
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("jellyfin API returned status %d: %s", resp.StatusCode, string(body))
+	jellyfinUserID, err := h.jellyfinUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	libraryIDs, err := requestedLibraries(h.config, r, models.MediaTypeTV)
+	if err == errLibraryNotEnabled {
+		http.Error(w, "Library not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
+		http.Error(w, "Error loading library settings", http.StatusInternalServerError)
+		return
 	}
 
-	var jellyfinResp models.JellyfinSeriesResponse
-	if err := json.Unmarshal(body, &jellyfinResp); err != nil {
-		return nil, fmt.Errorf("error parsing response: %v", err)
+	series, err := h.fetchSeries(jellyfinUserID, libraryIDs, q)
+	if err != nil {
+		log.Printf("Error fetching series: %v", err)
+		http.Error(w, fmt.Sprintf("Error fetching series: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	return &jellyfinResp, nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
 }
 
-// GetSeries handles TV series list requests
-func (h *JellyfinHandler) GetSeries(w http.ResponseWriter, r *http.Request) {
+// ListUsers returns all Jellyfin users and the accounts linked to them (admin only)
+func (h *JellyfinHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	limit := 100
-	startIndex := 0
+	var jellyfinUsers []models.JellyfinUser
+	if err := jellyfinGetJSON(h.config, "/Users", nil, &jellyfinUsers); err != nil {
+		log.Printf("Error fetching Jellyfin users: %v", err)
+		http.Error(w, fmt.Sprintf("Error fetching Jellyfin users: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	series, err := h.fetchSeries(limit, startIndex)
+	cursor, err := database.UsersCollection.Find(ctx, bson.M{"jellyfinUserId": bson.M{"$exists": true}})
 	if err != nil {
-		log.Printf("Error fetching series: %v", err)
-		http.Error(w, fmt.Sprintf("Error fetching series: %v", err), http.StatusInternalServerError)
+		http.Error(w, "Error fetching users", http.StatusInternalServerError)
 		return
 	}
+	defer cursor.Close(ctx)
+
+	var linkedUsers []models.User
+	if err := cursor.All(ctx, &linkedUsers); err != nil {
+		http.Error(w, "Error decoding users", http.StatusInternalServerError)
+		return
+	}
+
+	linked := make(map[string]models.User, len(linkedUsers))
+	for _, user := range linkedUsers {
+		linked[user.JellyfinUserID] = user
+	}
+
+	responses := make([]models.JellyfinUserMapping, len(jellyfinUsers))
+	for i, jellyfinUser := range jellyfinUsers {
+		responses[i] = models.JellyfinUserMapping{
+			JellyfinUserID:  jellyfinUser.Id,
+			JellyfinName:    jellyfinUser.Name,
+			IsAdministrator: jellyfinUser.Policy.IsAdministrator,
+			IsDisabled:      jellyfinUser.Policy.IsDisabled,
+		}
+		if user, ok := linked[jellyfinUser.Id]; ok {
+			responses[i].LinkedUserID = user.ID.Hex()
+			responses[i].LinkedUsername = user.Username
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(series)
+	json.NewEncoder(w).Encode(responses)
 }
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+	"jellystreaming/internal/secretbox"
+)
+
+// jellyfinClientAuthorization identifies this API to Jellyfin when signing a
+// user in. Jellyfin keeps one session per device, so each username gets its
+// own device ID and the sessions of different users stay apart.
+func jellyfinClientAuthorization(username string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(username)))
+	return fmt.Sprintf(`MediaBrowser Client="JellyStreaming", Device="JellyStreaming API", DeviceId="jellystreaming-%x", Version="2.2.0"`, sum[:8])
+}
+
+// jellyfinTokenKey is the key Jellyfin access tokens are encrypted with in
+// the database
+func jellyfinTokenKey(cfg *config.Config) []byte {
+	return secretbox.Key(cfg.JWTSecret, "jellyfin-token")
+}
+
+// errJellyfinAccountConflict is returned when a Jellyfin user cannot be linked
+// because the username belongs to another account
+var errJellyfinAccountConflict = errors.New("username belongs to another account")
+
+// authenticateWithJellyfin checks credentials with Jellyfin's AuthenticateByName
+func (h *AuthHandler) authenticateWithJellyfin(ctx context.Context, username, password string) (*models.JellyfinAuthenticationResult, error) {
+	jsonData, err := json.Marshal(map[string]string{
+		"Username": username,
+		"Pw":       password,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %v", err)
+	}
+
+	jellyfinURL := fmt.Sprintf("%s/Users/AuthenticateByName", h.config.JellyfinURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", jellyfinURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	authorization := jellyfinClientAuthorization(username)
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("X-Emby-Authorization", authorization)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, errInvalidCredentials
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jellyfin API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result models.JellyfinAuthenticationResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	if result.User.Id == "" {
+		return nil, errors.New("jellyfin did not return a user")
+	}
+
+	return &result, nil
+}
+
+// logoutJellyfinSession ends a Jellyfin session created by AuthenticateByName
+func (h *AuthHandler) logoutJellyfinSession(accessToken string) {
+	jellyfinURL := fmt.Sprintf("%s/Sessions/Logout", h.config.JellyfinURL)
+	req, err := http.NewRequest("POST", jellyfinURL, nil)
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("X-Emby-Token", accessToken)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Error ending Jellyfin session: %v", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// loginWithJellyfin authenticates against Jellyfin and returns the linked
+// local user, creating or linking one on first login
+func (h *AuthHandler) loginWithJellyfin(ctx context.Context, username, password string) (*models.User, error) {
+	result, err := h.authenticateWithJellyfin(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.jellyfinAccount(ctx, result.User)
+	if err != nil {
+		if result.AccessToken != "" {
+			go h.logoutJellyfinSession(result.AccessToken)
+		}
+		return nil, err
+	}
+	h.keepJellyfinToken(ctx, user, result.AccessToken)
+	return user, nil
+}
+
+// keepJellyfinToken stores the access token of a Jellyfin login, encrypted,
+// so playback can be reported to Jellyfin as the user. A token that cannot
+// be stored is ended instead. A later login from the same device replaces
+// the token in Jellyfin too.
+func (h *AuthHandler) keepJellyfinToken(ctx context.Context, user *models.User, accessToken string) {
+	if accessToken == "" {
+		return
+	}
+
+	sealed, err := secretbox.Seal(jellyfinTokenKey(h.config), accessToken)
+	if err == nil {
+		_, err = database.UsersCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+			"$set": bson.M{"jellyfinToken": sealed},
+		})
+	}
+	if err != nil {
+		log.Printf("Error storing Jellyfin token for %s: %v", user.Username, err)
+		go h.logoutJellyfinSession(accessToken)
+		return
+	}
+	user.JellyfinToken = sealed
+}
+
+// jellyfinAccount returns the local user of an authenticated Jellyfin user,
+// creating or linking one on first login
+func (h *AuthHandler) jellyfinAccount(ctx context.Context, jellyfinUser models.JellyfinUser) (*models.User, error) {
+	isAdmin := jellyfinUser.Policy.IsAdministrator
+
+	var user models.User
+	err := database.UsersCollection.FindOne(ctx, bson.M{"jellyfinUserId": jellyfinUser.Id}).Decode(&user)
+	if err == nil {
+		if user.IsAdmin && !isAdmin {
+			// As with deleting users, the last admin is never demoted
+			adminCount, err := database.UsersCollection.CountDocuments(ctx, bson.M{"isAdmin": true})
+			if err != nil {
+				return nil, err
+			}
+			if adminCount <= 1 {
+				log.Printf("Not demoting %s, the last admin user, although Jellyfin no longer lists them as an administrator", user.Username)
+				isAdmin = true
+			}
+		}
+		if user.IsAdmin != isAdmin {
+			// Mirror Jellyfin's administrator flag; a demotion ends existing sessions
+			_, err := database.UsersCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+				"$set": bson.M{"isAdmin": isAdmin, "updatedAt": time.Now()},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if user.IsAdmin && !isAdmin {
+				if _, err := database.RevokeUserSessions(ctx, user.ID, ""); err != nil {
+					log.Printf("Error revoking sessions for demoted user %s: %v", user.Username, err)
+				}
+			}
+			user.IsAdmin = isAdmin
+		}
+		return &user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// First Jellyfin login. An account with the same name is only linked when
+	// JELLYFIN_LINK_BY_USERNAME is set, and never one with a local password or
+	// MFA: anyone able to create a Jellyfin user could otherwise take it over.
+	// Administrators link other accounts through their jellyfinUserId.
+	if h.config.JellyfinLinkByUsername {
+		err := database.UsersCollection.FindOneAndUpdate(ctx,
+			bson.M{
+				"username":       jellyfinUser.Name,
+				"jellyfinUserId": bson.M{"$exists": false},
+				"password":       bson.M{"$in": bson.A{"", nil}},
+				"mfaEnabled":     bson.M{"$ne": true},
+			},
+			bson.M{"$set": bson.M{
+				"jellyfinUserId": jellyfinUser.Id,
+				"isAdmin":        isAdmin,
+				"updatedAt":      time.Now(),
+			}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&user)
+		if err == nil {
+			log.Printf("Linked existing user %s to Jellyfin user %s", user.Username, jellyfinUser.Id)
+			return &user, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+	}
+
+	count, err := database.UsersCollection.CountDocuments(ctx, bson.M{"username": jellyfinUser.Name})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errJellyfinAccountConflict
+	}
+
+	user = models.User{
+		Username:       jellyfinUser.Name,
+		IsAdmin:        isAdmin,
+		AuthProvider:   models.AuthProviderJellyfin,
+		JellyfinUserID: jellyfinUser.Id,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+
+	insertResult, err := database.UsersCollection.InsertOne(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	user.ID = insertResult.InsertedID.(primitive.ObjectID)
+
+	log.Printf("Provisioned user %s from Jellyfin user %s", user.Username, jellyfinUser.Id)
+	return &user, nil
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"jellystreaming/internal/config"
+)
+
+const (
+	defaultLibraryPageSize = 100
+	maxLibraryPageSize     = 500
+)
+
+// librarySortFields maps the sortBy values accepted by the library endpoints
+// to Jellyfin sort fields; ties fall back to the name
+var librarySortFields = map[string]string{
+	"name":      "SortName",
+	"dateAdded": "DateCreated,SortName",
+	"year":      "ProductionYear,SortName",
+	"rating":    "CommunityRating,SortName",
+	"runtime":   "Runtime,SortName",
+}
+
+// libraryQuery is a page of a Jellyfin library listing with its sort order
+// and filters
+type libraryQuery struct {
+	StartIndex int
+	Limit      int
+	SortKey    string // sortBy as requested
+	SortBy     string // Jellyfin sort fields
+	SortOrder  string // Ascending or Descending
+
+	Genres          []string
+	YearFrom        int
+	YearTo          int
+	OfficialRatings []string
+	Played          string // "true", "false" or empty for both
+	Containers      []string
+}
+
+// listParam splits a comma-separated query parameter, dropping empty values
+func listParam(r *http.Request, name string) []string {
+	var values []string
+	for _, value := range strings.Split(r.URL.Query().Get(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// parseLibraryQuery reads the paging, sorting and filter parameters of the
+// library endpoints:
+//
+//	page, limit         1-based page of limit items (default 100, max 500)
+//	cursor              NextCursor of a previous page; takes precedence over page
+//	sortBy              name, dateAdded (default), year, rating or runtime
+//	sortOrder           asc or desc (default)
+//	genre               comma-separated genres, matching any
+//	yearFrom, yearTo    production year range, inclusive
+//	officialRating      comma-separated ratings, matching any (PG-13, TV-MA, ...)
+//	watched             true or false
+//	container           comma-separated containers, matching any (mkv, mp4, ...)
+func parseLibraryQuery(r *http.Request) (libraryQuery, error) {
+	query := r.URL.Query()
+	q := libraryQuery{Limit: defaultLibraryPageSize}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("limit must be a positive number")
+		}
+		if n > maxLibraryPageSize {
+			n = maxLibraryPageSize
+		}
+		q.Limit = n
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("invalid cursor")
+		}
+		q.StartIndex = n
+	} else if page := query.Get("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("page must be a positive number")
+		}
+		q.StartIndex = (n - 1) * q.Limit
+	}
+
+	sortBy := query.Get("sortBy")
+	if sortBy == "" {
+		sortBy = "dateAdded"
+	}
+	fields, ok := librarySortFields[sortBy]
+	if !ok {
+		return q, fmt.Errorf("sortBy must be name, dateAdded, year, rating or runtime")
+	}
+	q.SortKey, q.SortBy = sortBy, fields
+
+	switch query.Get("sortOrder") {
+	case "", "desc":
+		q.SortOrder = "Descending"
+	case "asc":
+		q.SortOrder = "Ascending"
+	default:
+		return q, fmt.Errorf("sortOrder must be asc or desc")
+	}
+
+	for _, bound := range []struct {
+		name string
+		out  *int
+	}{{"yearFrom", &q.YearFrom}, {"yearTo", &q.YearTo}} {
+		if value := query.Get(bound.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 9999 {
+				return q, fmt.Errorf("%s must be a year", bound.name)
+			}
+			*bound.out = n
+		}
+	}
+	if q.YearFrom > 0 && q.YearTo > 0 && q.YearFrom > q.YearTo {
+		return q, fmt.Errorf("yearFrom must not be after yearTo")
+	}
+
+	switch watched := query.Get("watched"); watched {
+	case "", "true", "false":
+		q.Played = watched
+	default:
+		return q, fmt.Errorf("watched must be true or false")
+	}
+
+	q.Genres = listParam(r, "genre")
+	q.OfficialRatings = listParam(r, "officialRating")
+	q.Containers = listParam(r, "container")
+	return q, nil
+}
+
+// values builds the Jellyfin /Items query for the listing, without paging
+func (q libraryQuery) values(itemType, parentID, fields string) url.Values {
+	values := url.Values{
+		"IncludeItemTypes": {itemType},
+		"Recursive":        {"true"},
+		"SortBy":           {q.SortBy},
+		"SortOrder":        {q.SortOrder},
+		"Fields":           {fields},
+		"ImageTypeLimit":   {"1"},
+		"EnableImageTypes": {"Primary,Backdrop,Banner,Thumb"},
+	}
+	if parentID != "" {
+		values.Set("ParentId", parentID)
+	}
+
+	// Jellyfin takes pipe-separated lists for these
+	if len(q.Genres) > 0 {
+		values.Set("Genres", strings.Join(q.Genres, "|"))
+	}
+	if len(q.OfficialRatings) > 0 {
+		values.Set("OfficialRatings", strings.Join(q.OfficialRatings, "|"))
+	}
+	if q.YearFrom > 0 {
+		values.Set("MinPremiereDate", fmt.Sprintf("%04d-01-01T00:00:00Z", q.YearFrom))
+	}
+	if q.YearTo > 0 {
+		values.Set("MaxPremiereDate", fmt.Sprintf("%04d-12-31T23:59:59Z", q.YearTo))
+	}
+	if q.Played != "" {
+		values.Set("IsPlayed", q.Played)
+	}
+	return values
+}
+
+// matchesContainer reports whether a Jellyfin container ("mkv", or a list
+// such as "mov,mp4,m4a") is one of the requested containers
+func (q libraryQuery) matchesContainer(container string) bool {
+	for _, have := range strings.Split(container, ",") {
+		for _, want := range q.Containers {
+			if strings.EqualFold(strings.TrimSpace(have), want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nextCursor returns the cursor of the page after the one starting at
+// StartIndex, or an empty string on the last page
+func (q libraryQuery) nextCursor(returned, total int) string {
+	next := q.StartIndex + returned
+	if returned == 0 || next >= total {
+		return ""
+	}
+	return strconv.Itoa(next)
+}
+
+// libraryItem is an item of a listing merged from several libraries, with
+// the fields it is sorted and filtered on
+type libraryItem struct {
+	raw  json.RawMessage
+	keys struct {
+		SortName        string
+		DateCreated     string
+		ProductionYear  int
+		CommunityRating float64
+		RunTimeTicks    int64
+		Container       string
+	}
+}
+
+// compare orders two items by the query's sort key, then by name, in
+// ascending order
+func (q libraryQuery) compare(a, b *libraryItem) int {
+	switch q.SortKey {
+	case "dateAdded":
+		if c := strings.Compare(a.keys.DateCreated, b.keys.DateCreated); c != 0 {
+			return c
+		}
+	case "year":
+		if a.keys.ProductionYear != b.keys.ProductionYear {
+			return a.keys.ProductionYear - b.keys.ProductionYear
+		}
+	case "rating":
+		if a.keys.CommunityRating != b.keys.CommunityRating {
+			if a.keys.CommunityRating < b.keys.CommunityRating {
+				return -1
+			}
+			return 1
+		}
+	case "runtime":
+		if a.keys.RunTimeTicks != b.keys.RunTimeTicks {
+			if a.keys.RunTimeTicks < b.keys.RunTimeTicks {
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(strings.ToLower(a.keys.SortName), strings.ToLower(b.keys.SortName))
+}
+
+// fetchLibraryPage returns the page q selects from the itemType items of the
+// given libraries (every library when parentIDs is empty), as raw Jellyfin
+// items, with the total number of matching items.
+//
+// A single library is paged by Jellyfin. Several libraries are each asked
+// for their first StartIndex+Limit items, which are merged and paged here.
+// Jellyfin cannot filter on containers, so with Containers set every item is
+// fetched and filtered here.
+func fetchLibraryPage(cfg *config.Config, jellyfinUserID, itemType, fields string, parentIDs []string, q libraryQuery) ([]json.RawMessage, int, error) {
+	path := fmt.Sprintf("/Users/%s/Items", jellyfinUserID)
+	if len(parentIDs) == 0 {
+		parentIDs = []string{""}
+	}
+
+	var page struct {
+		Items            []json.RawMessage `json:"Items"`
+		TotalRecordCount int               `json:"TotalRecordCount"`
+	}
+
+	if len(parentIDs) == 1 && len(q.Containers) == 0 {
+		values := q.values(itemType, parentIDs[0], fields)
+		values.Set("StartIndex", strconv.Itoa(q.StartIndex))
+		values.Set("Limit", strconv.Itoa(q.Limit))
+		if err := jellyfinGetJSON(cfg, path, values, &page); err != nil {
+			return nil, 0, err
+		}
+		return page.Items, page.TotalRecordCount, nil
+	}
+
+	var items []*libraryItem
+	total := 0
+	for _, parentID := range parentIDs {
+		values := q.values(itemType, parentID, fields+",SortName,DateCreated")
+		if len(q.Containers) == 0 {
+			values.Set("Limit", strconv.Itoa(q.StartIndex+q.Limit))
+		}
+		page.Items, page.TotalRecordCount = nil, 0
+		if err := jellyfinGetJSON(cfg, path, values, &page); err != nil {
+			return nil, 0, err
+		}
+
+		for _, raw := range page.Items {
+			item := &libraryItem{raw: raw}
+			if err := json.Unmarshal(raw, &item.keys); err != nil {
+				return nil, 0, fmt.Errorf("error parsing response: %v", err)
+			}
+			if len(q.Containers) > 0 && !q.matchesContainer(item.keys.Container) {
+				continue
+			}
+			items = append(items, item)
+		}
+		total += page.TotalRecordCount
+	}
+	if len(q.Containers) > 0 {
+		total = len(items)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if q.SortOrder == "Descending" {
+			return q.compare(items[j], items[i]) < 0
+		}
+		return q.compare(items[i], items[j]) < 0
+	})
+
+	start, end := q.StartIndex, q.StartIndex+q.Limit
+	if start > len(items) {
+		start = len(items)
+	}
+	if end > len(items) {
+		end = len(items)
+	}
+	raws := make([]json.RawMessage, 0, end-start)
+	for _, item := range items[start:end] {
+		raws = append(raws, item.raw)
+	}
+	return raws, total, nil
+}
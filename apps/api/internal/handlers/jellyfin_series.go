@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/models"
+)
+
+// episodeFields are the extra Jellyfin fields requested for seasons and
+// episodes
+const episodeFields = "Overview,PrimaryImageAspectRatio,ChildCount"
+
+// seriesPathIDs extracts the series and season IDs from
+// /api/jellyfin/series/{id}/seasons[/{seasonId}/episodes] and
+// /api/jellyfin/series/{id}/next-episode
+func seriesPathIDs(p string) (string, string, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(p, "/api/jellyfin/series/"), "/"), "/")
+	if len(parts) < 2 || !jellyfinItemIDPattern.MatchString(parts[0]) {
+		return "", "", false
+	}
+	switch {
+	case len(parts) == 2:
+		return parts[0], "", true
+	case len(parts) == 4 && parts[1] == "seasons" && parts[3] == "episodes" && jellyfinItemIDPattern.MatchString(parts[2]):
+		return parts[0], parts[2], true
+	}
+	return "", "", false
+}
+
+// fetchEpisodes lists the episodes of a series, or of one of its seasons when
+// seasonID is set, in airing order with the user's playback state
+func fetchEpisodes(cfg *config.Config, jellyfinUserID, seriesID, seasonID string) (*models.JellyfinEpisodesResponse, error) {
+	query := url.Values{
+		"UserId":    {jellyfinUserID},
+		"Fields":    {episodeFields},
+		"IsMissing": {"false"},
+	}
+	if seasonID != "" {
+		query.Set("SeasonId", seasonID)
+	}
+
+	var episodes models.JellyfinEpisodesResponse
+	if err := jellyfinGetJSON(cfg, "/Shows/"+seriesID+"/Episodes", query, &episodes); err != nil {
+		return nil, err
+	}
+	if episodes.Items == nil {
+		episodes.Items = []models.JellyfinEpisode{}
+	}
+	return &episodes, nil
+}
+
+// episodeAfter returns the episode following episodeID in episodes, and
+// whether episodeID was found. Specials are skipped unless episodeID is one.
+func episodeAfter(episodes []models.JellyfinEpisode, episodeID string) (*models.JellyfinEpisode, bool) {
+	current := -1
+	for i, episode := range episodes {
+		if strings.EqualFold(episode.Id, episodeID) {
+			current = i
+			break
+		}
+	}
+	if current < 0 {
+		return nil, false
+	}
+
+	special := episodes[current].ParentIndexNumber == 0
+	for i := current + 1; i < len(episodes); i++ {
+		if special || episodes[i].ParentIndexNumber > 0 {
+			return &episodes[i], true
+		}
+	}
+	return nil, true
+}
+
+// writeJellyfinError reports a failed Jellyfin call, passing a 404 through
+func writeJellyfinError(w http.ResponseWriter, what string, err error) {
+	if statusErr, ok := err.(*jellyfinStatusError); ok && statusErr.StatusCode == http.StatusNotFound {
+		http.Error(w, "Series or season not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Error fetching %s: %v", what, err)
+	http.Error(w, fmt.Sprintf("Error fetching %s: %v", what, err), http.StatusInternalServerError)
+}
+
+// GetSeasons lists the seasons of a series
+func (h *JellyfinHandler) GetSeasons(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seriesID, _, ok := seriesPathIDs(r.URL.Path)
+	if !ok {
+		http.Error(w, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+
+	jellyfinUserID, err := h.jellyfinUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var seasons models.JellyfinSeasonsResponse
+	err = jellyfinGetJSON(h.config, "/Shows/"+seriesID+"/Seasons", url.Values{
+		"UserId":    {jellyfinUserID},
+		"Fields":    {episodeFields},
+		"IsMissing": {"false"},
+	}, &seasons)
+	if err != nil {
+		writeJellyfinError(w, "seasons", err)
+		return
+	}
+	if seasons.Items == nil {
+		seasons.Items = []models.JellyfinSeason{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(seasons)
+}
+
+// GetEpisodes lists the episodes of a season
+func (h *JellyfinHandler) GetEpisodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seriesID, seasonID, ok := seriesPathIDs(r.URL.Path)
+	if !ok || seasonID == "" {
+		http.Error(w, "Invalid series or season ID", http.StatusBadRequest)
+		return
+	}
+
+	jellyfinUserID, err := h.jellyfinUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	episodes, err := fetchEpisodes(h.config, jellyfinUserID, seriesID, seasonID)
+	if err != nil {
+		writeJellyfinError(w, "episodes", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(episodes)
+}
+
+// GetNextEpisode returns the episode to watch next: the one following
+// ?after={episodeId}, or otherwise Jellyfin's next up for the user (the first
+// episode of a series not started yet). Specials are skipped unless the
+// current episode is one.
+func (h *JellyfinHandler) GetNextEpisode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seriesID, _, ok := seriesPathIDs(r.URL.Path)
+	if !ok {
+		http.Error(w, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+	after := r.URL.Query().Get("after")
+	if after != "" && !jellyfinItemIDPattern.MatchString(after) {
+		http.Error(w, "Invalid episode ID", http.StatusBadRequest)
+		return
+	}
+
+	jellyfinUserID, err := h.jellyfinUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var next *models.JellyfinEpisode
+	if after != "" {
+		episodes, err := fetchEpisodes(h.config, jellyfinUserID, seriesID, "")
+		if err != nil {
+			writeJellyfinError(w, "episodes", err)
+			return
+		}
+		var found bool
+		if next, found = episodeAfter(episodes.Items, after); !found {
+			http.Error(w, "Episode not found", http.StatusNotFound)
+			return
+		}
+	} else {
+		var nextUp models.JellyfinEpisodesResponse
+		err := jellyfinGetJSON(h.config, "/Shows/NextUp", url.Values{
+			"UserId":   {jellyfinUserID},
+			"SeriesId": {seriesID},
+			"Fields":   {episodeFields},
+			"Limit":    {"1"},
+		}, &nextUp)
+		if err != nil {
+			writeJellyfinError(w, "next episode", err)
+			return
+		}
+		if len(nextUp.Items) > 0 {
+			next = &nextUp.Items[0]
+		}
+	}
+
+	if next == nil {
+		http.Error(w, "No next episode", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(next)
+}
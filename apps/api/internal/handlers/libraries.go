@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/middleware"
+	"jellystreaming/internal/models"
+)
+
+// errLibraryNotEnabled is returned for a libraryId the page does not show
+var errLibraryNotEnabled = errors.New("library not enabled")
+
+// loadLibrarySettings reads the library settings, which are empty until an
+// administrator saves them
+func loadLibrarySettings(ctx context.Context) (*models.LibrarySettings, error) {
+	var settings models.LibrarySettings
+	err := database.SettingsCollection.FindOne(ctx, bson.M{"_id": models.LibrarySettingsID}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return &models.LibrarySettings{ID: models.LibrarySettingsID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// enabledLibraryIDs returns the libraries shown for mediaType, or nil when
+// every library is
+func enabledLibraryIDs(cfg *config.Config, settings *models.LibrarySettings, mediaType string) []string {
+	if mediaType == models.MediaTypeTV {
+		if len(settings.TVLibraries) > 0 {
+			return settings.TVLibraries
+		}
+		if cfg.TVShowsParentID != "" {
+			return []string{cfg.TVShowsParentID}
+		}
+		return nil
+	}
+	if len(settings.MovieLibraries) > 0 {
+		return settings.MovieLibraries
+	}
+	if cfg.ParentID != "" {
+		return []string{cfg.ParentID}
+	}
+	return nil
+}
+
+// requestedLibraries returns the libraries a movie or series listing reads:
+// the one in ?libraryId, which must be enabled, or every enabled library
+func requestedLibraries(cfg *config.Config, r *http.Request, mediaType string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	settings, err := loadLibrarySettings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading library settings: %v", err)
+	}
+	enabled := enabledLibraryIDs(cfg, settings, mediaType)
+
+	libraryID := r.URL.Query().Get("libraryId")
+	if libraryID == "" {
+		return enabled, nil
+	}
+	if enabled == nil || containsString(enabled, libraryID) {
+		return []string{libraryID}, nil
+	}
+	return nil, errLibraryNotEnabled
+}
+
+// libraryServes reports whether a library of the given Jellyfin collection
+// type can hold items of mediaType
+func libraryServes(collectionType, mediaType string) bool {
+	switch collectionType {
+	case "", models.CollectionTypeMixed:
+		return true
+	case models.CollectionTypeMovies:
+		return mediaType == models.MediaTypeMovie
+	case models.CollectionTypeTVShows:
+		return mediaType == models.MediaTypeTV
+	}
+	return false
+}
+
+// libraryShown reports whether the page for mediaType shows a library
+func libraryShown(enabled []string, id, collectionType, mediaType string) bool {
+	if enabled == nil {
+		return libraryServes(collectionType, mediaType)
+	}
+	return containsString(enabled, id)
+}
+
+// librariesResponse lists libraries with the pages that show them. With all
+// set every library on the server is listed; otherwise only the shown
+// libraries the Jellyfin user can see.
+func (h *JellyfinHandler) librariesResponse(r *http.Request, settings *models.LibrarySettings, all bool) (*models.LibrariesResponse, error) {
+	movies := enabledLibraryIDs(h.config, settings, models.MediaTypeMovie)
+	tv := enabledLibraryIDs(h.config, settings, models.MediaTypeTV)
+
+	response := &models.LibrariesResponse{
+		Libraries:      []models.Library{},
+		MovieLibraries: nonNilStrings(settings.MovieLibraries),
+		TVLibraries:    nonNilStrings(settings.TVLibraries),
+	}
+
+	add := func(library models.Library) {
+		library.Movies = libraryShown(movies, library.ID, library.CollectionType, models.MediaTypeMovie)
+		library.TV = libraryShown(tv, library.ID, library.CollectionType, models.MediaTypeTV)
+		if all || library.Movies || library.TV {
+			response.Libraries = append(response.Libraries, library)
+		}
+	}
+
+	if all {
+		var folders []models.JellyfinVirtualFolder
+		if err := jellyfinGetJSON(h.config, "/Library/VirtualFolders", nil, &folders); err != nil {
+			return nil, err
+		}
+		for _, folder := range folders {
+			add(models.Library{
+				ID:             folder.ItemId,
+				Name:           folder.Name,
+				CollectionType: folder.CollectionType,
+				Locations:      folder.Locations,
+			})
+		}
+		return response, nil
+	}
+
+	jellyfinUserID, err := h.jellyfinUserID(r)
+	if err != nil {
+		return nil, err
+	}
+	var views models.JellyfinUserViewsResponse
+	if err := jellyfinGetJSON(h.config, fmt.Sprintf("/Users/%s/Views", jellyfinUserID), nil, &views); err != nil {
+		return nil, err
+	}
+	for _, view := range views.Items {
+		if view.CollectionType != models.CollectionTypeMovies && view.CollectionType != models.CollectionTypeTVShows &&
+			view.CollectionType != models.CollectionTypeMixed && view.CollectionType != "" {
+			continue
+		}
+		add(models.Library{ID: view.Id, Name: view.Name, CollectionType: view.CollectionType})
+	}
+	return response, nil
+}
+
+// GetLibraries lists the libraries the movie and TV pages show that the
+// caller's Jellyfin user can see. With ?all=true (libraries.manage) every
+// library on the server is listed.
+func (h *JellyfinHandler) GetLibraries(w http.ResponseWriter, r *http.Request) {
+	all := r.URL.Query().Get("all") == "true"
+	if all && !middleware.HasPermission(r, models.PermissionLibrariesManage) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	settings, err := loadLibrarySettings(ctx)
+	if err != nil {
+		http.Error(w, "Error loading library settings", http.StatusInternalServerError)
+		return
+	}
+
+	response, err := h.librariesResponse(r, settings, all)
+	if err != nil {
+		log.Printf("Error fetching Jellyfin libraries: %v", err)
+		http.Error(w, fmt.Sprintf("Error fetching libraries: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateLibraries saves which libraries the movie and TV pages show. An
+// empty list goes back to the default for that page.
+func (h *JellyfinHandler) UpdateLibraries(w http.ResponseWriter, r *http.Request) {
+	var req models.LibrarySettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var folders []models.JellyfinVirtualFolder
+	if err := jellyfinGetJSON(h.config, "/Library/VirtualFolders", nil, &folders); err != nil {
+		log.Printf("Error fetching Jellyfin libraries: %v", err)
+		http.Error(w, fmt.Sprintf("Error fetching libraries: %v", err), http.StatusInternalServerError)
+		return
+	}
+	collectionTypes := make(map[string]string, len(folders))
+	for _, folder := range folders {
+		collectionTypes[folder.ItemId] = folder.CollectionType
+	}
+
+	// Keep each library once, and only where it can hold the page's media
+	validate := func(ids []string, mediaType string) ([]string, error) {
+		cleaned := []string{}
+		for _, id := range ids {
+			collectionType, ok := collectionTypes[id]
+			if !ok {
+				return nil, fmt.Errorf("unknown library %s", id)
+			}
+			if !libraryServes(collectionType, mediaType) {
+				return nil, fmt.Errorf("library %s holds %s, not %s", id, collectionType, mediaType)
+			}
+			if !containsString(cleaned, id) {
+				cleaned = append(cleaned, id)
+			}
+		}
+		return cleaned, nil
+	}
+	movieLibraries, err := validate(req.MovieLibraries, models.MediaTypeMovie)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tvLibraries, err := validate(req.TVLibraries, models.MediaTypeTV)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	before, err := loadLibrarySettings(ctx)
+	if err != nil {
+		http.Error(w, "Error loading library settings", http.StatusInternalServerError)
+		return
+	}
+
+	username, _ := r.Context().Value("username").(string)
+	settings := &models.LibrarySettings{
+		ID:             models.LibrarySettingsID,
+		MovieLibraries: movieLibraries,
+		TVLibraries:    tvLibraries,
+		UpdatedAt:      time.Now(),
+		UpdatedBy:      username,
+	}
+	_, err = database.SettingsCollection.ReplaceOne(ctx, bson.M{"_id": models.LibrarySettingsID}, settings, options.Replace().SetUpsert(true))
+	if err != nil {
+		http.Error(w, "Error saving library settings", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action: models.AuditLibraryUpdate,
+		Target: models.AuditTarget{Type: "settings", ID: models.LibrarySettingsID},
+		Changes: auditChanges(
+			map[string]interface{}{"movieLibraries": nonNilStrings(before.MovieLibraries), "tvLibraries": nonNilStrings(before.TVLibraries)},
+			map[string]interface{}{"movieLibraries": movieLibraries, "tvLibraries": tvLibraries},
+		),
+	})
+
+	response, err := h.librariesResponse(r, settings, true)
+	if err != nil {
+		log.Printf("Error fetching Jellyfin libraries: %v", err)
+		http.Error(w, fmt.Sprintf("Error fetching libraries: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// nonNilStrings returns list, or an empty list for nil
+func nonNilStrings(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
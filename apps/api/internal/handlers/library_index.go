@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/events"
+	"jellystreaming/internal/models"
+)
+
+// Radarr/Sonarr states reported on enriched TMDB list items
+const (
+	arrStatusDownloading = "downloading" // In the download queue
+	arrStatusDownloaded  = "downloaded"  // Every file is on disk
+	arrStatusPartial     = "partial"     // Some episodes are on disk
+	arrStatusMonitored   = "monitored"   // Monitored, nothing on disk yet
+	arrStatusUnmonitored = "unmonitored" // Added but not monitored
+)
+
+// librarySnapshot is what the Jellyfin, Radarr and Sonarr libraries held
+// when the index was last built. The Jellyfin maps hold what one Jellyfin
+// user can see.
+type librarySnapshot struct {
+	jellyfinMovies map[string]string // Jellyfin item ID by libraryKey
+	jellyfinSeries map[string]string
+	radarr         map[int]string // Radarr status by TMDB ID
+	sonarr         map[int]string // Sonarr status by TMDB ID
+}
+
+// libraryKey is how Jellyfin items are indexed: the lowercased provider and
+// ID, such as "tmdb.603" or "imdb.tt0133093"
+func libraryKey(provider, id string) string {
+	return strings.ToLower(provider + "." + id)
+}
+
+// tmdbKey is the libraryKey of a TMDB ID
+func tmdbKey(tmdbID int) string {
+	return libraryKey("Tmdb", strconv.Itoa(tmdbID))
+}
+
+// indexEntry caches one part of the index. A stale snapshot keeps being
+// served while a fresh one is built in the background.
+type indexEntry struct {
+	mu         sync.Mutex
+	snapshot   *librarySnapshot
+	builtAt    time.Time
+	refreshing bool
+
+	// buildMu lets a single build run at a time
+	buildMu sync.Mutex
+}
+
+// libraryIndex caches library snapshots so list pages can be annotated
+// without calling every service per item. Radarr and Sonarr are indexed once;
+// Jellyfin is indexed per Jellyfin user, so nobody learns about titles in
+// libraries they cannot see.
+type libraryIndex struct {
+	config *config.Config
+	arr    *indexEntry
+
+	mu       sync.Mutex
+	jellyfin map[string]*indexEntry // By Jellyfin user ID
+}
+
+func newLibraryIndex(cfg *config.Config) *libraryIndex {
+	return &libraryIndex{
+		config:   cfg,
+		arr:      &indexEntry{},
+		jellyfin: make(map[string]*indexEntry),
+	}
+}
+
+// get returns the Radarr and Sonarr snapshot combined with the Jellyfin
+// snapshot of jellyfinUserID; without a Jellyfin user nothing is in the
+// library. The first call for each part builds it; later calls return
+// immediately and refresh a stale part in the background.
+func (idx *libraryIndex) get(jellyfinUserID string) *librarySnapshot {
+	arr := idx.arr.get(idx.config.LibraryIndexTTL, idx.buildArr)
+	snapshot := &librarySnapshot{radarr: arr.radarr, sonarr: arr.sonarr}
+	if jellyfinUserID == "" {
+		return snapshot
+	}
+
+	idx.mu.Lock()
+	entry, ok := idx.jellyfin[jellyfinUserID]
+	if !ok {
+		entry = &indexEntry{}
+		idx.jellyfin[jellyfinUserID] = entry
+	}
+	idx.mu.Unlock()
+
+	library := entry.get(idx.config.LibraryIndexTTL, func(previous *librarySnapshot) *librarySnapshot {
+		return idx.buildJellyfin(jellyfinUserID, previous)
+	})
+	snapshot.jellyfinMovies = library.jellyfinMovies
+	snapshot.jellyfinSeries = library.jellyfinSeries
+	return snapshot
+}
+
+// invalidate marks every snapshot stale so the next read rebuilds it
+func (idx *libraryIndex) invalidate() {
+	idx.arr.invalidate()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, entry := range idx.jellyfin {
+		entry.invalidate()
+	}
+}
+
+// fresh reports whether the snapshot can be used without rebuilding. Call
+// with mu held.
+func (e *indexEntry) fresh(ttl time.Duration) bool {
+	return e.snapshot != nil && time.Since(e.builtAt) < ttl
+}
+
+// get returns the cached snapshot, building it with build on first use
+func (e *indexEntry) get(ttl time.Duration, build func(previous *librarySnapshot) *librarySnapshot) *librarySnapshot {
+	e.mu.Lock()
+	snapshot := e.snapshot
+	if snapshot != nil && !e.fresh(ttl) && !e.refreshing {
+		e.refreshing = true
+		go e.refresh(ttl, build)
+	}
+	e.mu.Unlock()
+
+	if snapshot == nil {
+		return e.refresh(ttl, build)
+	}
+	return snapshot
+}
+
+// invalidate marks the snapshot stale so the next read rebuilds it
+func (e *indexEntry) invalidate() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.builtAt = time.Time{}
+}
+
+// refresh rebuilds the snapshot unless another caller just did
+func (e *indexEntry) refresh(ttl time.Duration, build func(previous *librarySnapshot) *librarySnapshot) *librarySnapshot {
+	e.buildMu.Lock()
+	defer e.buildMu.Unlock()
+
+	e.mu.Lock()
+	if e.fresh(ttl) {
+		snapshot := e.snapshot
+		e.refreshing = false
+		e.mu.Unlock()
+		return snapshot
+	}
+	previous := e.snapshot
+	e.mu.Unlock()
+
+	snapshot := build(previous)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.snapshot = snapshot
+	e.builtAt = time.Now()
+	e.refreshing = false
+	return snapshot
+}
+
+// buildArr reads the Radarr and Sonarr libraries. A service that cannot be
+// reached keeps its entries from the previous snapshot.
+func (idx *libraryIndex) buildArr(previous *librarySnapshot) *librarySnapshot {
+	if previous == nil {
+		previous = &librarySnapshot{}
+	}
+	snapshot := &librarySnapshot{}
+
+	var wg sync.WaitGroup
+	run := func(service string, fallback map[int]string, load func() (map[int]string, error), out *map[int]string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entries, err := load()
+			if err != nil {
+				log.Printf("Error indexing %s library: %v", service, err)
+				entries = fallback
+			}
+			*out = entries
+		}()
+	}
+
+	if idx.config.RadarrURL != "" {
+		run("radarr", previous.radarr, idx.radarrMovies, &snapshot.radarr)
+	}
+	if idx.config.SonarrURL != "" {
+		run("sonarr", previous.sonarr, idx.sonarrSeries, &snapshot.sonarr)
+	}
+
+	wg.Wait()
+	return snapshot
+}
+
+// buildJellyfin reads the movies and series a Jellyfin user can see. A
+// failed read keeps its entries from the previous snapshot.
+func (idx *libraryIndex) buildJellyfin(jellyfinUserID string, previous *librarySnapshot) *librarySnapshot {
+	if previous == nil {
+		previous = &librarySnapshot{}
+	}
+	snapshot := &librarySnapshot{}
+
+	var wg sync.WaitGroup
+	run := func(mediaType string, fallback map[string]string, out *map[string]string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entries, err := idx.jellyfinItems(jellyfinUserID, mediaType)
+			if err != nil {
+				log.Printf("Error indexing jellyfin %s library of %s: %v", mediaType, jellyfinUserID, err)
+				entries = fallback
+			}
+			*out = entries
+		}()
+	}
+
+	run(models.MediaTypeMovie, previous.jellyfinMovies, &snapshot.jellyfinMovies)
+	run(models.MediaTypeTV, previous.jellyfinSeries, &snapshot.jellyfinSeries)
+
+	wg.Wait()
+	return snapshot
+}
+
+// jellyfinItems maps every provider ID of the movies or series a Jellyfin
+// user can see to the item ID
+func (idx *libraryIndex) jellyfinItems(jellyfinUserID, mediaType string) (map[string]string, error) {
+	items, err := jellyfinLibraryItems(idx.config, jellyfinUserID, mediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]string, len(items))
+	for _, item := range items {
+		for provider, id := range item.ProviderIds {
+			if id != "" {
+				entries[libraryKey(provider, id)] = item.Id
+			}
+		}
+	}
+	return entries, nil
+}
+
+// radarrMovies maps the TMDB ID of every Radarr movie to its status
+func (idx *libraryIndex) radarrMovies() (map[int]string, error) {
+	var movies []models.RadarrMovie
+	if err := arrGetJSON(idx.config.RadarrURL, idx.config.RadarrAPIKey, "/api/v3/movie", &movies); err != nil {
+		return nil, err
+	}
+	var queue models.RadarrQueueResponse
+	if err := arrGetJSON(idx.config.RadarrURL, idx.config.RadarrAPIKey, "/api/v3/queue?pageSize=1000", &queue); err != nil {
+		return nil, err
+	}
+
+	downloading := make(map[int]bool, len(queue.Records))
+	for _, item := range queue.Records {
+		downloading[item.MovieId] = true
+	}
+
+	entries := make(map[int]string, len(movies))
+	for _, movie := range movies {
+		if movie.TmdbId == 0 {
+			continue
+		}
+		switch {
+		case downloading[movie.Id]:
+			entries[movie.TmdbId] = arrStatusDownloading
+		case movie.HasFile:
+			entries[movie.TmdbId] = arrStatusDownloaded
+		case movie.Monitored:
+			entries[movie.TmdbId] = arrStatusMonitored
+		default:
+			entries[movie.TmdbId] = arrStatusUnmonitored
+		}
+	}
+	return entries, nil
+}
+
+// sonarrSeries maps the TMDB ID of every Sonarr series to its status
+func (idx *libraryIndex) sonarrSeries() (map[int]string, error) {
+	var series []models.SonarrSeries
+	if err := arrGetJSON(idx.config.SonarrURL, idx.config.SonarrAPIKey, "/api/v3/series", &series); err != nil {
+		return nil, err
+	}
+	var queue models.SonarrQueueResponse
+	if err := arrGetJSON(idx.config.SonarrURL, idx.config.SonarrAPIKey, "/api/v3/queue?pageSize=1000", &queue); err != nil {
+		return nil, err
+	}
+
+	downloading := make(map[int]bool, len(queue.Records))
+	for _, item := range queue.Records {
+		downloading[item.SeriesId] = true
+	}
+
+	entries := make(map[int]string, len(series))
+	for _, show := range series {
+		if show.TmdbId == 0 {
+			continue
+		}
+		files, _ := show.Statistics["episodeFileCount"].(float64)
+		percent, _ := show.Statistics["percentOfEpisodes"].(float64)
+		switch {
+		case downloading[show.Id]:
+			entries[show.TmdbId] = arrStatusDownloading
+		case files > 0 && percent >= 100:
+			entries[show.TmdbId] = arrStatusDownloaded
+		case files > 0:
+			entries[show.TmdbId] = arrStatusPartial
+		case show.Monitored:
+			entries[show.TmdbId] = arrStatusMonitored
+		default:
+			entries[show.TmdbId] = arrStatusUnmonitored
+		}
+	}
+	return entries, nil
+}
+
+// subscribe marks the index stale whenever a library changes
+func (idx *libraryIndex) subscribe() {
+	for _, eventType := range []string{
+		events.JellyfinItemAdded,
+		events.RadarrGrab, events.RadarrDownload, events.RadarrMovieDelete,
+		events.SonarrGrab, events.SonarrDownload, events.SonarrSeriesDelete,
+		events.RequestApproved,
+	} {
+		events.Subscribe(eventType, func(events.Event) {
+			idx.invalidate()
+		})
+	}
+}
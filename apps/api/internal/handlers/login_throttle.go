@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/middleware"
+	"jellystreaming/internal/models"
+)
+
+// checkLoginThrottle rejects the attempt with 429 and Retry-After while the
+// username or client IP is locked out, and reports whether to continue
+func (h *AuthHandler) checkLoginThrottle(ctx context.Context, w http.ResponseWriter, r *http.Request, username string) bool {
+	ip := middleware.ClientIP(r)
+
+	wait, err := database.LoginLockedFor(ctx, database.UsernameThrottleKey(username), database.IPThrottleKey(ip))
+	if err != nil {
+		// Do not lock everyone out because the throttle store is unavailable
+		log.Printf("Error checking login throttle: %v", err)
+		return true
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	h.logFailedLogin(ctx, r, username, models.FailedLoginThrottled)
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, fmt.Sprintf("Too many failed login attempts, try again in %d seconds", seconds), http.StatusTooManyRequests)
+	return false
+}
+
+// recordLoginFailure logs a failed attempt and advances the backoff for both
+// the username and the client IP
+func (h *AuthHandler) recordLoginFailure(ctx context.Context, r *http.Request, username, reason string) {
+	h.logFailedLogin(ctx, r, username, reason)
+
+	ip := middleware.ClientIP(r)
+
+	throttle, err := database.RecordLoginFailure(ctx, database.UsernameThrottleKey(username),
+		h.config.LoginMaxAttempts, h.config.LoginBackoffBase, h.config.LoginLockoutMax, h.config.LoginFailureWindow)
+	if err != nil {
+		log.Printf("Error recording login failure for %s: %v", username, err)
+	} else if throttle.LockedUntil != nil {
+		log.Printf("Login for %s locked until %s after %d failures", username, throttle.LockedUntil.Format("15:04:05"), throttle.Failures)
+	}
+
+	throttle, err = database.RecordLoginFailure(ctx, database.IPThrottleKey(ip),
+		h.config.LoginIPMaxAttempts, h.config.LoginBackoffBase, h.config.LoginLockoutMax, h.config.LoginFailureWindow)
+	if err != nil {
+		log.Printf("Error recording login failure for %s: %v", ip, err)
+	} else if throttle.LockedUntil != nil {
+		log.Printf("Logins from %s locked until %s after %d failures", ip, throttle.LockedUntil.Format("15:04:05"), throttle.Failures)
+	}
+}
+
+// clearLoginFailures resets the username counter after a complete login. The
+// IP counter is left alone so one valid account cannot reset it for an attacker.
+func (h *AuthHandler) clearLoginFailures(ctx context.Context, username string) {
+	if _, err := database.ClearLoginThrottle(ctx, database.UsernameThrottleKey(username)); err != nil {
+		log.Printf("Error clearing login throttle for %s: %v", username, err)
+	}
+}
+
+// logFailedLogin stores a failed login record for the security log
+func (h *AuthHandler) logFailedLogin(ctx context.Context, r *http.Request, username, reason string) {
+	err := database.RecordFailedLogin(ctx, models.FailedLogin{
+		Username:  username,
+		IP:        middleware.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Reason:    reason,
+	}, h.config.FailedLoginRetention)
+	if err != nil {
+		log.Printf("Error recording failed login: %v", err)
+	}
+}
@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+	"jellystreaming/internal/totp"
+)
+
+const (
+	mfaTokenAudience   = "mfa"
+	mfaTokenTTL        = 5 * time.Minute
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+// recoveryCodeAlphabet avoids characters that are easy to confuse (0/o, 1/l/i)
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// findUserByID loads a user by hex ObjectID
+func findUserByID(ctx context.Context, userID string) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := database.UsersCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// generateMFAToken creates a short-lived challenge token proving the password step succeeded
+func generateMFAToken(user *models.User) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:   user.ID.Hex(),
+		Audience:  jwt.ClaimStrings{mfaTokenAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(database.JWTSecret)
+}
+
+// parseMFAToken validates a challenge token and returns the user ID it was issued for
+func parseMFAToken(tokenString string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return database.JWTSecret, nil
+	}, jwt.WithAudience(mfaTokenAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid || claims.Subject == "" {
+		return "", errors.New("invalid token")
+	}
+
+	return claims.Subject, nil
+}
+
+// normalizeRecoveryCode makes recovery codes case- and dash-insensitive
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// generateRecoveryCodes returns new recovery codes and their hashes for storage
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		for j := range b {
+			b[j] = recoveryCodeAlphabet[int(b[j])%len(recoveryCodeAlphabet)]
+		}
+		half := recoveryCodeLength / 2
+		codes[i] = string(b[:half]) + "-" + string(b[half:])
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	return codes, hashes, nil
+}
+
+// consumeSecondFactor checks a TOTP or recovery code for a user and marks it
+// as used so that it cannot be replayed
+func consumeSecondFactor(ctx context.Context, user *models.User, code string) (bool, error) {
+	if !user.MFAEnabled || code == "" {
+		return false, nil
+	}
+
+	if counter, ok := totp.Validate(code, user.MFASecret, time.Now()); ok {
+		result, err := database.UsersCollection.UpdateOne(ctx, bson.M{
+			"_id": user.ID,
+			"$or": []bson.M{
+				{"mfaLastCounter": bson.M{"$lt": counter}},
+				{"mfaLastCounter": bson.M{"$exists": false}},
+			},
+		}, bson.M{"$set": bson.M{"mfaLastCounter": counter}})
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+
+	codeHash := hashToken(normalizeRecoveryCode(code))
+	result, err := database.UsersCollection.UpdateOne(ctx,
+		bson.M{"_id": user.ID, "recoveryCodes": codeHash},
+		bson.M{"$pull": bson.M{"recoveryCodes": codeHash}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// SetupMFA generates a new TOTP secret for the current user to confirm
+func (h *AuthHandler) SetupMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, r.Context().Value("userID").(string))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if user.MFAEnabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		http.Error(w, "Error generating secret", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = database.UsersCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"mfaPendingSecret": secret, "updatedAt": time.Now()},
+	})
+	if err != nil {
+		http.Error(w, "Error saving secret", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MFASetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(h.config.MFAIssuer, user.Username, secret),
+	})
+}
+
+// EnableMFA confirms the pending secret with a code and turns on two-factor authentication
+func (h *AuthHandler) EnableMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, r.Context().Value("userID").(string))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if user.MFAEnabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	if user.MFAPendingSecret == "" {
+		http.Error(w, "Two-factor setup has not been started", http.StatusBadRequest)
+		return
+	}
+
+	counter, ok := totp.Validate(req.Code, user.MFAPendingSecret, time.Now())
+	if !ok {
+		http.Error(w, "Invalid verification code", http.StatusUnauthorized)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Error generating recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = database.UsersCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{
+			"mfaEnabled":     true,
+			"mfaSecret":      user.MFAPendingSecret,
+			"mfaLastCounter": counter,
+			"recoveryCodes":  hashes,
+			"updatedAt":      time.Now(),
+		},
+		"$unset": bson.M{"mfaPendingSecret": ""},
+	})
+	if err != nil {
+		http.Error(w, "Error enabling two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableMFA turns off two-factor authentication after re-checking both
+// factors. Jellyfin and OIDC accounts have no local password to re-check, so
+// the second factor alone is required for them.
+func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.MFADisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, r.Context().Value("userID").(string))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if !user.MFAEnabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	if user.Password != "" && !database.CheckPassword(req.Password, user.Password) {
+		http.Error(w, "Password is incorrect", http.StatusUnauthorized)
+		return
+	}
+
+	ok, err := consumeSecondFactor(ctx, user, req.Code)
+	if err != nil {
+		log.Printf("Error checking second factor: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid verification code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := disableMFA(ctx, user.ID); err != nil {
+		http.Error(w, "Error disabling two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a second factor
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, r.Context().Value("userID").(string))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if !user.MFAEnabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	ok, err := consumeSecondFactor(ctx, user, req.Code)
+	if err != nil {
+		log.Printf("Error checking second factor: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid verification code", http.StatusUnauthorized)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Error generating recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = database.UsersCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"recoveryCodes": hashes, "updatedAt": time.Now()},
+	})
+	if err != nil {
+		http.Error(w, "Error saving recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// VerifyMFA completes a two-step login with a TOTP or recovery code
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.MFAToken == "" || req.Code == "" {
+		http.Error(w, "MFA token and code required", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := parseMFAToken(req.MFAToken)
+	if err != nil {
+		http.Error(w, "Invalid or expired MFA token", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, userID)
+	if err != nil {
+		http.Error(w, "Invalid or expired MFA token", http.StatusUnauthorized)
+		return
+	}
+
+	if !h.checkLoginThrottle(ctx, w, r, user.Username) {
+		return
+	}
+
+	ok, err := consumeSecondFactor(ctx, user, req.Code)
+	if err != nil {
+		log.Printf("Error checking second factor: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(ctx, r, user.Username, models.FailedLoginInvalidMFACode)
+		http.Error(w, "Invalid verification code", http.StatusUnauthorized)
+		return
+	}
+
+	h.clearLoginFailures(ctx, user.Username)
+
+	response, err := h.startSession(r, user)
+	if err != nil {
+		log.Printf("Error starting session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// disableMFA removes all two-factor state from a user
+func disableMFA(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := database.UsersCollection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{"mfaEnabled": false, "updatedAt": time.Now()},
+		"$unset": bson.M{
+			"mfaSecret":        "",
+			"mfaPendingSecret": "",
+			"mfaLastCounter":   "",
+			"recoveryCodes":    "",
+		},
+	})
+	return err
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/events"
+	"jellystreaming/internal/models"
+)
+
+// streamHeartbeat keeps idle event streams open through proxies
+const streamHeartbeat = 30 * time.Second
+
+// streamMessage is one Server-Sent Event
+type streamMessage struct {
+	Event string
+	Data  interface{}
+}
+
+// inboxHub fans inbox changes out to each user's open event streams
+type inboxHub struct {
+	mu      sync.Mutex
+	clients map[primitive.ObjectID]map[chan streamMessage]struct{}
+}
+
+func newInboxHub() *inboxHub {
+	return &inboxHub{clients: make(map[primitive.ObjectID]map[chan streamMessage]struct{})}
+}
+
+// connect registers a stream for userID
+func (hub *inboxHub) connect(userID primitive.ObjectID) chan streamMessage {
+	ch := make(chan streamMessage, 16)
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.clients[userID] == nil {
+		hub.clients[userID] = make(map[chan streamMessage]struct{})
+	}
+	hub.clients[userID][ch] = struct{}{}
+	return ch
+}
+
+// disconnect removes a stream registered with connect
+func (hub *inboxHub) disconnect(userID primitive.ObjectID, ch chan streamMessage) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	delete(hub.clients[userID], ch)
+	if len(hub.clients[userID]) == 0 {
+		delete(hub.clients, userID)
+	}
+}
+
+// connected reports whether userID has any open stream
+func (hub *inboxHub) connected(userID primitive.ObjectID) bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return len(hub.clients[userID]) > 0
+}
+
+// send delivers msg to every stream of userID. A stream that is not keeping
+// up misses the message rather than blocking the sender.
+func (hub *inboxHub) send(userID primitive.ObjectID, msg streamMessage) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for ch := range hub.clients[userID] {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// unreadCount counts the user's unread inbox items
+func unreadCount(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return database.NotificationsCollection.CountDocuments(ctx, bson.M{"userId": userID, "read": false})
+}
+
+// pushUnread sends the user's current unread count to their open streams
+func (h *NotificationHandler) pushUnread(userID primitive.ObjectID) {
+	if !h.hub.connected(userID) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := unreadCount(ctx, userID)
+	if err != nil {
+		log.Printf("Error counting unread notifications: %v", err)
+		return
+	}
+	h.hub.send(userID, streamMessage{Event: "unread", Data: map[string]int64{"count": count}})
+}
+
+// SubscribeEvents pushes new inbox items to connected browsers
+func (h *NotificationHandler) SubscribeEvents() {
+	events.Subscribe(events.NotificationCreated, func(event events.Event) {
+		notification, ok := event.Payload.(*models.Notification)
+		if !ok || !h.hub.connected(notification.UserID) {
+			return
+		}
+		h.hub.send(notification.UserID, streamMessage{Event: "notification", Data: notification})
+		h.pushUnread(notification.UserID)
+	})
+}
+
+// Stream sends inbox changes to the browser as Server-Sent Events: an
+// "unread" event with the unread count on connect and after every change,
+// and a "notification" event with each new item
+func (h *NotificationHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	userID, _ := r.Context().Value("userID").(string)
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	count, err := unreadCount(ctx, objectID)
+	cancel()
+	if err != nil {
+		http.Error(w, "Error counting notifications", http.StatusInternalServerError)
+		return
+	}
+
+	messages := h.hub.connect(objectID)
+	defer h.hub.disconnect(objectID, messages)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	writeStreamMessage(w, streamMessage{Event: "unread", Data: map[string]int64{"count": count}})
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-messages:
+			writeStreamMessage(w, msg)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeStreamMessage writes msg in the text/event-stream format
+func writeStreamMessage(w http.ResponseWriter, msg streamMessage) {
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		log.Printf("Error encoding %s stream event: %v", msg.Event, err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Event, data)
+}
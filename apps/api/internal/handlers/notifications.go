@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/middleware"
+	"jellystreaming/internal/models"
+	"jellystreaming/internal/notify"
+)
+
+// maxNotificationChannels limits how many destinations a user can configure
+const maxNotificationChannels = 10
+
+// NotificationHandler handles notification preference and inbox requests
+type NotificationHandler struct {
+	config   *config.Config
+	notifier *notify.Notifier
+	hub      *inboxHub
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(cfg *config.Config, notifier *notify.Notifier) *NotificationHandler {
+	return &NotificationHandler{
+		config:   cfg,
+		notifier: notifier,
+		hub:      newInboxHub(),
+	}
+}
+
+// contextUserID returns the authenticated user's ID
+func contextUserID(r *http.Request) (primitive.ObjectID, error) {
+	userID, _ := r.Context().Value("userID").(string)
+	return primitive.ObjectIDFromHex(userID)
+}
+
+// currentUser loads the authenticated user
+func currentUser(ctx context.Context, r *http.Request) (*models.User, error) {
+	objectID, err := contextUserID(r)
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := database.UsersCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// isExternalChannel reports whether a channel type sends requests to a
+// user-supplied URL
+func isExternalChannel(channelType string) bool {
+	switch channelType {
+	case models.ChannelWebhook, models.ChannelDiscord, models.ChannelNtfy, models.ChannelGotify:
+		return true
+	}
+	return false
+}
+
+// validateChannels checks a set of channels before they are saved or tested
+func (h *NotificationHandler) validateChannels(r *http.Request, channels []models.NotificationChannel) error {
+	if len(channels) > maxNotificationChannels {
+		return fmt.Errorf("at most %d notification channels are allowed", maxNotificationChannels)
+	}
+	for i, channel := range channels {
+		// Outbound URLs let the server be pointed at arbitrary hosts, so they
+		// are limited to users trusted with them
+		if isExternalChannel(channel.Type) && !middleware.HasPermission(r, models.PermissionNotificationsWebhooks) {
+			return fmt.Errorf("channel %d: %s channels require the %s permission", i+1, channel.Type, models.PermissionNotificationsWebhooks)
+		}
+		if err := h.notifier.Validate(channel); err != nil {
+			return fmt.Errorf("channel %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// GetPreferences returns the current user's notification channels
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NotificationPreferences{Channels: notify.UserChannels(user)})
+}
+
+// UpdatePreferences replaces the current user's notification channels
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	var prefs models.NotificationPreferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if prefs.Channels == nil {
+		// An empty list turns every notification off; nil would bring back
+		// the defaults
+		prefs.Channels = []models.NotificationChannel{}
+	}
+	if err := h.validateChannels(r, prefs.Channels); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	objectID, err := contextUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = database.UsersCollection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{
+		"$set": bson.M{"notifications": prefs.Channels, "updatedAt": time.Now()},
+	})
+	if err != nil {
+		http.Error(w, "Error saving notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// TestNotification sends a test message to the channels in the request body,
+// or to the user's saved channels if the body is empty
+func (h *NotificationHandler) TestNotification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var prefs models.NotificationPreferences
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	user, err := currentUser(ctx, r)
+	cancel()
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	channels := prefs.Channels
+	if channels == nil {
+		channels = notify.UserChannels(user)
+	}
+	if err := h.validateChannels(r, channels); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]models.TestNotificationResult, 0, len(channels))
+	for _, channel := range channels {
+		result := models.TestNotificationResult{Type: channel.Type, OK: true}
+		if err := h.notifier.Send(r.Context(), user, channel, notify.Data{Event: models.NotificationTest}); err != nil {
+			result.OK = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+	"jellystreaming/internal/oidc"
+)
+
+const (
+	// oidcStateTTL bounds how long a user may take at the identity provider
+	oidcStateTTL = 10 * time.Minute
+	// oidcStateCookie binds a login to the browser that started it
+	oidcStateCookie = "oidc_state"
+)
+
+var (
+	errOIDCNotProvisioned = errors.New("no account is linked to this identity")
+	errOIDCUsernameTaken  = errors.New("an account with this username already exists")
+	errOIDCStateMismatch  = errors.New("state does not belong to this browser")
+	errOIDCStateExpired   = errors.New("state has expired")
+)
+
+// OIDCConfig tells the login page whether single sign-on is available
+func (h *AuthHandler) OIDCConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := models.OIDCConfigResponse{Enabled: h.oidc != nil}
+	if h.oidc != nil {
+		response.LoginURL = "/api/auth/oidc/login"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// OIDCLogin starts the authorization code flow by redirecting to the identity provider
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.oidc == nil {
+		http.Error(w, "Single sign-on is not configured", http.StatusNotFound)
+		return
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	_, err = database.OIDCStatesCollection.InsertOne(ctx, models.OIDCState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcStateTTL),
+	})
+	if err != nil {
+		log.Printf("Error saving OIDC state: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := h.oidc.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		log.Printf("Error building OIDC authorization URL: %v", err)
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	// Lax so the cookie comes back on the provider's top-level redirect
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   int(oidcStateTTL / time.Second),
+		Secure:   strings.HasPrefix(h.config.OIDCRedirectURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback completes the flow: it validates state, exchanges the code,
+// verifies the ID token, provisions the user and hands tokens to the frontend
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.oidc == nil {
+		http.Error(w, "Single sign-on is not configured", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		log.Printf("OIDC provider returned error: %s (%s)", providerError, query.Get("error_description"))
+		h.oidcRedirect(w, r, url.Values{"error": {"Single sign-on was cancelled or failed"}})
+		return
+	}
+
+	code := query.Get("code")
+	stateValue := query.Get("state")
+	if code == "" || stateValue == "" {
+		h.oidcRedirect(w, r, url.Values{"error": {"Missing code or state"}})
+		return
+	}
+
+	// The state cookie is single-use like the state itself
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// States are single-use: deleting on lookup prevents replaying a callback
+	var state models.OIDCState
+	err := database.OIDCStatesCollection.FindOneAndDelete(ctx, bson.M{"state": stateValue}).Decode(&state)
+	if err != nil {
+		h.oidcRedirect(w, r, url.Values{"error": {"Login session expired, please try again"}})
+		return
+	}
+
+	claims, err := h.verifyOIDCCallback(ctx, r, &state)
+	if errors.Is(err, errOIDCStateMismatch) || errors.Is(err, errOIDCStateExpired) {
+		h.oidcRedirect(w, r, url.Values{"error": {"Login session expired, please try again"}})
+		return
+	}
+	if err != nil {
+		log.Printf("Error completing OIDC login: %v", err)
+		h.oidcRedirect(w, r, url.Values{"error": {"Could not verify identity"}})
+		return
+	}
+
+	user, err := h.provisionOIDCUser(ctx, claims)
+	if err != nil {
+		log.Printf("Error provisioning OIDC user: %v", err)
+		message := "Could not sign in"
+		if errors.Is(err, errOIDCNotProvisioned) || errors.Is(err, errOIDCUsernameTaken) {
+			message = err.Error()
+		}
+		h.oidcRedirect(w, r, url.Values{"error": {message}})
+		return
+	}
+
+	// The identity provider is responsible for any second factor
+	response, err := h.startSession(r, user)
+	if err != nil {
+		log.Printf("Error starting session: %v", err)
+		h.oidcRedirect(w, r, url.Values{"error": {"Could not sign in"}})
+		return
+	}
+
+	h.oidcRedirect(w, r, url.Values{
+		"token":        {response.Token},
+		"refreshToken": {response.RefreshToken},
+		"expiresIn":    {fmt.Sprintf("%d", response.ExpiresIn)},
+	})
+}
+
+// verifyOIDCCallback checks that the callback belongs to the stored state,
+// then exchanges the code and verifies the ID token against the state's nonce.
+// A callback without the state cookie was not started by this browser: it may
+// be an attempt to sign the user in to someone else's account (login CSRF).
+func (h *AuthHandler) verifyOIDCCallback(ctx context.Context, r *http.Request, state *models.OIDCState) (jwt.MapClaims, error) {
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state.State)) != 1 {
+		return nil, errOIDCStateMismatch
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, errOIDCStateExpired
+	}
+
+	tokens, err := h.oidc.Exchange(ctx, r.URL.Query().Get("code"), state.CodeVerifier)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging code: %v", err)
+	}
+	return h.oidc.VerifyIDToken(ctx, tokens.IDToken, state.Nonce)
+}
+
+// oidcRedirect sends the browser back to the frontend; values go in the URL
+// fragment so tokens never reach server logs or Referer headers
+func (h *AuthHandler) oidcRedirect(w http.ResponseWriter, r *http.Request, values url.Values) {
+	http.Redirect(w, r, h.config.OIDCPostLoginRedirect+"#"+values.Encode(), http.StatusFound)
+}
+
+// provisionOIDCUser finds, links or creates the local user for a verified identity
+func (h *AuthHandler) provisionOIDCUser(ctx context.Context, claims jwt.MapClaims) (*models.User, error) {
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+
+	username := claimString(claims, h.config.OIDCUsernameClaim)
+	if username == "" {
+		username = claimString(claims, "email")
+	}
+	if username == "" {
+		username = subject
+	}
+	email := claimString(claims, "email")
+
+	// Admin rights are only managed by the provider when an admin group is configured
+	manageAdmin := h.config.OIDCAdminGroup != ""
+	isAdmin := manageAdmin && containsString(claimStrings(claims, h.config.OIDCGroupsClaim), h.config.OIDCAdminGroup)
+
+	var user models.User
+	err := database.UsersCollection.FindOne(ctx, bson.M{"oidcIssuer": issuer, "oidcSubject": subject}).Decode(&user)
+	if err == nil {
+		set := bson.M{"updatedAt": time.Now()}
+		if email != "" {
+			set["email"] = email
+			user.Email = email
+		}
+		if manageAdmin {
+			set["isAdmin"] = isAdmin
+			if user.IsAdmin && !isAdmin {
+				// Removed from the admin group: end sessions that still carry admin claims
+				if _, err := database.RevokeUserSessions(ctx, user.ID, ""); err != nil {
+					log.Printf("Error revoking sessions for demoted user %s: %v", user.Username, err)
+				}
+			}
+			user.IsAdmin = isAdmin
+		}
+		if _, err := database.UsersCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": set}); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// An account with the same name is only linked when OIDC_LINK_BY_USERNAME
+	// is set, and never one with a local password or MFA: anyone able to
+	// register that username at the provider could otherwise take it over
+	if h.config.OIDCLinkByUsername {
+		set := bson.M{
+			"authProvider": models.AuthProviderOIDC,
+			"oidcIssuer":   issuer,
+			"oidcSubject":  subject,
+			"updatedAt":    time.Now(),
+		}
+		if manageAdmin {
+			set["isAdmin"] = isAdmin
+		}
+		err := database.UsersCollection.FindOneAndUpdate(ctx,
+			bson.M{
+				"username":    username,
+				"oidcSubject": bson.M{"$exists": false},
+				"password":    bson.M{"$in": bson.A{"", nil}},
+				"mfaEnabled":  bson.M{"$ne": true},
+			},
+			bson.M{"$set": set},
+		).Decode(&user)
+		if err == nil {
+			log.Printf("Linked existing user %s to OIDC subject %s", username, subject)
+			return findUserByID(ctx, user.ID.Hex())
+		}
+		if err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+	}
+
+	if !h.config.OIDCLinkByUsername && !h.config.OIDCAutoCreate {
+		return nil, errOIDCNotProvisioned
+	}
+
+	// The name belongs to an account that cannot be linked
+	count, err := database.UsersCollection.CountDocuments(ctx, bson.M{"username": username})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errOIDCUsernameTaken
+	}
+
+	if !h.config.OIDCAutoCreate {
+		return nil, errOIDCNotProvisioned
+	}
+
+	user = models.User{
+		Username:     username,
+		Email:        email,
+		IsAdmin:      isAdmin,
+		AuthProvider: models.AuthProviderOIDC,
+		OIDCIssuer:   issuer,
+		OIDCSubject:  subject,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	result, err := database.UsersCollection.InsertOne(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+
+	log.Printf("Provisioned user %s from OIDC subject %s", username, subject)
+	return &user, nil
+}
+
+// claimValue resolves a claim by name, following dots into nested objects
+// (e.g. Keycloak's "realm_access.roles")
+func claimValue(claims jwt.MapClaims, name string) interface{} {
+	if name == "" {
+		return nil
+	}
+	if value, ok := claims[name]; ok {
+		return value
+	}
+
+	var current interface{} = map[string]interface{}(claims)
+	for _, part := range strings.Split(name, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = object[part]
+	}
+	return current
+}
+
+// claimString returns a string claim or an empty string
+func claimString(claims jwt.MapClaims, name string) string {
+	value, _ := claimValue(claims, name).(string)
+	return value
+}
+
+// claimStrings returns a claim holding either a list of strings or a single string
+func claimStrings(claims jwt.MapClaims, name string) []string {
+	switch value := claimValue(claims, name).(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
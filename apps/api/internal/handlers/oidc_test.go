@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/models"
+	"jellystreaming/internal/oidc"
+)
+
+const (
+	testClientID     = "jellystreaming"
+	testCode         = "auth-code"
+	testCodeVerifier = "code-verifier"
+	testKeyID        = "test-key"
+)
+
+// mockProvider is an OpenID Connect provider serving discovery, token and
+// JWKS endpoints. The token endpoint issues an ID token with nonce for
+// testCode and testCodeVerifier.
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	nonce  string
+	// exchanges counts calls to the token endpoint
+	exchanges atomic.Int32
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	m := &mockProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidc.Metadata{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		m.exchanges.Add(1)
+		if r.FormValue("code") != testCode || r.FormValue("code_verifier") != testCodeVerifier {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":   m.server.URL,
+			"aud":   testClientID,
+			"sub":   "subject-1",
+			"email": "viewer@example.com",
+			"nonce": m.nonce,
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = testKeyID
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(oidc.TokenResponse{AccessToken: "access", TokenType: "Bearer", IDToken: idToken})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// handler returns an AuthHandler using the mock provider
+func (m *mockProvider) handler() *AuthHandler {
+	cfg := &config.Config{OIDCRedirectURL: "http://localhost/api/auth/oidc/callback"}
+	return &AuthHandler{
+		config: cfg,
+		oidc: oidc.New(oidc.Config{
+			IssuerURL:   m.server.URL,
+			ClientID:    testClientID,
+			RedirectURL: cfg.OIDCRedirectURL,
+		}),
+	}
+}
+
+// callbackRequest is the provider's redirect back for state, sent with the
+// state cookie set to cookie unless it is empty
+func callbackRequest(state, cookie string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?code="+testCode+"&state="+state, nil)
+	if cookie != "" {
+		r.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: cookie})
+	}
+	return r
+}
+
+func testState(nonce string, expiresAt time.Time) *models.OIDCState {
+	return &models.OIDCState{
+		State:        "state-1",
+		Nonce:        nonce,
+		CodeVerifier: testCodeVerifier,
+		CreatedAt:    expiresAt.Add(-oidcStateTTL),
+		ExpiresAt:    expiresAt,
+	}
+}
+
+func TestVerifyOIDCCallback(t *testing.T) {
+	provider := newMockProvider(t)
+	provider.nonce = "nonce-1"
+	h := provider.handler()
+
+	state := testState("nonce-1", time.Now().Add(oidcStateTTL))
+	claims, err := h.verifyOIDCCallback(context.Background(), callbackRequest(state.State, state.State), state)
+	if err != nil {
+		t.Fatalf("verifyOIDCCallback: %v", err)
+	}
+	if sub, _ := claims["sub"].(string); sub != "subject-1" {
+		t.Errorf("sub = %q, want subject-1", sub)
+	}
+	if email := claimString(claims, "email"); email != "viewer@example.com" {
+		t.Errorf("email = %q, want viewer@example.com", email)
+	}
+}
+
+func TestVerifyOIDCCallbackBadNonce(t *testing.T) {
+	provider := newMockProvider(t)
+	provider.nonce = "someone-elses-nonce"
+	h := provider.handler()
+
+	state := testState("nonce-1", time.Now().Add(oidcStateTTL))
+	_, err := h.verifyOIDCCallback(context.Background(), callbackRequest(state.State, state.State), state)
+	if err == nil {
+		t.Fatal("verifyOIDCCallback accepted an ID token with the wrong nonce")
+	}
+}
+
+func TestVerifyOIDCCallbackExpiredState(t *testing.T) {
+	provider := newMockProvider(t)
+	provider.nonce = "nonce-1"
+	h := provider.handler()
+
+	state := testState("nonce-1", time.Now().Add(-time.Second))
+	_, err := h.verifyOIDCCallback(context.Background(), callbackRequest(state.State, state.State), state)
+	if !errors.Is(err, errOIDCStateExpired) {
+		t.Fatalf("err = %v, want errOIDCStateExpired", err)
+	}
+	if n := provider.exchanges.Load(); n != 0 {
+		t.Errorf("code was exchanged %d times for an expired state", n)
+	}
+}
+
+func TestVerifyOIDCCallbackStateCookie(t *testing.T) {
+	provider := newMockProvider(t)
+	provider.nonce = "nonce-1"
+	h := provider.handler()
+
+	state := testState("nonce-1", time.Now().Add(oidcStateTTL))
+	for name, cookie := range map[string]string{
+		"missing":  "",
+		"mismatch": "state-2",
+	} {
+		_, err := h.verifyOIDCCallback(context.Background(), callbackRequest(state.State, cookie), state)
+		if !errors.Is(err, errOIDCStateMismatch) {
+			t.Errorf("%s cookie: err = %v, want errOIDCStateMismatch", name, err)
+		}
+	}
+	if n := provider.exchanges.Load(); n != 0 {
+		t.Errorf("code was exchanged %d times without the state cookie", n)
+	}
+}
+
+func TestOIDCCallbackRequiresCodeAndState(t *testing.T) {
+	provider := newMockProvider(t)
+	h := provider.handler()
+	h.config.OIDCPostLoginRedirect = "http://localhost/login"
+
+	w := httptest.NewRecorder()
+	h.OIDCCallback(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?state=state-1", nil))
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+	if location := w.Header().Get("Location"); location != "http://localhost/login#error=Missing+code+or+state" {
+		t.Errorf("Location = %q", location)
+	}
+}
@@ -0,0 +1,659 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+	"jellystreaming/internal/secretbox"
+)
+
+// Jellyfin's defaults: an item stopped past 90% counts as played, and one
+// stopped before 5% is not worth resuming
+const (
+	playedThreshold = 0.9
+	resumeThreshold = 0.05
+)
+
+const (
+	defaultFeedSize = 20
+	maxFeedSize     = 100
+)
+
+// watchItemFields are the extra Jellyfin fields requested for feed items
+const watchItemFields = "Overview,PrimaryImageAspectRatio"
+
+type PlaybackHandler struct {
+	config *config.Config
+}
+
+func NewPlaybackHandler(cfg *config.Config) *PlaybackHandler {
+	return &PlaybackHandler{config: cfg}
+}
+
+// playbackViewer is the account a playback request is for
+type playbackViewer struct {
+	userID primitive.ObjectID
+	// linked is the account's Jellyfin user, which keeps its own playback
+	// state; empty when positions are stored in Mongo
+	linked string
+	// lookup is the Jellyfin user items are read as: the linked user, else
+	// the shared JELLYFIN_USER_ID
+	lookup string
+	// token is the linked user's Jellyfin access token from their last
+	// Jellyfin login, empty when there is none
+	token string
+}
+
+// viewer loads the caller's account. Accounts with no Jellyfin user to read
+// items as get a 403, as everywhere else items are read.
+func (h *PlaybackHandler) viewer(w http.ResponseWriter, r *http.Request) (*playbackViewer, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		http.Error(w, "Error loading user", http.StatusInternalServerError)
+		return nil, false
+	}
+	viewer := &playbackViewer{userID: user.ID, linked: user.JellyfinUserID, lookup: user.JellyfinUserID}
+	if viewer.linked != "" && user.JellyfinToken != "" {
+		// A token sealed under a previous JWT_SECRET cannot be opened; the
+		// viewer is treated as having none until their next Jellyfin login
+		if token, err := secretbox.Open(jellyfinTokenKey(h.config), user.JellyfinToken); err == nil {
+			viewer.token = token
+		}
+	}
+	if viewer.lookup == "" {
+		viewer.lookup = h.config.JellyfinUserID
+	}
+	if viewer.lookup == "" {
+		http.Error(w, errNoJellyfinUser.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	return viewer, true
+}
+
+// fetchWatchItems reads Jellyfin items by ID as a Jellyfin user, keyed by ID.
+// Items that no longer exist or the user cannot see are left out.
+func fetchWatchItems(cfg *config.Config, jellyfinUserID string, ids []string) (map[string]models.WatchItem, error) {
+	if jellyfinUserID == "" {
+		return nil, errNoJellyfinUser
+	}
+	items := make(map[string]models.WatchItem, len(ids))
+	if len(ids) == 0 {
+		return items, nil
+	}
+
+	var response models.WatchFeedResponse
+	err := jellyfinGetJSON(cfg, "/Items", url.Values{
+		"UserId": {jellyfinUserID},
+		"Ids":    {strings.Join(ids, ",")},
+		"Fields": {watchItemFields},
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range response.Items {
+		items[item.Id] = item
+	}
+	return items, nil
+}
+
+// fetchWatchItem reads one Jellyfin item, returning nil when it does not exist
+func (h *PlaybackHandler) fetchWatchItem(viewer *playbackViewer, itemID string) (*models.WatchItem, error) {
+	items, err := fetchWatchItems(h.config, viewer.lookup, []string{itemID})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		return &item, nil
+	}
+	return nil, nil
+}
+
+// finishedPosition returns the position to keep for an item stopped at
+// position, and whether it now counts as played
+func finishedPosition(position, runTimeTicks int64) (int64, bool) {
+	if runTimeTicks <= 0 {
+		return position, false
+	}
+	if float64(position) >= float64(runTimeTicks)*playedThreshold {
+		return 0, true
+	}
+	if float64(position) < float64(runTimeTicks)*resumeThreshold {
+		return 0, false
+	}
+	return position, false
+}
+
+// decodePlaybackReport reads and validates a playback report
+func decodePlaybackReport(w http.ResponseWriter, r *http.Request) (*models.PlaybackReport, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	var report models.PlaybackReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	if !jellyfinItemIDPattern.MatchString(report.ItemID) {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return nil, false
+	}
+	if report.PositionTicks < 0 {
+		http.Error(w, "positionTicks must not be negative", http.StatusBadRequest)
+		return nil, false
+	}
+	switch report.PlayMethod {
+	case "":
+		report.PlayMethod = "DirectStream"
+	case "DirectPlay", "DirectStream", "Transcode":
+	default:
+		http.Error(w, "playMethod must be DirectPlay, DirectStream or Transcode", http.StatusBadRequest)
+		return nil, false
+	}
+	return &report, true
+}
+
+// reportSession forwards a linked viewer's playback report to Jellyfin's
+// session API so the server dashboard shows the stream and stops its
+// transcode when it ends. It reports as the calling user with their own
+// Jellyfin token and returns true when Jellyfin accepted it; Jellyfin then
+// saves the user's playback state itself. Viewers without a token (linked by
+// an admin, or not signed in with Jellyfin since) are reported with the
+// server API key. Unlinked viewers have no Jellyfin user and are skipped.
+// Failures are logged; the position is still saved.
+func (h *PlaybackHandler) reportSession(viewer *playbackViewer, path string, report *models.PlaybackReport) bool {
+	if viewer.linked == "" {
+		return false
+	}
+	mediaSourceID := report.MediaSourceID
+	if mediaSourceID == "" {
+		mediaSourceID = report.ItemID
+	}
+	body := map[string]interface{}{
+		"ItemId":        report.ItemID,
+		"MediaSourceId": mediaSourceID,
+		"PlaySessionId": report.PlaySessionID,
+		"PositionTicks": report.PositionTicks,
+		"IsPaused":      report.IsPaused,
+		"PlayMethod":    report.PlayMethod,
+		"CanSeek":       true,
+	}
+
+	if viewer.token != "" {
+		err := jellyfinPostJSONAs(h.config, viewer.token, path, nil, body)
+		if err == nil {
+			return true
+		}
+		log.Printf("Error reporting playback to Jellyfin as %s (%s): %v", viewer.linked, path, err)
+		if statusErr, ok := err.(*jellyfinStatusError); ok && statusErr.StatusCode == http.StatusUnauthorized {
+			// The token was revoked in Jellyfin; stop using it
+			h.forgetJellyfinToken(viewer)
+		}
+	}
+
+	if err := jellyfinPostJSON(h.config, path, nil, body); err != nil {
+		log.Printf("Error reporting playback to Jellyfin (%s): %v", path, err)
+	}
+	return false
+}
+
+// forgetJellyfinToken removes a viewer's stored Jellyfin token
+func (h *PlaybackHandler) forgetJellyfinToken(viewer *playbackViewer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := database.UsersCollection.UpdateOne(ctx, bson.M{"_id": viewer.userID}, bson.M{
+		"$unset": bson.M{"jellyfinToken": ""},
+	})
+	if err != nil {
+		log.Printf("Error removing Jellyfin token of user %s: %v", viewer.userID.Hex(), err)
+	}
+	viewer.token = ""
+}
+
+// saveJellyfinUserData writes the linked user's playback state for an item
+// when the session report did not. Sessions reported with the server API key
+// belong to no Jellyfin user, so the position is saved on the user's item
+// data directly.
+func (h *PlaybackHandler) saveJellyfinUserData(viewer *playbackViewer, itemID string, userData map[string]interface{}) error {
+	userData["LastPlayedDate"] = time.Now().UTC().Format(time.RFC3339)
+	return jellyfinPostJSON(h.config, "/UserItems/"+itemID+"/UserData", url.Values{"userId": {viewer.linked}}, userData)
+}
+
+// saveProgress upserts the stored position of an unlinked viewer
+func saveProgress(ctx context.Context, viewer *playbackViewer, item *models.WatchItem, positionTicks int64, played bool) error {
+	_, err := database.PlaybackCollection.UpdateOne(ctx,
+		bson.M{"userId": viewer.userID, "itemId": item.Id},
+		bson.M{"$set": bson.M{
+			"itemType":      item.Type,
+			"seriesId":      item.SeriesId,
+			"positionTicks": positionTicks,
+			"runTimeTicks":  item.RunTimeTicks,
+			"played":        played,
+			"updatedAt":     time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// StartPlayback reports that playback started and returns the position to
+// resume from
+func (h *PlaybackHandler) StartPlayback(w http.ResponseWriter, r *http.Request) {
+	report, ok := decodePlaybackReport(w, r)
+	if !ok {
+		return
+	}
+	viewer, ok := h.viewer(w, r)
+	if !ok {
+		return
+	}
+
+	item, err := h.fetchWatchItem(viewer, report.ItemID)
+	if err != nil {
+		log.Printf("Error fetching Jellyfin item %s: %v", report.ItemID, err)
+		http.Error(w, fmt.Sprintf("Error fetching item: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if item == nil {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	h.reportSession(viewer, "/Sessions/Playing", report)
+
+	state := models.PlaybackState{ItemID: item.Id}
+	if viewer.linked != "" {
+		if item.UserData != nil {
+			state.PositionTicks = item.UserData.PlaybackPositionTicks
+			state.Played = item.UserData.Played
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var progress models.PlaybackProgress
+		err := database.PlaybackCollection.FindOne(ctx, bson.M{"userId": viewer.userID, "itemId": item.Id}).Decode(&progress)
+		if err != nil && err != mongo.ErrNoDocuments {
+			http.Error(w, "Error loading playback progress", http.StatusInternalServerError)
+			return
+		}
+		state.PositionTicks, state.Played = progress.PositionTicks, progress.Played
+
+		if err := saveProgress(ctx, viewer, item, progress.PositionTicks, progress.Played); err != nil {
+			http.Error(w, "Error saving playback progress", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// ReportProgress saves the current position, sent periodically and on pause
+func (h *PlaybackHandler) ReportProgress(w http.ResponseWriter, r *http.Request) {
+	report, ok := decodePlaybackReport(w, r)
+	if !ok {
+		return
+	}
+	viewer, ok := h.viewer(w, r)
+	if !ok {
+		return
+	}
+
+	reported := h.reportSession(viewer, "/Sessions/Playing/Progress", report)
+
+	if reported {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if viewer.linked != "" {
+		err := h.saveJellyfinUserData(viewer, report.ItemID, map[string]interface{}{
+			"PlaybackPositionTicks": report.PositionTicks,
+		})
+		if err != nil {
+			log.Printf("Error saving Jellyfin playback progress for %s: %v", report.ItemID, err)
+			http.Error(w, "Error saving playback progress", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := database.PlaybackCollection.UpdateOne(ctx,
+		bson.M{"userId": viewer.userID, "itemId": report.ItemID},
+		bson.M{"$set": bson.M{"positionTicks": report.PositionTicks, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		http.Error(w, "Error saving playback progress", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		// Playback was not started through the API; store the item now
+		item, err := h.fetchWatchItem(viewer, report.ItemID)
+		if err != nil {
+			log.Printf("Error fetching Jellyfin item %s: %v", report.ItemID, err)
+			http.Error(w, fmt.Sprintf("Error fetching item: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if item == nil {
+			http.Error(w, "Item not found", http.StatusNotFound)
+			return
+		}
+		if err := saveProgress(ctx, viewer, item, report.PositionTicks, false); err != nil {
+			http.Error(w, "Error saving playback progress", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StopPlayback reports that playback stopped and saves the final position,
+// marking the item played when it was watched to the end
+func (h *PlaybackHandler) StopPlayback(w http.ResponseWriter, r *http.Request) {
+	report, ok := decodePlaybackReport(w, r)
+	if !ok {
+		return
+	}
+	viewer, ok := h.viewer(w, r)
+	if !ok {
+		return
+	}
+
+	reported := h.reportSession(viewer, "/Sessions/Playing/Stopped", report)
+
+	item, err := h.fetchWatchItem(viewer, report.ItemID)
+	if err != nil {
+		log.Printf("Error fetching Jellyfin item %s: %v", report.ItemID, err)
+		http.Error(w, fmt.Sprintf("Error fetching item: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if item == nil {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	position, played := finishedPosition(report.PositionTicks, item.RunTimeTicks)
+	if reported {
+		// Jellyfin saved the stop as the user; the item read above already
+		// reflects it
+		if !played && item.UserData != nil {
+			played = item.UserData.Played
+		}
+	} else if viewer.linked != "" {
+		userData := map[string]interface{}{"PlaybackPositionTicks": position}
+		if played {
+			playCount := 0
+			if item.UserData != nil {
+				playCount = item.UserData.PlayCount
+			}
+			userData["Played"] = true
+			userData["PlayCount"] = playCount + 1
+		}
+		if err := h.saveJellyfinUserData(viewer, item.Id, userData); err != nil {
+			log.Printf("Error saving Jellyfin playback progress for %s: %v", item.Id, err)
+			http.Error(w, "Error saving playback progress", http.StatusInternalServerError)
+			return
+		}
+		if !played && item.UserData != nil {
+			played = item.UserData.Played
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if !played {
+			// Stopping a rewatch early keeps an item played
+			var progress models.PlaybackProgress
+			err := database.PlaybackCollection.FindOne(ctx, bson.M{"userId": viewer.userID, "itemId": item.Id}).Decode(&progress)
+			if err != nil && err != mongo.ErrNoDocuments {
+				http.Error(w, "Error loading playback progress", http.StatusInternalServerError)
+				return
+			}
+			played = progress.Played
+		}
+		if err := saveProgress(ctx, viewer, item, position, played); err != nil {
+			http.Error(w, "Error saving playback progress", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.PlaybackState{ItemID: item.Id, PositionTicks: position, Played: played})
+}
+
+// feedLimit reads ?limit for the watch feeds
+func feedLimit(r *http.Request) int {
+	limit := queryInt(r, "limit", defaultFeedSize)
+	if limit < 1 {
+		return defaultFeedSize
+	}
+	if limit > maxFeedSize {
+		return maxFeedSize
+	}
+	return limit
+}
+
+// progressUserData is an unlinked viewer's playback state in Jellyfin's shape
+func progressUserData(progress models.PlaybackProgress) *models.JellyfinUserData {
+	userData := &models.JellyfinUserData{
+		PlaybackPositionTicks: progress.PositionTicks,
+		Played:                progress.Played,
+		LastPlayedDate:        progress.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if progress.Played {
+		userData.PlayCount = 1
+	}
+	if progress.RunTimeTicks > 0 {
+		userData.PlayedPercentage = float64(progress.PositionTicks) / float64(progress.RunTimeTicks) * 100
+	}
+	return userData
+}
+
+// writeFeed writes a watch feed, as an empty list rather than null
+func writeFeed(w http.ResponseWriter, items []models.WatchItem) {
+	if items == nil {
+		items = []models.WatchItem{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.WatchFeedResponse{Items: items})
+}
+
+// ContinueWatching lists the movies and episodes the caller started but did
+// not finish, most recently watched first
+func (h *PlaybackHandler) ContinueWatching(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	viewer, ok := h.viewer(w, r)
+	if !ok {
+		return
+	}
+	limit := feedLimit(r)
+
+	if viewer.linked != "" {
+		var feed models.WatchFeedResponse
+		err := jellyfinGetJSON(h.config, "/Items", url.Values{
+			"UserId":           {viewer.linked},
+			"Filters":          {"IsResumable"},
+			"IncludeItemTypes": {"Movie,Episode"},
+			"Recursive":        {"true"},
+			"SortBy":           {"DatePlayed"},
+			"SortOrder":        {"Descending"},
+			"Fields":           {watchItemFields},
+			"Limit":            {fmt.Sprint(limit)},
+		}, &feed)
+		if err != nil {
+			log.Printf("Error fetching continue watching: %v", err)
+			http.Error(w, fmt.Sprintf("Error fetching continue watching: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeFeed(w, feed.Items)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := database.PlaybackCollection.Find(ctx,
+		bson.M{"userId": viewer.userID, "played": false, "positionTicks": bson.M{"$gt": 0}},
+		options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		http.Error(w, "Error loading playback progress", http.StatusInternalServerError)
+		return
+	}
+	var entries []models.PlaybackProgress
+	if err := cursor.All(ctx, &entries); err != nil {
+		http.Error(w, "Error loading playback progress", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ItemID)
+	}
+	found, err := fetchWatchItems(h.config, viewer.lookup, ids)
+	if err != nil {
+		log.Printf("Error fetching continue watching: %v", err)
+		http.Error(w, fmt.Sprintf("Error fetching continue watching: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var items []models.WatchItem
+	for _, entry := range entries {
+		item, ok := found[entry.ItemID]
+		if !ok {
+			continue
+		}
+		item.UserData = progressUserData(entry)
+		items = append(items, item)
+	}
+	writeFeed(w, items)
+}
+
+// NextUp lists, for each series the caller is watching, the first episode
+// after the last one they finished, most recently watched series first
+func (h *PlaybackHandler) NextUp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	viewer, ok := h.viewer(w, r)
+	if !ok {
+		return
+	}
+	limit := feedLimit(r)
+
+	if viewer.linked != "" {
+		var feed models.WatchFeedResponse
+		err := jellyfinGetJSON(h.config, "/Shows/NextUp", url.Values{
+			"UserId": {viewer.linked},
+			"Fields": {watchItemFields},
+			"Limit":  {fmt.Sprint(limit)},
+		}, &feed)
+		if err != nil {
+			log.Printf("Error fetching next up: %v", err)
+			http.Error(w, fmt.Sprintf("Error fetching next up: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeFeed(w, feed.Items)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := database.PlaybackCollection.Find(ctx,
+		bson.M{"userId": viewer.userID, "itemType": "Episode", "seriesId": bson.M{"$ne": ""}},
+		options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}),
+	)
+	if err != nil {
+		http.Error(w, "Error loading playback progress", http.StatusInternalServerError)
+		return
+	}
+	var entries []models.PlaybackProgress
+	if err := cursor.All(ctx, &entries); err != nil {
+		http.Error(w, "Error loading playback progress", http.StatusInternalServerError)
+		return
+	}
+
+	// The most recently finished episode of each series, and the state of
+	// every episode the viewer touched
+	progress := make(map[string]models.PlaybackProgress, len(entries))
+	var series []string
+	lastPlayed := map[string]string{}
+	for _, entry := range entries {
+		progress[entry.ItemID] = entry
+		if _, ok := lastPlayed[entry.SeriesID]; !ok && entry.Played {
+			lastPlayed[entry.SeriesID] = entry.ItemID
+			series = append(series, entry.SeriesID)
+		}
+	}
+
+	var items []models.WatchItem
+	for _, seriesID := range series {
+		if len(items) >= limit {
+			break
+		}
+		episodes, err := fetchEpisodes(h.config, viewer.lookup, seriesID, "")
+		if err != nil {
+			if statusErr, ok := err.(*jellyfinStatusError); ok && statusErr.StatusCode == http.StatusNotFound {
+				continue
+			}
+			log.Printf("Error fetching next up: %v", err)
+			http.Error(w, fmt.Sprintf("Error fetching next up: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		next, _ := episodeAfter(episodes.Items, lastPlayed[seriesID])
+		for next != nil && progress[next.Id].Played {
+			next, _ = episodeAfter(episodes.Items, next.Id)
+		}
+		if next == nil {
+			continue
+		}
+
+		item := models.WatchItem{
+			Name:              next.Name,
+			Id:                next.Id,
+			Type:              "Episode",
+			SeriesId:          next.SeriesId,
+			SeriesName:        next.SeriesName,
+			SeasonId:          next.SeasonId,
+			IndexNumber:       next.IndexNumber,
+			ParentIndexNumber: next.ParentIndexNumber,
+			Overview:          next.Overview,
+			RunTimeTicks:      next.RunTimeTicks,
+			ImageTags:         next.ImageTags,
+		}
+		if entry, ok := progress[next.Id]; ok {
+			item.UserData = progressUserData(entry)
+		}
+		items = append(items, item)
+	}
+	writeFeed(w, items)
+}
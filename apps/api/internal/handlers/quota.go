@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+)
+
+// quotaCountedStatuses are the request states that use up quota; declined
+// and failed requests give it back
+var quotaCountedStatuses = bson.A{models.RequestStatusPending, models.RequestStatusApproved}
+
+// userQuotaStatus computes a user's quota and usage over the rolling window
+func userQuotaStatus(ctx context.Context, cfg *config.Config, userID primitive.ObjectID) (*models.QuotaStatus, error) {
+	var user models.User
+	if err := database.UsersCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	quota := models.RequestQuota{MovieLimit: cfg.QuotaMovieLimit, SeasonLimit: cfg.QuotaSeasonLimit, Days: cfg.QuotaDays}
+	if user.Quota != nil {
+		quota = *user.Quota
+	}
+	if quota.Days < 1 {
+		quota.Days = 7
+	}
+
+	status := &models.QuotaStatus{
+		Days:    quota.Days,
+		Exempt:  user.IsAdmin,
+		Custom:  user.Quota != nil,
+		Movies:  models.QuotaUsage{Limit: quota.MovieLimit},
+		Seasons: models.QuotaUsage{Limit: quota.SeasonLimit},
+	}
+
+	window := time.Duration(quota.Days) * 24 * time.Hour
+	cursor, err := database.RequestsCollection.Find(ctx, bson.M{
+		"requestedBy": userID,
+		"status":      bson.M{"$in": quotaCountedStatuses},
+		"createdAt":   bson.M{"$gte": time.Now().Add(-window)},
+	}, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetProjection(bson.M{"mediaType": 1, "seasonCount": 1, "createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []models.MediaRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+
+	for _, request := range requests {
+		usage, amount := &status.Movies, 1
+		if request.MediaType == models.MediaTypeTV {
+			usage, amount = &status.Seasons, request.SeasonCount
+		}
+		usage.Used += amount
+		if usage.ResetsAt == nil {
+			resetsAt := request.CreatedAt.Add(window)
+			usage.ResetsAt = &resetsAt
+		}
+	}
+
+	for _, usage := range []*models.QuotaUsage{&status.Movies, &status.Seasons} {
+		if status.Exempt || usage.Limit == 0 {
+			usage.Unlimited = true
+			usage.Limit = 0
+			usage.ResetsAt = nil
+			continue
+		}
+		usage.Remaining = usage.Limit - usage.Used
+		if usage.Remaining < 0 {
+			usage.Remaining = 0
+		}
+	}
+
+	return status, nil
+}
+
+// checkQuota reports whether the caller may request one movie or the given
+// number of seasons. When they may not, a 429 has been written with the
+// remaining quota and when it resets.
+func checkQuota(ctx context.Context, cfg *config.Config, w http.ResponseWriter, r *http.Request, mediaType string, seasons int) bool {
+	if err := quotaExceeded(ctx, cfg, r, mediaType, seasons); err != nil {
+		err.write(w)
+		return false
+	}
+	return true
+}
+
+// quotaExceeded returns nil when the caller may request one movie or the
+// given number of seasons, and otherwise the error to report
+func quotaExceeded(ctx context.Context, cfg *config.Config, r *http.Request, mediaType string, seasons int) *requestError {
+	userID, _ := r.Context().Value("userID").(string)
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return &requestError{Status: http.StatusBadRequest, Message: "Invalid user ID"}
+	}
+
+	status, err := userQuotaStatus(ctx, cfg, objectID)
+	if err != nil {
+		return &requestError{Status: http.StatusInternalServerError, Message: "Error checking request quota"}
+	}
+
+	usage, amount, unit := status.Movies, 1, "movie"
+	if mediaType == models.MediaTypeTV {
+		usage, amount, unit = status.Seasons, seasons, "season"
+	}
+	if usage.Unlimited || amount <= usage.Remaining {
+		return nil
+	}
+
+	exceeded := &requestError{Status: http.StatusTooManyRequests}
+	exceeded.Message = fmt.Sprintf("Request quota exceeded: %d of %d %ss used in the last %d days, %d remaining",
+		usage.Used, usage.Limit, unit, status.Days, usage.Remaining)
+	if amount > 1 {
+		exceeded.Message += fmt.Sprintf(" (this request needs %d)", amount)
+	}
+	if usage.ResetsAt != nil {
+		seconds := int(math.Ceil(time.Until(*usage.ResetsAt).Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		exceeded.RetryAfter = seconds
+		exceeded.Message += fmt.Sprintf("; next %s frees up at %s", unit, usage.ResetsAt.UTC().Format(time.RFC3339))
+	}
+	return exceeded
+}
+
+// GetQuota returns the current user's request quota and usage
+func (h *AuthHandler) GetQuota(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := r.Context().Value("userID").(string)
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status, err := userQuotaStatus(ctx, h.config, objectID)
+	if err != nil {
+		http.Error(w, "Error fetching request quota", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
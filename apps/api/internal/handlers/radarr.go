@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"jellystreaming/internal/config"
+	"jellystreaming/internal/middleware"
 	"jellystreaming/internal/models"
 )
 
@@ -23,6 +26,16 @@ func NewRadarrHandler(cfg *config.Config) *RadarrHandler {
 	return &RadarrHandler{config: cfg}
 }
 
+// containsInt reports whether list contains value
+func containsInt(list []int, value int) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
 // AddMovie handles adding a movie to Radarr
 func (h *RadarrHandler) AddMovie(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
@@ -36,7 +49,52 @@ func (h *RadarrHandler) AddMovie(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set default values
+	h.applyMovieDefaults(&req)
+	if containsInt(h.config.Radarr4KQualityProfileIDs, req.QualityProfileId) && !middleware.HasPermission(r, models.PermissionRequest4K) {
+		http.Error(w, "Permission required: "+models.PermissionRequest4K, http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !checkQuota(ctx, h.config, w, r, models.MediaTypeMovie, 0) {
+		return
+	}
+
+	status, body, err := h.addMovie(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error calling Radarr: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if status < http.StatusMultipleChoices {
+		recordDirectRequest(r, models.MediaRequest{
+			MediaType:        models.MediaTypeMovie,
+			TMDBID:           req.TmdbId,
+			Title:            req.Title,
+			Year:             req.Year,
+			QualityProfileID: req.QualityProfileId,
+			RootFolderPath:   req.RootFolderPath,
+		})
+	}
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action: models.AuditMovieAdd,
+		Target: models.AuditTarget{Type: "movie", ID: strconv.Itoa(req.TmdbId), Name: req.Title},
+		Changes: auditChanges(nil, map[string]interface{}{
+			"qualityProfileId": req.QualityProfileId,
+			"rootFolderPath":   req.RootFolderPath,
+		}),
+		Status: status,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// applyMovieDefaults fills in the fields Radarr needs that callers may omit
+func (h *RadarrHandler) applyMovieDefaults(req *models.RadarrAddMovieRequest) {
 	if req.QualityProfileId == 0 {
 		req.QualityProfileId = 1
 	}
@@ -49,39 +107,31 @@ func (h *RadarrHandler) AddMovie(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	req.Monitored = true
+}
 
-	jsonData, err := json.Marshal(req)
+// addMovie adds a movie to Radarr and returns Radarr's status and response body
+func (h *RadarrHandler) addMovie(req *models.RadarrAddMovieRequest) (int, []byte, error) {
+	status, body, err := arrRequest(h.config.RadarrURL, h.config.RadarrAPIKey, "POST", "/api/v3/movie", req, 30*time.Second)
 	if err != nil {
-		http.Error(w, "Error encoding request", http.StatusInternalServerError)
-		return
+		return 0, nil, err
 	}
 
-	radarrURL := fmt.Sprintf("%s/api/v3/movie", h.config.RadarrURL)
-	client := &http.Client{Timeout: 30 * time.Second}
+	log.Printf("Radarr add movie response status: %d", status)
+	log.Printf("Radarr response body: %s", string(body))
+	return status, body, nil
+}
 
-	httpReq, err := http.NewRequest("POST", radarrURL, bytes.NewReader(jsonData))
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating request: %v", err), http.StatusInternalServerError)
-		return
+// lookupMovie resolves a TMDB ID to the movie details Radarr needs to add it
+func (h *RadarrHandler) lookupMovie(tmdbID int) (*models.RadarrAddMovieRequest, error) {
+	var movie models.RadarrAddMovieRequest
+	path := fmt.Sprintf("/api/v3/movie/lookup/tmdb?tmdbId=%d", tmdbID)
+	if err := arrGetJSON(h.config.RadarrURL, h.config.RadarrAPIKey, path, &movie); err != nil {
+		return nil, err
 	}
-
-	httpReq.Header.Set("X-Api-Key", h.config.RadarrAPIKey)
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error calling Radarr: %v", err), http.StatusInternalServerError)
-		return
+	if movie.TmdbId == 0 {
+		return nil, fmt.Errorf("radarr found no movie with TMDB ID %d", tmdbID)
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("Radarr add movie response status: %d", resp.StatusCode)
-	log.Printf("Radarr response body: %s", string(body))
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	w.Write(body)
+	return &movie, nil
 }
 
 // GetQueue handles Radarr queue requests
@@ -209,6 +259,13 @@ func (h *RadarrHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action: models.AuditRadarrRefresh,
+		Target: models.AuditTarget{Type: "radarr", Name: "RefreshMonitoredDownloads"},
+		Status: resp.StatusCode,
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(resp.StatusCode)
 	w.Write(body)
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/middleware"
+	"jellystreaming/internal/models"
+)
+
+// usernamePattern restricts self-chosen usernames to characters that are
+// safe in URLs and Jellyfin
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{1,63}$`)
+
+// Register creates an account by redeeming an invite code and signs the new
+// user in. Failed redemptions count against the client IP like failed logins.
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	code := normalizeRecoveryCode(req.Code)
+	if code == "" || req.Username == "" || req.Password == "" {
+		http.Error(w, "Invite code, username and password required", http.StatusBadRequest)
+		return
+	}
+	if !usernamePattern.MatchString(req.Username) {
+		http.Error(w, "Username must be 2-64 letters, digits, '.', '_', '@' or '-'", http.StatusBadRequest)
+		return
+	}
+	if err := passwordPolicy(h.config).Validate(req.Password, req.Username); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ipKey := database.IPThrottleKey(middleware.ClientIP(r))
+	wait, err := database.LoginLockedFor(ctx, ipKey)
+	if err != nil {
+		log.Printf("Error checking registration throttle: %v", err)
+	} else if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		http.Error(w, fmt.Sprintf("Too many failed attempts, try again in %d seconds", seconds), http.StatusTooManyRequests)
+		return
+	}
+
+	count, err := database.UsersCollection.CountDocuments(ctx, bson.M{"username": req.Username})
+	if err != nil {
+		http.Error(w, "Error checking username", http.StatusInternalServerError)
+		return
+	}
+	if count > 0 {
+		http.Error(w, "Username already exists", http.StatusConflict)
+		return
+	}
+
+	invite, err := redeemInvite(ctx, code)
+	if errors.Is(err, errInviteNotRedeemable) {
+		h.logFailedLogin(ctx, r, req.Username, models.FailedLoginInvalidInvite)
+		if _, err := database.RecordLoginFailure(ctx, ipKey, h.config.LoginIPMaxAttempts,
+			h.config.LoginBackoffBase, h.config.LoginLockoutMax, h.config.LoginFailureWindow); err != nil {
+			log.Printf("Error recording registration failure: %v", err)
+		}
+		http.Error(w, "Invalid or expired invite code", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("Error redeeming invite: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	hashedPassword, err := database.HashPassword(req.Password)
+	if err != nil {
+		releaseInvite(ctx, invite.ID)
+		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	user := models.User{
+		Username:  req.Username,
+		Email:     req.Email,
+		Password:  hashedPassword,
+		Quota:     invite.Quota,
+		InviteID:  &invite.ID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if invite.Role != "" {
+		user.Roles = []string{invite.Role}
+	}
+
+	result, err := database.UsersCollection.InsertOne(ctx, user)
+	if err != nil {
+		releaseInvite(ctx, invite.ID)
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "Username already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("Error creating user: %v", err)
+		http.Error(w, "Error creating user", http.StatusInternalServerError)
+		return
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+
+	if _, err := database.InvitesCollection.UpdateOne(ctx, bson.M{"_id": invite.ID}, bson.M{
+		"$push": bson.M{"redemptions": models.InviteRedemption{
+			UserID:     user.ID,
+			Username:   user.Username,
+			IP:         middleware.ClientIP(r),
+			RedeemedAt: now,
+		}},
+	}); err != nil {
+		log.Printf("Error recording redemption of invite %s: %v", invite.ID.Hex(), err)
+	}
+
+	log.Printf("Registered user %s with invite %s", user.Username, invite.ID.Hex())
+
+	response, err := h.startSession(r, &user)
+	if err != nil {
+		log.Printf("Error starting session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,853 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/events"
+	"jellystreaming/internal/middleware"
+	"jellystreaming/internal/models"
+)
+
+// errRequestNotReviewable is returned when a request was already handled
+var errRequestNotReviewable = errors.New("request is no longer pending")
+
+// RequestHandler handles the media request workflow
+type RequestHandler struct {
+	config *config.Config
+	radarr *RadarrHandler
+	sonarr *SonarrHandler
+}
+
+// NewRequestHandler creates a new RequestHandler
+func NewRequestHandler(cfg *config.Config) *RequestHandler {
+	return &RequestHandler{
+		config: cfg,
+		radarr: NewRadarrHandler(cfg),
+		sonarr: NewSonarrHandler(cfg),
+	}
+}
+
+// requestIDFromPath extracts the request ID from /api/requests/{id}[/action]
+func requestIDFromPath(p string) (primitive.ObjectID, error) {
+	id, _, _ := strings.Cut(strings.TrimPrefix(p, "/api/requests/"), "/")
+	return primitive.ObjectIDFromHex(id)
+}
+
+// requestAuditTarget identifies a media request in audit events
+func requestAuditTarget(request *models.MediaRequest) models.AuditTarget {
+	return models.AuditTarget{Type: "request", ID: request.ID.Hex(), Name: request.Title}
+}
+
+// ListRequests returns the caller's requests, or every request for users with
+// requests.manage (unless ?mine=true). Filters: status, mediaType.
+func (h *RequestHandler) ListRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := bson.M{}
+	if !middleware.HasPermission(r, models.PermissionRequestsManage) || query.Get("mine") == "true" {
+		userID, _ := r.Context().Value("userID").(string)
+		objectID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		filter["requestedBy"] = objectID
+	}
+	if status := query.Get("status"); status != "" {
+		filter["status"] = status
+	}
+	if mediaType := query.Get("mediaType"); mediaType != "" {
+		filter["mediaType"] = mediaType
+	}
+
+	limit := queryInt(r, "limit", 50)
+	if limit == 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	skip := queryInt(r, "skip", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := database.RequestsCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		http.Error(w, "Error counting requests", http.StatusInternalServerError)
+		return
+	}
+
+	cursor, err := database.RequestsCollection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)))
+	if err != nil {
+		http.Error(w, "Error fetching requests", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	response := models.MediaRequestsResponse{Items: []models.MediaRequest{}, Total: total}
+	if err := cursor.All(ctx, &response.Items); err != nil {
+		http.Error(w, "Error decoding requests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// requestError is a media request that cannot be created, with the HTTP
+// status and message to report
+type requestError struct {
+	Status     int
+	Message    string
+	RetryAfter int // Seconds until the quota frees up, when it is exhausted
+}
+
+func (e *requestError) Error() string {
+	return e.Message
+}
+
+// write reports the error as the response
+func (e *requestError) write(w http.ResponseWriter) {
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(e.RetryAfter))
+	}
+	http.Error(w, e.Message, e.Status)
+}
+
+// CreateRequest records a pending request; users with request.autoapprove
+// have it approved immediately
+func (h *RequestHandler) CreateRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.CreateMediaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	request, reqErr := h.create(r, req)
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(request)
+}
+
+// create records a request for the caller after checking their permissions,
+// open requests for the title and their quota, and approves it straight away
+// for users with request.autoapprove
+func (h *RequestHandler) create(r *http.Request, req models.CreateMediaRequest) (*models.MediaRequest, *requestError) {
+	switch req.MediaType {
+	case models.MediaTypeMovie:
+		if !middleware.HasPermission(r, models.PermissionRequestMovie) {
+			return nil, &requestError{Status: http.StatusForbidden, Message: "Permission required: " + models.PermissionRequestMovie}
+		}
+		if req.TMDBID <= 0 {
+			return nil, &requestError{Status: http.StatusBadRequest, Message: "tmdbId required"}
+		}
+		req.TVDBID = 0
+		req.Seasons = nil
+	case models.MediaTypeTV:
+		if !middleware.HasPermission(r, models.PermissionRequestTV) {
+			return nil, &requestError{Status: http.StatusForbidden, Message: "Permission required: " + models.PermissionRequestTV}
+		}
+		if req.TMDBID <= 0 && req.TVDBID <= 0 {
+			return nil, &requestError{Status: http.StatusBadRequest, Message: "tmdbId or tvdbId required"}
+		}
+	default:
+		return nil, &requestError{Status: http.StatusBadRequest, Message: "mediaType must be movie or tv"}
+	}
+
+	seasons := []int{}
+	seen := make(map[int]bool)
+	for _, season := range req.Seasons {
+		if season < 0 {
+			return nil, &requestError{Status: http.StatusBadRequest, Message: "Season numbers cannot be negative"}
+		}
+		if !seen[season] {
+			seen[season] = true
+			seasons = append(seasons, season)
+		}
+	}
+	sort.Ints(seasons)
+
+	userID, _ := r.Context().Value("userID").(string)
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, &requestError{Status: http.StatusBadRequest, Message: "Invalid user ID"}
+	}
+	username, _ := r.Context().Value("username").(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// One open request per title; movies stay unique once approved
+	duplicate := bson.M{"mediaType": req.MediaType}
+	if req.TMDBID > 0 {
+		duplicate["tmdbId"] = req.TMDBID
+	} else {
+		duplicate["tvdbId"] = req.TVDBID
+	}
+	if req.MediaType == models.MediaTypeMovie {
+		duplicate["status"] = bson.M{"$in": bson.A{models.RequestStatusPending, models.RequestStatusApproved}}
+	} else {
+		duplicate["status"] = models.RequestStatusPending
+	}
+	count, err := database.RequestsCollection.CountDocuments(ctx, duplicate)
+	if err != nil {
+		return nil, &requestError{Status: http.StatusInternalServerError, Message: "Error checking existing requests"}
+	}
+	if count > 0 {
+		return nil, &requestError{Status: http.StatusConflict, Message: "This title has already been requested"}
+	}
+
+	seasonCount := 0
+	if req.MediaType == models.MediaTypeTV {
+		seasonCount = h.requestedSeasonCount(req.TMDBID, req.TVDBID, seasons)
+	}
+	if reqErr := quotaExceeded(ctx, h.config, r, req.MediaType, seasonCount); reqErr != nil {
+		return nil, reqErr
+	}
+
+	now := time.Now()
+	request := models.MediaRequest{
+		MediaType:           req.MediaType,
+		TMDBID:              req.TMDBID,
+		TVDBID:              req.TVDBID,
+		Title:               strings.TrimSpace(req.Title),
+		Year:                req.Year,
+		PosterPath:          req.PosterPath,
+		SeasonCount:         seasonCount,
+		Status:              models.RequestStatusPending,
+		RequestedBy:         objectID,
+		RequestedByUsername: username,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	if len(seasons) > 0 {
+		request.Seasons = seasons
+	}
+
+	result, err := database.RequestsCollection.InsertOne(ctx, request)
+	if err != nil {
+		log.Printf("Error creating request: %v", err)
+		return nil, &requestError{Status: http.StatusInternalServerError, Message: "Error creating request"}
+	}
+	request.ID = result.InsertedID.(primitive.ObjectID)
+
+	if middleware.HasPermission(r, models.PermissionRequestAutoApprove) {
+		approved, err := h.approve(ctx, r, request.ID, models.ApproveMediaRequest{})
+		if err != nil {
+			log.Printf("Error auto-approving request %s: %v", request.ID.Hex(), err)
+		}
+		if approved != nil {
+			request = *approved
+		}
+	}
+
+	return &request, nil
+}
+
+// SubscribeEvents marks approved requests available when Jellyfin reports
+// their movie, or a series, season or episode of their show, was added to
+// the library
+func (h *RequestHandler) SubscribeEvents() {
+	events.Subscribe(events.JellyfinItemAdded, h.markRequestsAvailable)
+}
+
+// markRequestsAvailable handles a Jellyfin ItemAdded event, matching approved
+// requests on the item's TMDB or TVDB ID. Seasons and episodes are matched on
+// their series' IDs, and only against requests for every season or for the
+// season that was added.
+func (h *RequestHandler) markRequestsAvailable(event events.Event) {
+	item, ok := event.Payload.(*models.JellyfinEvent)
+	if !ok {
+		return
+	}
+
+	mediaType := ""
+	providerIDs := item.ProviderIds
+	jellyfinItemID := item.ItemID
+	filter := bson.M{}
+	switch item.ItemType {
+	case "Movie":
+		mediaType = models.MediaTypeMovie
+	case "Series":
+		mediaType = models.MediaTypeTV
+	case "Season", "Episode":
+		if item.SeriesID == "" {
+			return
+		}
+		mediaType = models.MediaTypeTV
+		jellyfinItemID = item.SeriesID
+
+		var err error
+		providerIDs, err = jellyfinSeriesProviderIDs(h.config, item.SeriesID)
+		if err != nil {
+			log.Printf("Error looking up series of %s: %v", item.Name, err)
+			return
+		}
+		if item.SeasonNumber > 0 {
+			filter["$and"] = bson.A{bson.M{"$or": bson.A{
+				bson.M{"seasons": bson.M{"$exists": false}},
+				bson.M{"seasons": bson.M{"$size": 0}},
+				bson.M{"seasons": item.SeasonNumber},
+			}}}
+		}
+	default:
+		return
+	}
+
+	ids := bson.A{}
+	if tmdbID, err := strconv.Atoi(providerIDs["Tmdb"]); err == nil {
+		ids = append(ids, bson.M{"tmdbId": tmdbID})
+	}
+	if tvdbID, err := strconv.Atoi(providerIDs["Tvdb"]); err == nil && mediaType == models.MediaTypeTV {
+		ids = append(ids, bson.M{"tvdbId": tvdbID})
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	filter["mediaType"] = mediaType
+	filter["status"] = models.RequestStatusApproved
+	filter["availableAt"] = bson.M{"$exists": false}
+	filter["$or"] = ids
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := database.RequestsCollection.Find(ctx, filter)
+	if err != nil {
+		log.Printf("Error finding requests for %s: %v", item.Name, err)
+		return
+	}
+	var requests []models.MediaRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		log.Printf("Error decoding requests for %s: %v", item.Name, err)
+		return
+	}
+
+	now := time.Now()
+	for i := range requests {
+		request := &requests[i]
+		result, err := database.RequestsCollection.UpdateOne(ctx,
+			bson.M{"_id": request.ID, "availableAt": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{
+				"availableAt":    now,
+				"jellyfinItemId": jellyfinItemID,
+				"updatedAt":      now,
+			}})
+		if err != nil {
+			log.Printf("Error marking request %s available: %v", request.ID.Hex(), err)
+			continue
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		request.AvailableAt = &now
+		request.JellyfinItemID = jellyfinItemID
+		request.UpdatedAt = now
+		log.Printf("Request %s for %s is available", request.ID.Hex(), request.Title)
+		events.Publish(events.RequestAvailable, request)
+	}
+}
+
+// jellyfinSeriesProviderIDs fetches the provider IDs of a Jellyfin series,
+// keyed like JellyfinEvent.ProviderIds
+func jellyfinSeriesProviderIDs(cfg *config.Config, seriesID string) (map[string]string, error) {
+	var response struct {
+		Items []struct {
+			ProviderIds map[string]string `json:"ProviderIds"`
+		} `json:"Items"`
+	}
+	query := url.Values{"Ids": {seriesID}, "Fields": {"ProviderIds"}}
+	if err := jellyfinGetJSON(cfg, "/Items", query, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Items) == 0 {
+		return nil, fmt.Errorf("series %s not found", seriesID)
+	}
+	return response.Items[0].ProviderIds, nil
+}
+
+// requestedSeasonCount is how many seasons a TV request counts against the
+// quota. A request for every season is counted from Sonarr's lookup.
+func (h *RequestHandler) requestedSeasonCount(tmdbID, tvdbID int, seasons []int) int {
+	if len(seasons) > 0 {
+		return len(seasons)
+	}
+
+	term := "tmdb:" + strconv.Itoa(tmdbID)
+	if tvdbID > 0 {
+		term = "tvdb:" + strconv.Itoa(tvdbID)
+	}
+	series, err := h.sonarr.lookupSeries(term)
+	if err != nil {
+		log.Printf("Error counting seasons for %s: %v", term, err)
+		return 1
+	}
+	return monitoredSeasonCount(series.Seasons, true)
+}
+
+// monitoredSeasonCount counts the regular (non-special) seasons that are
+// monitored, or all of them when all is set; at least one is counted
+func monitoredSeasonCount(seasons []models.SonarrSeason, all bool) int {
+	count := 0
+	for _, season := range seasons {
+		if season.SeasonNumber > 0 && (all || season.Monitored) {
+			count++
+		}
+	}
+	if count == 0 {
+		return 1
+	}
+	return count
+}
+
+// recordDirectRequest stores a movie or show added straight to Radarr or
+// Sonarr as an approved request, so it appears in the caller's history and
+// counts against their quota
+func recordDirectRequest(r *http.Request, request models.MediaRequest) {
+	userID, _ := r.Context().Value("userID").(string)
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return
+	}
+	username, _ := r.Context().Value("username").(string)
+
+	now := time.Now()
+	request.Status = models.RequestStatusApproved
+	request.RequestedBy = objectID
+	request.RequestedByUsername = username
+	request.ReviewedBy = username
+	request.ReviewedAt = &now
+	request.CreatedAt = now
+	request.UpdatedAt = now
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := database.RequestsCollection.InsertOne(ctx, request); err != nil {
+		log.Printf("Error recording direct add of %s: %v", request.Title, err)
+	}
+}
+
+// GetRequest returns one request to its requester or to request managers
+func (h *RequestHandler) GetRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID, err := requestIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var request models.MediaRequest
+	if err := database.RequestsCollection.FindOne(ctx, bson.M{"_id": requestID}).Decode(&request); err != nil {
+		http.Error(w, "Request not found", http.StatusNotFound)
+		return
+	}
+
+	userID, _ := r.Context().Value("userID").(string)
+	if request.RequestedBy.Hex() != userID && !middleware.HasPermission(r, models.PermissionRequestsManage) {
+		http.Error(w, "Request not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+// CancelRequest deletes a pending request; requesters can cancel their own
+// and request managers can cancel any
+func (h *RequestHandler) CancelRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID, err := requestIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	filter := bson.M{"_id": requestID, "status": models.RequestStatusPending}
+	if !middleware.HasPermission(r, models.PermissionRequestsManage) {
+		userID, _ := r.Context().Value("userID").(string)
+		objectID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		filter["requestedBy"] = objectID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := database.RequestsCollection.DeleteOne(ctx, filter)
+	if err != nil {
+		http.Error(w, "Error cancelling request", http.StatusInternalServerError)
+		return
+	}
+	if result.DeletedCount == 0 {
+		http.Error(w, "No pending request found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Request cancelled successfully"})
+}
+
+// ApproveRequest approves a pending (or previously failed) request and adds
+// the media to Radarr or Sonarr
+func (h *RequestHandler) ApproveRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID, err := requestIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ApproveMediaRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	request, err := h.approve(ctx, r, requestID, req)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Request not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errRequestNotReviewable) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, errPermission4K) {
+		http.Error(w, "Permission required: "+models.PermissionRequest4K, http.StatusForbidden)
+		return
+	}
+	if err != nil && request == nil {
+		log.Printf("Error approving request %s: %v", requestID.Hex(), err)
+		http.Error(w, "Error approving request", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if request.Status == models.RequestStatusFailed {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	json.NewEncoder(w).Encode(request)
+}
+
+// DeclineRequest declines a pending request with a reason
+func (h *RequestHandler) DeclineRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID, err := requestIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.DeclineMediaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, "A reason is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reviewer, _ := r.Context().Value("username").(string)
+	now := time.Now()
+
+	var request models.MediaRequest
+	err = database.RequestsCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": requestID, "status": bson.M{"$in": bson.A{models.RequestStatusPending, models.RequestStatusFailed}}},
+		bson.M{"$set": bson.M{
+			"status":        models.RequestStatusDeclined,
+			"declineReason": req.Reason,
+			"reviewedBy":    reviewer,
+			"reviewedAt":    now,
+			"updatedAt":     now,
+		}},
+		// The previous status (pending or failed) is audited
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&request)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "No pending request found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error declining request", http.StatusInternalServerError)
+		return
+	}
+	previousStatus := request.Status
+	request.Status = models.RequestStatusDeclined
+	request.DeclineReason = req.Reason
+	request.ReviewedBy = reviewer
+	request.ReviewedAt = &now
+	request.UpdatedAt = now
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditRequestDecline,
+		Target:  requestAuditTarget(&request),
+		Changes: []models.AuditChange{{Field: "status", Before: previousStatus, After: models.RequestStatusDeclined}, {Field: "declineReason", After: req.Reason}},
+	})
+	events.Publish(events.RequestDeclined, &request)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+// errPermission4K is returned when an approval would use a 4K profile the
+// approver may not request
+var errPermission4K = errors.New("permission required: " + models.PermissionRequest4K)
+
+// approve claims a pending or failed request, adds it to Radarr or Sonarr and
+// stores the outcome. A request whose add failed is returned with the
+// failed status alongside the error.
+func (h *RequestHandler) approve(ctx context.Context, r *http.Request, requestID primitive.ObjectID, opts models.ApproveMediaRequest) (*models.MediaRequest, error) {
+	var request models.MediaRequest
+	if err := database.RequestsCollection.FindOne(ctx, bson.M{"_id": requestID}).Decode(&request); err != nil {
+		return nil, err
+	}
+	if request.Status != models.RequestStatusPending && request.Status != models.RequestStatusFailed {
+		return nil, errRequestNotReviewable
+	}
+
+	qualityProfileID := opts.QualityProfileID
+	if qualityProfileID == 0 {
+		qualityProfileID = 1
+	}
+	fourK := h.config.Radarr4KQualityProfileIDs
+	if request.MediaType == models.MediaTypeTV {
+		fourK = h.config.Sonarr4KQualityProfileIDs
+	}
+	if containsInt(fourK, qualityProfileID) && !middleware.HasPermission(r, models.PermissionRequest4K) {
+		return nil, errPermission4K
+	}
+
+	// Claim the request so concurrent approvals cannot add it twice
+	reviewer, _ := r.Context().Value("username").(string)
+	now := time.Now()
+	err := database.RequestsCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": requestID, "status": request.Status},
+		bson.M{"$set": bson.M{
+			"status":     models.RequestStatusApproved,
+			"reviewedBy": reviewer,
+			"reviewedAt": now,
+			"updatedAt":  now,
+		}, "$unset": bson.M{"error": ""}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&request)
+	if err == mongo.ErrNoDocuments {
+		return nil, errRequestNotReviewable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status, addErr := h.fulfil(&request, qualityProfileID, opts.RootFolderPath)
+
+	set := bson.M{
+		"title":            request.Title,
+		"year":             request.Year,
+		"tvdbId":           request.TVDBID,
+		"qualityProfileId": request.QualityProfileID,
+		"rootFolderPath":   request.RootFolderPath,
+		"updatedAt":        time.Now(),
+	}
+	if addErr != nil {
+		request.Status = models.RequestStatusFailed
+		request.Error = addErr.Error()
+		set["status"] = request.Status
+		set["error"] = request.Error
+	}
+	if _, err := database.RequestsCollection.UpdateOne(ctx, bson.M{"_id": request.ID}, bson.M{"$set": set}); err != nil {
+		log.Printf("Error saving outcome of request %s: %v", request.ID.Hex(), err)
+	}
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action: models.AuditRequestApprove,
+		Target: requestAuditTarget(&request),
+		Changes: auditChanges(nil, map[string]interface{}{
+			"status":           request.Status,
+			"qualityProfileId": request.QualityProfileID,
+			"rootFolderPath":   request.RootFolderPath,
+		}),
+		Status: status,
+	})
+
+	if addErr == nil {
+		approved := request
+		events.Publish(events.RequestApproved, &approved)
+	}
+
+	return &request, addErr
+}
+
+// fulfil adds an approved request to Radarr or Sonarr, filling in details
+// from their lookup, and returns the arr's HTTP status
+func (h *RequestHandler) fulfil(request *models.MediaRequest, qualityProfileID int, rootFolderPath string) (int, error) {
+	if request.MediaType == models.MediaTypeMovie {
+		movie, err := h.radarr.lookupMovie(request.TMDBID)
+		if err != nil {
+			return 0, err
+		}
+		if rootFolderPath == "" {
+			rootFolderPath = firstRootFolder(h.config.RadarrURL, h.config.RadarrAPIKey)
+		}
+		movie.QualityProfileId = qualityProfileID
+		movie.RootFolderPath = rootFolderPath
+		movie.AddOptions = nil
+		h.radarr.applyMovieDefaults(movie)
+
+		request.Title = movie.Title
+		request.Year = movie.Year
+		request.QualityProfileID = movie.QualityProfileId
+		request.RootFolderPath = movie.RootFolderPath
+
+		status, body, err := h.radarr.addMovie(movie)
+		if err != nil {
+			return 0, err
+		}
+		if status >= 300 {
+			return status, fmt.Errorf("radarr returned status %d: %s", status, arrErrorMessage(body))
+		}
+		return status, nil
+	}
+
+	term := "tmdb:" + strconv.Itoa(request.TMDBID)
+	if request.TVDBID > 0 {
+		term = "tvdb:" + strconv.Itoa(request.TVDBID)
+	}
+	series, err := h.sonarr.lookupSeries(term)
+	if err != nil {
+		return 0, err
+	}
+	request.Title = series.Title
+	request.Year = series.Year
+	request.TVDBID = series.TvdbId
+
+	var status int
+	var body []byte
+	if series.Id != 0 {
+		// Already in Sonarr: monitor the requested seasons instead of adding
+		status, body, err = h.sonarr.monitorSeasons(series.Id, request.Seasons)
+	} else {
+		wanted := make(map[int]bool, len(request.Seasons))
+		for _, season := range request.Seasons {
+			wanted[season] = true
+		}
+		for i := range series.Seasons {
+			number := series.Seasons[i].SeasonNumber
+			series.Seasons[i].Monitored = (len(wanted) == 0 && number > 0) || wanted[number]
+		}
+		if rootFolderPath == "" {
+			rootFolderPath = firstRootFolder(h.config.SonarrURL, h.config.SonarrAPIKey)
+		}
+		series.QualityProfileId = qualityProfileID
+		series.RootFolderPath = rootFolderPath
+		series.AddOptions = nil
+		h.sonarr.applySeriesDefaults(series)
+
+		request.QualityProfileID = series.QualityProfileId
+		request.RootFolderPath = series.RootFolderPath
+
+		status, body, err = h.sonarr.sendSeries("POST", series)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if status >= 300 {
+		return status, fmt.Errorf("sonarr returned status %d: %s", status, arrErrorMessage(body))
+	}
+	return status, nil
+}
+
+// arrErrorMessage extracts a readable message from a Radarr/Sonarr error body,
+// which is either an object with "message" or a list of validation failures
+func arrErrorMessage(body []byte) string {
+	var single struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &single) == nil && single.Message != "" {
+		return single.Message
+	}
+
+	var failures []struct {
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if json.Unmarshal(body, &failures) == nil && len(failures) > 0 {
+		messages := make([]string, 0, len(failures))
+		for _, failure := range failures {
+			messages = append(messages, failure.ErrorMessage)
+		}
+		return strings.Join(messages, "; ")
+	}
+
+	message := string(body)
+	if len(message) > 200 {
+		message = message[:200]
+	}
+	return message
+}
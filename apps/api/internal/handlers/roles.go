@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/middleware"
+	"jellystreaming/internal/models"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// RoleHandler handles role management requests
+type RoleHandler struct {
+	config *config.Config
+}
+
+// NewRoleHandler creates a new RoleHandler
+func NewRoleHandler(cfg *config.Config) *RoleHandler {
+	return &RoleHandler{config: cfg}
+}
+
+// validatePermissions rejects unknown permissions and permissions the caller
+// does not hold, so that role editors cannot escalate their own privileges
+func validatePermissions(r *http.Request, permissions []string) error {
+	for _, permission := range permissions {
+		if !models.IsValidPermission(permission) {
+			return fmt.Errorf("unknown permission %q", permission)
+		}
+		if !middleware.HasPermission(r, permission) {
+			return fmt.Errorf("cannot grant permission %q you do not hold", permission)
+		}
+	}
+	return nil
+}
+
+// validateRoleAssignment checks that every role exists and only grants
+// permissions the caller holds
+func validateRoleAssignment(ctx context.Context, r *http.Request, roleNames []string) error {
+	if len(roleNames) == 0 {
+		return nil
+	}
+
+	count, err := database.RolesCollection.CountDocuments(ctx, bson.M{"name": bson.M{"$in": roleNames}})
+	if err != nil {
+		return err
+	}
+	if int(count) != len(uniqueStrings(roleNames)) {
+		return errors.New("unknown role")
+	}
+
+	permissions, err := database.RolePermissions(ctx, roleNames)
+	if err != nil {
+		return err
+	}
+	return validatePermissions(r, permissions)
+}
+
+// uniqueStrings returns values without duplicates, keeping the first occurrence
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// permissionsLost reports whether a user no longer holds one of permissions
+func permissionsLost(ctx context.Context, user *models.User, permissions []string) (bool, error) {
+	held, err := database.ResolvePermissions(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	holds := make(map[string]bool, len(held))
+	for _, permission := range held {
+		holds[permission] = true
+	}
+	for _, permission := range permissions {
+		if !holds[permission] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// revokeOnPermissionLoss ends the sessions of the users who no longer hold
+// one of permissions. Access tokens carry the permissions they were issued
+// with, so revoked permissions would otherwise keep working until a refresh.
+func revokeOnPermissionLoss(ctx context.Context, users []models.User, permissions []string) {
+	if len(permissions) == 0 {
+		return
+	}
+	for i := range users {
+		lost, err := permissionsLost(ctx, &users[i], permissions)
+		if err != nil {
+			log.Printf("Error resolving permissions of user %s: %v", users[i].Username, err)
+			continue
+		}
+		if !lost {
+			continue
+		}
+		if _, err := database.RevokeUserSessions(ctx, users[i].ID, ""); err != nil {
+			log.Printf("Error revoking sessions for user %s: %v", users[i].Username, err)
+		}
+	}
+}
+
+// roleMembers returns the non-admin users a role applies to: those assigned
+// it, and for the default role those without any role
+func roleMembers(ctx context.Context, roleName string) ([]models.User, error) {
+	members := bson.A{bson.M{"roles": roleName}}
+	if roleName == models.DefaultRoleName {
+		members = append(members, bson.M{"roles": bson.M{"$exists": false}}, bson.M{"roles": bson.M{"$size": 0}})
+	}
+	cursor, err := database.UsersCollection.Find(ctx, bson.M{"isAdmin": bson.M{"$ne": true}, "$or": members})
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// removedStrings returns the values of before that are not in after
+func removedStrings(before, after []string) []string {
+	kept := make(map[string]bool, len(after))
+	for _, value := range after {
+		kept[value] = true
+	}
+	var removed []string
+	for _, value := range before {
+		if !kept[value] {
+			removed = append(removed, value)
+		}
+	}
+	return removed
+}
+
+// ListPermissions returns every permission that can be granted
+func (h *RoleHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.Permissions)
+}
+
+// ListRoles returns all roles
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := database.RolesCollection.Find(ctx, bson.M{})
+	if err != nil {
+		http.Error(w, "Error fetching roles", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	roles := []models.Role{}
+	if err := cursor.All(ctx, &roles); err != nil {
+		http.Error(w, "Error decoding roles", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
+}
+
+// CreateRole creates a custom role
+func (h *RoleHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.CreateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !roleNamePattern.MatchString(req.Name) {
+		http.Error(w, "Role name must be 1-32 lowercase letters, digits, '-' or '_'", http.StatusBadRequest)
+		return
+	}
+
+	permissions := uniqueStrings(req.Permissions)
+	if err := validatePermissions(r, permissions); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	result, err := database.RolesCollection.InsertOne(ctx, role)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "Role already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating role: %v", err)
+		http.Error(w, "Error creating role", http.StatusInternalServerError)
+		return
+	}
+	role.ID = result.InsertedID.(primitive.ObjectID)
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditRoleCreate,
+		Target:  roleAuditTarget(&role),
+		Changes: []models.AuditChange{{Field: "permissions", After: role.Permissions}},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(role)
+}
+
+// UpdateRole changes a role's description or permissions. Users who lose a
+// permission through the change are signed out everywhere.
+func (h *RoleHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	roleID, err := primitive.ObjectIDFromHex(strings.TrimPrefix(r.URL.Path, "/api/roles/"))
+	if err != nil {
+		http.Error(w, "Invalid role ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var role models.Role
+	if err := database.RolesCollection.FindOne(ctx, bson.M{"_id": roleID}).Decode(&role); err != nil {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	}
+	before := role
+
+	set := bson.M{"updatedAt": time.Now()}
+	if req.Description != nil {
+		set["description"] = *req.Description
+	}
+	if req.Permissions != nil {
+		permissions := uniqueStrings(*req.Permissions)
+		// Removing permissions is also an escalation check: the caller must hold
+		// everything the role grants before and after the change
+		if err := validatePermissions(r, append(permissions, role.Permissions...)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		set["permissions"] = permissions
+	}
+
+	err = database.RolesCollection.FindOneAndUpdate(ctx, bson.M{"_id": roleID}, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&role)
+	if err != nil {
+		http.Error(w, "Error updating role", http.StatusInternalServerError)
+		return
+	}
+
+	if removed := removedStrings(before.Permissions, role.Permissions); len(removed) > 0 {
+		members, err := roleMembers(ctx, role.Name)
+		if err != nil {
+			log.Printf("Error loading users of role %s: %v", role.Name, err)
+		}
+		revokeOnPermissionLoss(ctx, members, removed)
+	}
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditRoleUpdate,
+		Target:  roleAuditTarget(&role),
+		Changes: auditChanges(roleAuditFields(&before), roleAuditFields(&role)),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(role)
+}
+
+// DeleteRole deletes a custom role and removes it from every user. Users who
+// lose a permission with it are signed out everywhere.
+func (h *RoleHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	roleID, err := primitive.ObjectIDFromHex(strings.TrimPrefix(r.URL.Path, "/api/roles/"))
+	if err != nil {
+		http.Error(w, "Invalid role ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var role models.Role
+	if err := database.RolesCollection.FindOne(ctx, bson.M{"_id": roleID}).Decode(&role); err != nil {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	}
+
+	if role.BuiltIn {
+		http.Error(w, "Built-in roles cannot be deleted", http.StatusBadRequest)
+		return
+	}
+	if err := validatePermissions(r, role.Permissions); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	members, err := roleMembers(ctx, role.Name)
+	if err != nil {
+		log.Printf("Error loading users of role %s: %v", role.Name, err)
+	}
+
+	if _, err := database.RolesCollection.DeleteOne(ctx, bson.M{"_id": roleID}); err != nil {
+		http.Error(w, "Error deleting role", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := database.UsersCollection.UpdateMany(ctx,
+		bson.M{"roles": role.Name},
+		bson.M{"$pull": bson.M{"roles": role.Name}},
+	); err != nil {
+		log.Printf("Error removing role %s from users: %v", role.Name, err)
+	}
+	if _, err := database.InvitesCollection.UpdateMany(ctx,
+		bson.M{"role": role.Name},
+		bson.M{"$unset": bson.M{"role": ""}},
+	); err != nil {
+		log.Printf("Error removing role %s from invites: %v", role.Name, err)
+	}
+
+	for i := range members {
+		members[i].Roles = removedStrings(members[i].Roles, []string{role.Name})
+	}
+	revokeOnPermissionLoss(ctx, members, role.Permissions)
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action:  models.AuditRoleDelete,
+		Target:  roleAuditTarget(&role),
+		Changes: []models.AuditChange{{Field: "permissions", Before: role.Permissions}},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Role deleted successfully"})
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+)
+
+// SecurityHandler exposes login lockouts and failed logins to admins
+type SecurityHandler struct{}
+
+// NewSecurityHandler creates a new SecurityHandler
+func NewSecurityHandler() *SecurityHandler {
+	return &SecurityHandler{}
+}
+
+// queryInt parses a non-negative integer query parameter, falling back to defaultValue
+func queryInt(r *http.Request, name string, defaultValue int) int {
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// ListLockouts returns active lockouts, or every failure counter with ?all=true
+func (h *SecurityHandler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter := bson.M{"lockedUntil": bson.M{"$gt": time.Now()}}
+	if r.URL.Query().Get("all") == "true" {
+		filter = bson.M{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := database.LoginThrottlesCollection.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "lastFailureAt", Value: -1}}))
+	if err != nil {
+		http.Error(w, "Error fetching lockouts", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	throttles := []models.LoginThrottle{}
+	if err := cursor.All(ctx, &throttles); err != nil {
+		http.Error(w, "Error decoding lockouts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(throttles)
+}
+
+// Unlock clears the lockout and failure counter of a username and/or IP
+// (?username=... and/or ?ip=...)
+func (h *SecurityHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := r.URL.Query().Get("username")
+	ip := r.URL.Query().Get("ip")
+	if username == "" && ip == "" {
+		http.Error(w, "username or ip required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var keys []string
+	if username != "" {
+		keys = append(keys, database.UsernameThrottleKey(username))
+	}
+	if ip != "" {
+		keys = append(keys, database.IPThrottleKey(ip))
+	}
+
+	cleared := 0
+	for _, key := range keys {
+		deleted, err := database.ClearLoginThrottle(ctx, key)
+		if err != nil {
+			http.Error(w, "Error clearing lockout", http.StatusInternalServerError)
+			return
+		}
+		if deleted {
+			cleared++
+		}
+	}
+
+	if cleared == 0 {
+		http.Error(w, "No lockout found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Lockout cleared successfully"})
+}
+
+// ListFailedLogins returns failed logins, newest first, filtered by
+// ?username=, ?ip=, ?reason= and ?since= (RFC 3339), paginated with ?limit= and ?skip=
+func (h *SecurityHandler) ListFailedLogins(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := bson.M{}
+	if username := query.Get("username"); username != "" {
+		filter["username"] = username
+	}
+	if ip := query.Get("ip"); ip != "" {
+		filter["ip"] = ip
+	}
+	if reason := query.Get("reason"); reason != "" {
+		filter["reason"] = reason
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			http.Error(w, "Invalid since parameter, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		filter["createdAt"] = bson.M{"$gte": t}
+	}
+
+	limit := queryInt(r, "limit", 50)
+	if limit == 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	skip := queryInt(r, "skip", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := database.FailedLoginsCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		http.Error(w, "Error counting failed logins", http.StatusInternalServerError)
+		return
+	}
+
+	cursor, err := database.FailedLoginsCollection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)))
+	if err != nil {
+		http.Error(w, "Error fetching failed logins", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	response := models.FailedLoginsResponse{Items: []models.FailedLogin{}, Total: total}
+	if err := cursor.All(ctx, &response.Items); err != nil {
+		http.Error(w, "Error decoding failed logins", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
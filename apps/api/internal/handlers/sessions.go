@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/middleware"
+	"jellystreaming/internal/models"
+)
+
+// newOpaqueToken generates a random URL-safe token
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken hashes an opaque token for storage; only hashes are persisted
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession creates a new session for a user and issues a token pair
+func (h *AuthHandler) startSession(r *http.Request, user *models.User) (*models.LoginResponse, error) {
+	refreshToken, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        r.UserAgent(),
+		IP:               middleware.ClientIP(r),
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(h.config.RefreshTokenTTL),
+	}
+
+	result, err := database.SessionsCollection.InsertOne(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	session.ID = result.InsertedID.(primitive.ObjectID)
+
+	return h.tokenResponse(user, session.ID.Hex(), refreshToken)
+}
+
+// tokenResponse builds a login response with a fresh access token for a session.
+// Permissions are re-read from the user's roles, so role changes apply on the
+// next refresh
+func (h *AuthHandler) tokenResponse(user *models.User, sessionID, refreshToken string) (*models.LoginResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	permissions, err := database.ResolvePermissions(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateToken(user, sessionID, permissions, h.config.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	userResponse := user.ToResponse()
+	userResponse.Permissions = permissions
+
+	return &models.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.config.AccessTokenTTL.Seconds()),
+		User:         userResponse,
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.RefreshToken == "" {
+		http.Error(w, "Refresh token required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tokenHash := hashToken(req.RefreshToken)
+
+	var session models.Session
+	err := database.SessionsCollection.FindOne(ctx, bson.M{"refreshTokenHash": tokenHash}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		// A refresh token that was already rotated is being replayed: assume it
+		// was stolen and end the whole session
+		var reused models.Session
+		if database.SessionsCollection.FindOne(ctx, bson.M{"previousTokenHash": tokenHash}).Decode(&reused) == nil {
+			log.Printf("Refresh token reuse detected for session %s, revoking", reused.ID.Hex())
+			database.RevokeSession(ctx, reused.UserID, reused.ID)
+		}
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	err = database.UsersCollection.FindOne(ctx, bson.M{"_id": session.UserID}).Decode(&user)
+	if err != nil {
+		database.RevokeSession(ctx, session.UserID, session.ID)
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	newRefreshToken, err := newOpaqueToken()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Rotate the refresh token; matching on the old hash makes concurrent
+	// refreshes with the same token lose the race instead of both succeeding
+	now := time.Now()
+	result, err := database.SessionsCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": session.ID, "refreshTokenHash": tokenHash},
+		bson.M{
+			"$set": bson.M{
+				"refreshTokenHash":  hashToken(newRefreshToken),
+				"previousTokenHash": tokenHash,
+				"lastUsedAt":        now,
+				"expiresAt":         now.Add(h.config.RefreshTokenTTL),
+				"ip":                middleware.ClientIP(r),
+				"userAgent":         r.UserAgent(),
+			},
+		},
+	)
+	if err != nil {
+		log.Printf("Error rotating refresh token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := h.tokenResponse(&user, session.ID.Hex(), newRefreshToken)
+	if err != nil {
+		log.Printf("Error generating token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Logout revokes the session of the current access token
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, sessionID, ok := sessionFromContext(r)
+	if !ok {
+		http.Error(w, "Invalid session", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := database.RevokeSession(ctx, userID, sessionID); err != nil {
+		http.Error(w, "Error revoking session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out successfully"})
+}
+
+// ListSessions returns the current user's active sessions
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, sessionID, ok := sessionFromContext(r)
+	if !ok {
+		http.Error(w, "Invalid session", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessions, err := database.ListUserSessions(ctx, userID)
+	if err != nil {
+		http.Error(w, "Error fetching sessions", http.StatusInternalServerError)
+		return
+	}
+
+	responses := make([]models.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = session.ToResponse(sessionID.Hex())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(responses)
+}
+
+// RevokeAllSessions logs the current user out everywhere, including this session
+func (h *AuthHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _, ok := sessionFromContext(r)
+	if !ok {
+		http.Error(w, "Invalid session", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := database.RevokeUserSessions(ctx, userID, "")
+	if err != nil {
+		http.Error(w, "Error revoking sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Logged out of all sessions",
+		"revoked": revoked,
+	})
+}
+
+// RevokeSession revokes one of the current user's sessions
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _, ok := sessionFromContext(r)
+	if !ok {
+		http.Error(w, "Invalid session", http.StatusBadRequest)
+		return
+	}
+
+	targetID, err := primitive.ObjectIDFromHex(strings.TrimPrefix(r.URL.Path, "/api/auth/sessions/"))
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := database.RevokeSession(ctx, userID, targetID)
+	if err != nil {
+		http.Error(w, "Error revoking session", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Session revoked successfully"})
+}
+
+// sessionFromContext extracts the user and session IDs set by middleware.Auth
+func sessionFromContext(r *http.Request) (primitive.ObjectID, primitive.ObjectID, bool) {
+	userID, _ := r.Context().Value("userID").(string)
+	sessionID, _ := r.Context().Value("sessionID").(string)
+
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	sessionObjectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	return userObjectID, sessionObjectID, true
+}
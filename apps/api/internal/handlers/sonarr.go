@@ -2,15 +2,18 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"jellystreaming/internal/config"
+	"jellystreaming/internal/middleware"
 	"jellystreaming/internal/models"
 )
 
@@ -39,6 +42,88 @@ func (h *SonarrHandler) AddSeries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.applySeriesDefaults(&req)
+	if containsInt(h.config.Sonarr4KQualityProfileIDs, req.QualityProfileId) && !middleware.HasPermission(r, models.PermissionRequest4K) {
+		http.Error(w, "Permission required: "+models.PermissionRequest4K, http.StatusForbidden)
+		return
+	}
+
+	method := "POST"
+	if isUpdate {
+		method = "PUT"
+	}
+
+	monitoredSeasons := []int{}
+	for _, season := range req.Seasons {
+		if season.Monitored {
+			monitoredSeasons = append(monitoredSeasons, season.SeasonNumber)
+		}
+	}
+
+	// An update only counts the seasons it starts monitoring
+	requestedSeasons := monitoredSeasons
+	seasonCount := monitoredSeasonCount(req.Seasons, false)
+	if isUpdate {
+		if req.Id == 0 {
+			http.Error(w, "Series id required", http.StatusBadRequest)
+			return
+		}
+		existing, err := h.getSeries(req.Id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error calling Sonarr: %v", err), http.StatusInternalServerError)
+			return
+		}
+		requestedSeasons = newlyMonitoredSeasons(existing.Seasons, req.Seasons)
+		seasonCount = len(requestedSeasons)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if seasonCount > 0 && !checkQuota(ctx, h.config, w, r, models.MediaTypeTV, seasonCount) {
+		return
+	}
+
+	status, body, err := h.sendSeries(method, &req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error calling Sonarr: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	action := models.AuditSeriesAdd
+	if isUpdate {
+		action = models.AuditSeriesUpdate
+	}
+	if status < http.StatusMultipleChoices && seasonCount > 0 {
+		recordDirectRequest(r, models.MediaRequest{
+			MediaType:        models.MediaTypeTV,
+			TVDBID:           req.TvdbId,
+			Title:            req.Title,
+			Year:             req.Year,
+			Seasons:          requestedSeasons,
+			SeasonCount:      seasonCount,
+			QualityProfileID: req.QualityProfileId,
+			RootFolderPath:   req.RootFolderPath,
+		})
+	}
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action: action,
+		Target: models.AuditTarget{Type: "series", ID: strconv.Itoa(req.TvdbId), Name: req.Title},
+		Changes: auditChanges(nil, map[string]interface{}{
+			"qualityProfileId": req.QualityProfileId,
+			"rootFolderPath":   req.RootFolderPath,
+			"monitoredSeasons": monitoredSeasons,
+		}),
+		Status: status,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// applySeriesDefaults fills in the fields Sonarr needs that callers may omit
+func (h *SonarrHandler) applySeriesDefaults(req *models.SonarrAddSeriesRequest) {
 	if req.QualityProfileId == 0 {
 		req.QualityProfileId = 1
 	}
@@ -52,44 +137,101 @@ func (h *SonarrHandler) AddSeries(w http.ResponseWriter, r *http.Request) {
 	}
 	req.Monitored = true
 	req.SeasonFolder = true
+}
 
-	jsonData, err := json.Marshal(req)
+// sendSeries adds (POST) or updates (PUT) a series in Sonarr and returns
+// Sonarr's status and response body
+func (h *SonarrHandler) sendSeries(method string, req *models.SonarrAddSeriesRequest) (int, []byte, error) {
+	status, body, err := arrRequest(h.config.SonarrURL, h.config.SonarrAPIKey, method, "/api/v3/series", req, 30*time.Second)
 	if err != nil {
-		http.Error(w, "Error encoding request", http.StatusInternalServerError)
-		return
+		return 0, nil, err
 	}
 
-	sonarrURL := fmt.Sprintf("%s/api/v3/series", h.config.SonarrURL)
-	client := &http.Client{Timeout: 30 * time.Second}
+	log.Printf("Sonarr add series response status: %d", status)
+	log.Printf("Sonarr response body: %s", string(body))
+	return status, body, nil
+}
 
-	method := "POST"
-	if isUpdate {
-		method = "PUT"
+// getSeries fetches a series that is already in Sonarr
+func (h *SonarrHandler) getSeries(id int) (*models.SonarrAddSeriesRequest, error) {
+	var series models.SonarrAddSeriesRequest
+	if err := arrGetJSON(h.config.SonarrURL, h.config.SonarrAPIKey, "/api/v3/series/"+strconv.Itoa(id), &series); err != nil {
+		return nil, err
 	}
+	return &series, nil
+}
 
-	httpReq, err := http.NewRequest(method, sonarrURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating request: %v", err), http.StatusInternalServerError)
-		return
+// newlyMonitoredSeasons lists the regular seasons monitored in seasons that
+// existing did not monitor
+func newlyMonitoredSeasons(existing, seasons []models.SonarrSeason) []int {
+	wasMonitored := make(map[int]bool, len(existing))
+	for _, season := range existing {
+		wasMonitored[season.SeasonNumber] = season.Monitored
+	}
+	added := []int{}
+	for _, season := range seasons {
+		if season.SeasonNumber > 0 && season.Monitored && !wasMonitored[season.SeasonNumber] {
+			added = append(added, season.SeasonNumber)
+		}
 	}
+	return added
+}
 
-	httpReq.Header.Set("X-Api-Key", h.config.SonarrAPIKey)
-	httpReq.Header.Set("Content-Type", "application/json")
+// lookupSeries resolves a "tmdb:<id>" or "tvdb:<id>" term to the series
+// details Sonarr needs; Id is set when the series is already in Sonarr
+func (h *SonarrHandler) lookupSeries(term string) (*models.SonarrAddSeriesRequest, error) {
+	var results []models.SonarrAddSeriesRequest
+	path := "/api/v3/series/lookup?term=" + url.QueryEscape(term)
+	if err := arrGetJSON(h.config.SonarrURL, h.config.SonarrAPIKey, path, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("sonarr found no series for %s", term)
+	}
+	return &results[0], nil
+}
 
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error calling Sonarr: %v", err), http.StatusInternalServerError)
-		return
+// monitorSeasons turns on monitoring for seasons of a series already in
+// Sonarr (all seasons but specials when none are given) and searches for them.
+// The series is edited as raw JSON so fields this API does not model survive.
+func (h *SonarrHandler) monitorSeasons(seriesID int, seasons []int) (int, []byte, error) {
+	path := fmt.Sprintf("/api/v3/series/%d", seriesID)
+
+	var series map[string]interface{}
+	if err := arrGetJSON(h.config.SonarrURL, h.config.SonarrAPIKey, path, &series); err != nil {
+		return 0, nil, err
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("Sonarr add series response status: %d", resp.StatusCode)
-	log.Printf("Sonarr response body: %s", string(body))
+	wanted := make(map[int]bool, len(seasons))
+	for _, season := range seasons {
+		wanted[season] = true
+	}
+	if list, ok := series["seasons"].([]interface{}); ok {
+		for _, item := range list {
+			season, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			number, _ := season["seasonNumber"].(float64)
+			if (len(wanted) == 0 && number > 0) || wanted[int(number)] {
+				season["monitored"] = true
+			}
+		}
+	}
+	series["monitored"] = true
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	w.Write(body)
+	status, body, err := arrRequest(h.config.SonarrURL, h.config.SonarrAPIKey, "PUT", path, series, 30*time.Second)
+	if err != nil || status >= 300 {
+		return status, body, err
+	}
+
+	// Updating monitoring does not search by itself
+	if _, _, err := arrRequest(h.config.SonarrURL, h.config.SonarrAPIKey, "POST", "/api/v3/command",
+		map[string]interface{}{"name": "SeriesSearch", "seriesId": seriesID}, 10*time.Second); err != nil {
+		log.Printf("Error starting Sonarr search for series %d: %v", seriesID, err)
+	}
+
+	return status, body, nil
 }
 
 // GetQueue handles Sonarr queue requests
@@ -255,6 +397,13 @@ func (h *SonarrHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
+
+	recordAudit(h.config, r, models.AuditEvent{
+		Action: models.AuditSonarrRefresh,
+		Target: models.AuditTarget{Type: "sonarr", Name: "RefreshMonitoredDownloads"},
+		Status: resp.StatusCode,
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(resp.StatusCode)
 	w.Write(body)
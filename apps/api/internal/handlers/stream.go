@@ -0,0 +1,592 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/middleware"
+)
+
+const (
+	streamPathPrefix    = "/api/stream/"
+	streamTokenAudience = "stream"
+	// maxPlaylistSize bounds how much of a playlist or PlaybackInfo response is buffered for rewriting
+	maxPlaylistSize = 10 << 20
+	// itemAccessTTL is how long a user's access to an item is remembered, so
+	// segment requests do not each ask Jellyfin
+	itemAccessTTL = time.Minute
+)
+
+var (
+	jellyfinItemIDPattern = regexp.MustCompile(`^([0-9a-fA-F]{32}|[0-9a-fA-F-]{36})$`)
+	streamImagePattern    = regexp.MustCompile(`^images/([A-Za-z]+)(/[0-9]+)?$`)
+	playlistURIAttribute  = regexp.MustCompile(`URI="([^"]*)"`)
+
+	// Request headers forwarded to Jellyfin; Range makes seeking in direct streams work
+	streamRequestHeaders = []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since", "Accept", "Accept-Language", "Content-Type"}
+	// Response headers passed back to the client
+	streamResponseHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "Content-Disposition", "Cache-Control", "ETag", "Last-Modified", "Expires"}
+)
+
+// streamGrant identifies who plays a stream: the account and its Jellyfin
+// user, and the session or API key whose revocation must stop playback
+type streamGrant struct {
+	UserID         string `json:"uid"`
+	JellyfinUserID string `json:"jfu"`
+	SessionID      string `json:"ssn,omitempty"`
+	APIKeyID       string `json:"sak,omitempty"`
+}
+
+// streamClaims authorize the segment and variant requests a player makes on
+// its own after loading a playlist, where it cannot attach a bearer token
+type streamClaims struct {
+	streamGrant
+	jwt.RegisteredClaims
+}
+
+// StreamHandler proxies playback traffic to Jellyfin so the API key never
+// reaches the browser
+type StreamHandler struct {
+	config *config.Config
+	client *http.Client
+
+	accessMu sync.Mutex
+	access   map[string]time.Time // "jellyfinUserId/itemId" -> when the grant expires
+}
+
+// NewStreamHandler creates a new StreamHandler
+func NewStreamHandler(cfg *config.Config) *StreamHandler {
+	// No overall timeout: segment and direct-stream bodies can take as long as playback
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 60 * time.Second
+
+	return &StreamHandler{
+		config: cfg,
+		client: &http.Client{Transport: transport},
+		access: make(map[string]time.Time),
+	}
+}
+
+// generateStreamToken issues a token for one item, bound to the caller's session or API key
+func generateStreamToken(itemID string, grant streamGrant, ttl time.Duration) (string, error) {
+	claims := streamClaims{
+		streamGrant: grant,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strings.ToLower(itemID),
+			Audience:  jwt.ClaimStrings{streamTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(database.JWTSecret)
+}
+
+// parseStreamToken validates a stream token for the requested item
+func parseStreamToken(tokenString, itemID string) (*streamClaims, error) {
+	claims := &streamClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return database.JWTSecret, nil
+	}, jwt.WithAudience(streamTokenAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.UserID == "" || (claims.SessionID == "") == (claims.APIKeyID == "") {
+		return nil, errors.New("invalid token")
+	}
+	if claims.Subject != strings.ToLower(itemID) {
+		return nil, errors.New("token was issued for another item")
+	}
+
+	return claims, nil
+}
+
+// splitStreamPath splits /api/stream/{itemId}/{rest} into its parts
+func splitStreamPath(requestPath string) (string, string, bool) {
+	trimmed := strings.TrimPrefix(requestPath, streamPathPrefix)
+	if trimmed == requestPath {
+		return "", "", false
+	}
+	return splitItemPath(trimmed)
+}
+
+// splitItemPath splits {itemId}/{rest}, rejecting malformed IDs and dot segments
+func splitItemPath(p string) (string, string, bool) {
+	parts := strings.SplitN(p, "/", 2)
+	if len(parts) != 2 || parts[1] == "" || !jellyfinItemIDPattern.MatchString(parts[0]) {
+		return "", "", false
+	}
+	if path.Clean("/"+parts[1]) != "/"+parts[1] {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// forwardedQuery copies the client's query string without any credentials;
+// the API key is added server-side as a header
+func forwardedQuery(query url.Values) url.Values {
+	forwarded := url.Values{}
+	for key, values := range query {
+		switch strings.ToLower(key) {
+		case "api_key", "apikey", "x-emby-token", "access_token", "st":
+			continue
+		}
+		forwarded[key] = values
+	}
+	return forwarded
+}
+
+// Proxy handles /api/stream/{itemId}/... requests:
+//
+//	playbackinfo        -> /Items/{itemId}/PlaybackInfo
+//	images/{type}[/{i}] -> /Items/{itemId}/Images/{type}[/{i}]
+//	anything else       -> /Videos/{itemId}/... (HLS playlists, segments, subtitles, direct streams)
+//
+// Callers authenticate with a bearer or access_token query token, or with the
+// st stream token embedded in rewritten playlist URLs. Images need neither.
+func (h *StreamHandler) Proxy(w http.ResponseWriter, r *http.Request) {
+	itemID, rest, ok := splitStreamPath(r.URL.Path)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if strings.HasPrefix(strings.ToLower(rest), "images/") {
+		// Jellyfin serves artwork without authentication, so <img> tags need no token
+		h.proxyImage(w, r, itemID, rest)
+		return
+	}
+
+	if streamToken := r.URL.Query().Get("st"); streamToken != "" {
+		claims, err := parseStreamToken(streamToken, itemID)
+		if err != nil {
+			http.Error(w, "Invalid or expired stream token", http.StatusUnauthorized)
+			return
+		}
+
+		// Revoking the session or API key, or requiring a password change,
+		// also stops playback that is already running
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if claims.APIKeyID != "" {
+			err = database.CheckAPIKey(ctx, claims.APIKeyID)
+		} else {
+			err = database.CheckSession(ctx, claims.SessionID)
+		}
+		if errors.Is(err, database.ErrSessionInactive) || errors.Is(err, database.ErrAPIKeyInvalid) {
+			http.Error(w, "Invalid or expired stream token", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("Error checking stream token owner: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !h.checkStreamOwner(ctx, w, claims.UserID) {
+			return
+		}
+
+		h.proxyMedia(w, r, itemID, rest, claims.streamGrant)
+		return
+	}
+
+	middleware.Auth(func(w http.ResponseWriter, r *http.Request) {
+		jellyfinUserID, err := resolveJellyfinUserID(h.config, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		// The login token may predate an admin requiring a password change;
+		// check the account before issuing stream tokens on its behalf
+		userID, _ := r.Context().Value("userID").(string)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if !h.checkStreamOwner(ctx, w, userID) {
+			return
+		}
+
+		grant := streamGrant{UserID: userID, JellyfinUserID: jellyfinUserID}
+		grant.SessionID, _ = r.Context().Value("sessionID").(string)
+		grant.APIKeyID, _ = r.Context().Value("apiKeyID").(string)
+
+		h.proxyMedia(w, r, itemID, rest, grant)
+	})(w, r)
+}
+
+// checkStreamOwner verifies that the account a stream is played for may still
+// play media, and writes the error response when it may not
+func (h *StreamHandler) checkStreamOwner(ctx context.Context, w http.ResponseWriter, userID string) bool {
+	user, err := findUserByID(ctx, userID)
+	if err == mongo.ErrNoDocuments || errors.Is(err, primitive.ErrInvalidHex) {
+		http.Error(w, "Invalid or expired stream token", http.StatusUnauthorized)
+		return false
+	}
+	if err != nil {
+		log.Printf("Error loading stream owner: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if user.MustChangePassword {
+		http.Error(w, "Password change required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// proxyImage forwards an artwork request
+func (h *StreamHandler) proxyImage(w http.ResponseWriter, r *http.Request, itemID, rest string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	match := streamImagePattern.FindStringSubmatch(rest)
+	if match == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	resp, err := h.forward(r, "/Items/"+itemID+"/Images/"+match[1]+match[2], forwardedQuery(r.URL.Query()))
+	if err != nil {
+		log.Printf("Error proxying image for %s: %v", itemID, err)
+		http.Error(w, "Error contacting Jellyfin", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	copyStreamResponse(w, r, resp)
+}
+
+// proxyMedia forwards an authenticated playback request, rewriting playlists
+// and PlaybackInfo so that every media URL points back at the proxy
+func (h *StreamHandler) proxyMedia(w http.ResponseWriter, r *http.Request, itemID, rest string, grant streamGrant) {
+	// The API key can read every item, so check the user's libraries first
+	allowed, err := h.canAccess(grant.JellyfinUserID, itemID)
+	if err != nil {
+		log.Printf("Error checking access to %s: %v", itemID, err)
+		http.Error(w, "Error contacting Jellyfin", http.StatusBadGateway)
+		return
+	}
+	if !allowed {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	query := forwardedQuery(r.URL.Query())
+	lowerRest := strings.ToLower(rest)
+
+	var upstreamPath string
+	switch lowerRest {
+	case "playbackinfo":
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		upstreamPath = "/Items/" + itemID + "/PlaybackInfo"
+		query.Set("UserId", grant.JellyfinUserID)
+	default:
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		upstreamPath = "/Videos/" + itemID + "/" + rest
+	}
+
+	resp, err := h.forward(r, upstreamPath, query)
+	if err != nil {
+		log.Printf("Error proxying %s: %v", upstreamPath, err)
+		http.Error(w, "Error contacting Jellyfin", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || r.Method == http.MethodHead {
+		copyStreamResponse(w, r, resp)
+		return
+	}
+
+	rewriter, err := h.newStreamRewriter(upstreamPath, grant)
+	if err != nil {
+		log.Printf("Error preparing stream rewriter: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case lowerRest == "playbackinfo":
+		h.writeRewrittenPlaybackInfo(w, resp, rewriter)
+	case isPlaylistResponse(upstreamPath, resp):
+		h.writeRewrittenPlaylist(w, resp, rewriter)
+	default:
+		copyStreamResponse(w, r, resp)
+	}
+}
+
+// canAccess reports whether a Jellyfin user can see an item. Jellyfin answers
+// 404 (or 403) for items outside the user's libraries.
+func (h *StreamHandler) canAccess(jellyfinUserID, itemID string) (bool, error) {
+	key := jellyfinUserID + "/" + strings.ToLower(strings.ReplaceAll(itemID, "-", ""))
+	now := time.Now()
+
+	h.accessMu.Lock()
+	expires, ok := h.access[key]
+	h.accessMu.Unlock()
+	if ok && now.Before(expires) {
+		return true, nil
+	}
+
+	var item struct {
+		Id string `json:"Id"`
+	}
+	err := jellyfinGetJSON(h.config, fmt.Sprintf("/Users/%s/Items/%s", jellyfinUserID, itemID), nil, &item)
+	if statusErr, ok := err.(*jellyfinStatusError); ok &&
+		(statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusForbidden || statusErr.StatusCode == http.StatusBadRequest) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	h.accessMu.Lock()
+	defer h.accessMu.Unlock()
+	for k, expiry := range h.access {
+		if now.After(expiry) {
+			delete(h.access, k)
+		}
+	}
+	h.access[key] = now.Add(itemAccessTTL)
+	return true, nil
+}
+
+// forward sends the request to Jellyfin with the server API key
+func (h *StreamHandler) forward(r *http.Request, upstreamPath string, query url.Values) (*http.Response, error) {
+	upstreamURL := h.config.JellyfinURL + (&url.URL{Path: upstreamPath}).EscapedPath()
+	if len(query) > 0 {
+		upstreamURL += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if r.Method == http.MethodPost {
+		body = r.Body
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, upstreamURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	for _, header := range streamRequestHeaders {
+		if value := r.Header.Get(header); value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	if h.config.JellyfinAPIKey != "" {
+		req.Header.Set("X-Emby-Token", h.config.JellyfinAPIKey)
+	}
+
+	return h.client.Do(req)
+}
+
+// copyStreamResponse relays status, selected headers and body unchanged
+func copyStreamResponse(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+	for _, header := range streamResponseHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges")
+	w.WriteHeader(resp.StatusCode)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil && r.Context().Err() == nil {
+		log.Printf("Error streaming response: %v", err)
+	}
+}
+
+// isPlaylistResponse reports whether a response is an HLS playlist
+func isPlaylistResponse(upstreamPath string, resp *http.Response) bool {
+	if strings.HasSuffix(strings.ToLower(upstreamPath), ".m3u8") {
+		return true
+	}
+	return strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "mpegurl")
+}
+
+// writeRewrittenPlaylist rewrites every URI in an HLS playlist to the proxy
+func (h *StreamHandler) writeRewrittenPlaylist(w http.ResponseWriter, resp *http.Response, rewriter *streamRewriter) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPlaylistSize))
+	if err != nil {
+		http.Error(w, "Error reading playlist", http.StatusBadGateway)
+		return
+	}
+
+	playlist := rewriter.rewritePlaylist(string(body))
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(playlist))
+}
+
+// writeRewrittenPlaybackInfo rewrites TranscodingUrl, DeliveryUrl and similar
+// fields of a PlaybackInfo response to the proxy
+func (h *StreamHandler) writeRewrittenPlaybackInfo(w http.ResponseWriter, resp *http.Response, rewriter *streamRewriter) {
+	decoder := json.NewDecoder(io.LimitReader(resp.Body, maxPlaylistSize))
+	decoder.UseNumber()
+
+	var playbackInfo interface{}
+	if err := decoder.Decode(&playbackInfo); err != nil {
+		http.Error(w, "Error parsing playback info", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(rewriter.rewriteJSON(playbackInfo))
+}
+
+// streamRewriter maps Jellyfin /Videos URLs found in playlists and
+// PlaybackInfo to /api/stream URLs carrying a stream token
+type streamRewriter struct {
+	base     *url.URL
+	basePath string
+	tokens   map[string]string
+	newToken func(itemID string) (string, error)
+}
+
+// newStreamRewriter creates a rewriter resolving relative URLs against the
+// upstream document the client asked for
+func (h *StreamHandler) newStreamRewriter(upstreamPath string, grant streamGrant) (*streamRewriter, error) {
+	server, err := url.Parse(h.config.JellyfinURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Jellyfin URL: %v", err)
+	}
+	basePath := strings.TrimSuffix(server.Path, "/")
+
+	base := *server
+	base.Path = basePath + upstreamPath
+	base.RawPath = ""
+
+	ttl := h.config.StreamTokenTTL
+	return &streamRewriter{
+		base:     &base,
+		basePath: strings.ToLower(basePath),
+		tokens:   make(map[string]string),
+		newToken: func(itemID string) (string, error) {
+			return generateStreamToken(itemID, grant, ttl)
+		},
+	}, nil
+}
+
+// rewriteURL returns the proxy URL for a Jellyfin media URL, or false when the
+// URL does not point at a proxied endpoint
+func (rw *streamRewriter) rewriteURL(raw string) (string, bool) {
+	ref, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || raw == "" {
+		return "", false
+	}
+
+	target := rw.base.ResolveReference(ref)
+	if target.Host != rw.base.Host {
+		return "", false
+	}
+
+	// Jellyfin returns server-relative URLs without its base path in PlaybackInfo
+	// but relative ones in playlists, so accept both forms
+	targetPath := target.Path
+	if rw.basePath != "" && strings.HasPrefix(strings.ToLower(targetPath), rw.basePath+"/") {
+		targetPath = targetPath[len(rw.basePath):]
+	}
+	if !strings.HasPrefix(strings.ToLower(targetPath), "/videos/") {
+		return "", false
+	}
+
+	itemID, rest, ok := splitItemPath(targetPath[len("/videos/"):])
+	if !ok {
+		return "", false
+	}
+
+	token, ok := rw.tokens[itemID]
+	if !ok {
+		token, err = rw.newToken(itemID)
+		if err != nil {
+			log.Printf("Error generating stream token: %v", err)
+			return "", false
+		}
+		rw.tokens[itemID] = token
+	}
+
+	query := forwardedQuery(target.Query())
+	query.Set("st", token)
+
+	proxied := url.URL{Path: streamPathPrefix + itemID + "/" + rest, RawQuery: query.Encode()}
+	return proxied.String(), true
+}
+
+// rewritePlaylist rewrites URI lines and URI="..." attributes of a playlist
+func (rw *streamRewriter) rewritePlaylist(playlist string) string {
+	lines := strings.Split(playlist, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "#") {
+			lines[i] = playlistURIAttribute.ReplaceAllStringFunc(line, func(attribute string) string {
+				value := playlistURIAttribute.FindStringSubmatch(attribute)[1]
+				if proxied, ok := rw.rewriteURL(value); ok {
+					return `URI="` + proxied + `"`
+				}
+				return attribute
+			})
+			continue
+		}
+
+		if proxied, ok := rw.rewriteURL(trimmed); ok {
+			lines[i] = proxied
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rewriteJSON walks a decoded JSON document and rewrites string fields whose
+// name ends in "Url"
+func (rw *streamRewriter) rewriteJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if s, ok := child.(string); ok && strings.HasSuffix(key, "Url") {
+				if proxied, ok := rw.rewriteURL(s); ok {
+					v[key] = proxied
+				}
+				continue
+			}
+			v[key] = rw.rewriteJSON(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = rw.rewriteJSON(child)
+		}
+	}
+	return value
+}
@@ -1,23 +1,40 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
 	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
 )
 
 // TMDBHandler handles TMDB API proxy requests
 type TMDBHandler struct {
 	config *config.Config
+	index  *libraryIndex
 }
 
 // NewTMDBHandler creates a new TMDBHandler
 func NewTMDBHandler(cfg *config.Config) *TMDBHandler {
-	return &TMDBHandler{config: cfg}
+	return &TMDBHandler{
+		config: cfg,
+		index:  newLibraryIndex(cfg),
+	}
+}
+
+// SubscribeEvents keeps the library index behind enriched lists up to date
+func (h *TMDBHandler) SubscribeEvents() {
+	h.index.subscribe()
 }
 
 // makeRequest makes an HTTP request to TMDB API
@@ -73,6 +90,115 @@ func (h *TMDBHandler) Proxy(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
+// writeList writes a TMDB list response. With enrich=true a successful
+// response is annotated with the library state of each item as the caller's
+// Jellyfin user sees it (see enrichResults); otherwise it is passed through
+// unchanged.
+func (h *TMDBHandler) writeList(w http.ResponseWriter, r *http.Request, body []byte, statusCode int, mediaType string) {
+	if r.URL.Query().Get("enrich") == "true" && statusCode == http.StatusOK {
+		var list map[string]interface{}
+		if err := json.Unmarshal(body, &list); err != nil {
+			log.Printf("Error decoding TMDB list for enrichment: %v", err)
+		} else if results, ok := list["results"].([]interface{}); ok {
+			// Without a Jellyfin user nothing is reported as in the library
+			jellyfinUserID, _ := resolveJellyfinUserID(h.config, r)
+			h.enrichResults(results, mediaType, jellyfinUserID)
+			if enriched, err := json.Marshal(list); err == nil {
+				body = enriched
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// enrichResults adds inLibrary, jellyfinId, radarrStatus or sonarrStatus and
+// requested to each movie or show in results, with inLibrary and jellyfinId
+// as jellyfinUserID sees the library. mediaType "all" reads the type of each
+// item from its media_type; people are left alone.
+func (h *TMDBHandler) enrichResults(results []interface{}, mediaType, jellyfinUserID string) {
+	type listItem struct {
+		fields    map[string]interface{}
+		mediaType string
+		tmdbID    int
+	}
+
+	items := make([]listItem, 0, len(results))
+	tmdbIDs := make(bson.A, 0, len(results))
+	for _, result := range results {
+		fields, ok := result.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		itemType := mediaType
+		if itemType != models.MediaTypeMovie && itemType != models.MediaTypeTV {
+			itemType, _ = fields["media_type"].(string)
+		}
+		id, _ := fields["id"].(float64)
+		if (itemType != models.MediaTypeMovie && itemType != models.MediaTypeTV) || id <= 0 {
+			continue
+		}
+		items = append(items, listItem{fields: fields, mediaType: itemType, tmdbID: int(id)})
+		tmdbIDs = append(tmdbIDs, int(id))
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	requested := h.requestedTitles(tmdbIDs)
+	snapshot := h.index.get(jellyfinUserID)
+
+	for _, item := range items {
+		var jellyfinID, arrStatus string
+		if item.mediaType == models.MediaTypeMovie {
+			jellyfinID = snapshot.jellyfinMovies[tmdbKey(item.tmdbID)]
+			arrStatus = snapshot.radarr[item.tmdbID]
+			if arrStatus != "" {
+				item.fields["radarrStatus"] = arrStatus
+			}
+		} else {
+			jellyfinID = snapshot.jellyfinSeries[tmdbKey(item.tmdbID)]
+			arrStatus = snapshot.sonarr[item.tmdbID]
+			if arrStatus != "" {
+				item.fields["sonarrStatus"] = arrStatus
+			}
+		}
+		item.fields["inLibrary"] = jellyfinID != ""
+		if jellyfinID != "" {
+			item.fields["jellyfinId"] = jellyfinID
+		}
+		item.fields["requested"] = requested[fmt.Sprintf("%s:%d", item.mediaType, item.tmdbID)]
+	}
+}
+
+// requestedTitles returns the "mediaType:tmdbId" keys of the given titles
+// that have a pending or approved request
+func (h *TMDBHandler) requestedTitles(tmdbIDs bson.A) map[string]bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	requested := make(map[string]bool)
+	cursor, err := database.RequestsCollection.Find(ctx, bson.M{
+		"tmdbId": bson.M{"$in": tmdbIDs},
+		"status": bson.M{"$in": bson.A{models.RequestStatusPending, models.RequestStatusApproved}},
+	}, options.Find().SetProjection(bson.M{"mediaType": 1, "tmdbId": 1}))
+	if err != nil {
+		log.Printf("Error fetching requests for enrichment: %v", err)
+		return requested
+	}
+	var requests []models.MediaRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		log.Printf("Error decoding requests for enrichment: %v", err)
+		return requested
+	}
+	for _, request := range requests {
+		requested[fmt.Sprintf("%s:%d", request.MediaType, request.TMDBID)] = true
+	}
+	return requested
+}
+
 // GetTrending handles trending movies/tv requests
 func (h *TMDBHandler) GetTrending(w http.ResponseWriter, r *http.Request) {
 	mediaType := r.URL.Query().Get("type")
@@ -95,9 +221,7 @@ func (h *TMDBHandler) GetTrending(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	w.Write(body)
+	h.writeList(w, r, body, statusCode, mediaType)
 }
 
 // GetPopular handles popular movies requests
@@ -114,9 +238,7 @@ func (h *TMDBHandler) GetPopular(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	w.Write(body)
+	h.writeList(w, r, body, statusCode, models.MediaTypeMovie)
 }
 
 // GetMovieDetails handles movie details requests
@@ -156,6 +278,7 @@ func (h *TMDBHandler) GetGenres(w http.ResponseWriter, r *http.Request) {
 // Discover handles movie discovery requests
 func (h *TMDBHandler) Discover(w http.ResponseWriter, r *http.Request) {
 	queryParams := r.URL.Query()
+	queryParams.Del("enrich")
 	queryParams.Set("language", "en-US")
 
 	tmdbURL := fmt.Sprintf("https://api.themoviedb.org/3/discover/movie?%s", queryParams.Encode())
@@ -165,9 +288,7 @@ func (h *TMDBHandler) Discover(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	w.Write(body)
+	h.writeList(w, r, body, statusCode, models.MediaTypeMovie)
 }
 
 // Search handles movie search requests
@@ -191,9 +312,7 @@ func (h *TMDBHandler) Search(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	w.Write(body)
+	h.writeList(w, r, body, statusCode, models.MediaTypeMovie)
 }
 
 // GetTVTrending handles trending TV shows requests
@@ -214,9 +333,7 @@ func (h *TMDBHandler) GetTVTrending(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	w.Write(body)
+	h.writeList(w, r, body, statusCode, models.MediaTypeTV)
 }
 
 // GetTVPopular handles popular TV shows requests
@@ -233,9 +350,7 @@ func (h *TMDBHandler) GetTVPopular(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	w.Write(body)
+	h.writeList(w, r, body, statusCode, models.MediaTypeTV)
 }
 
 // GetTVDetails handles TV show details requests
@@ -302,7 +417,5 @@ func (h *TMDBHandler) SearchTV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	w.Write(body)
+	h.writeList(w, r, body, statusCode, models.MediaTypeTV)
 }
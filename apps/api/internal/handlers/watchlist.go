@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+)
+
+// WatchlistHandler manages each user's watchlist
+type WatchlistHandler struct {
+	config   *config.Config
+	tmdb     *TMDBHandler
+	requests *RequestHandler
+}
+
+// NewWatchlistHandler creates a watchlist handler that resolves entries with
+// the library index of tmdb and requests titles through requests
+func NewWatchlistHandler(cfg *config.Config, tmdb *TMDBHandler, requests *RequestHandler) *WatchlistHandler {
+	return &WatchlistHandler{
+		config:   cfg,
+		tmdb:     tmdb,
+		requests: requests,
+	}
+}
+
+// watchlistKeyFromPath extracts the entry from /api/watchlist/{mediaType}/{tmdbId}
+func watchlistKeyFromPath(p string) (models.WatchlistKey, error) {
+	mediaType, id, _ := strings.Cut(strings.Trim(strings.TrimPrefix(p, "/api/watchlist/"), "/"), "/")
+	tmdbID, err := strconv.Atoi(id)
+	if err != nil || tmdbID <= 0 {
+		return models.WatchlistKey{}, fmt.Errorf("invalid TMDB ID")
+	}
+	if mediaType != models.MediaTypeMovie && mediaType != models.MediaTypeTV {
+		return models.WatchlistKey{}, fmt.Errorf("mediaType must be movie or tv")
+	}
+	return models.WatchlistKey{MediaType: mediaType, TMDBID: tmdbID}, nil
+}
+
+// loadWatchlist returns a user's watchlist in order
+func loadWatchlist(ctx context.Context, user *models.User) ([]models.WatchlistItem, error) {
+	cursor, err := database.WatchlistCollection.Find(ctx, bson.M{"userId": user.ID},
+		options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "addedAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	items := []models.WatchlistItem{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// resolve fills in where each entry stands in Jellyfin, Radarr/Sonarr and
+// the request queue. The library is what the user's Jellyfin user (or the
+// shared JELLYFIN_USER_ID) can see.
+func (h *WatchlistHandler) resolve(user *models.User, items []models.WatchlistItem) {
+	if len(items) == 0 {
+		return
+	}
+
+	jellyfinUserID := user.JellyfinUserID
+	if jellyfinUserID == "" {
+		jellyfinUserID = h.config.JellyfinUserID
+	}
+
+	tmdbIDs := make(bson.A, 0, len(items))
+	for _, item := range items {
+		tmdbIDs = append(tmdbIDs, item.TMDBID)
+	}
+	requested := h.tmdb.requestedTitles(tmdbIDs)
+	snapshot := h.tmdb.index.get(jellyfinUserID)
+
+	for i := range items {
+		item := &items[i]
+		if item.MediaType == models.MediaTypeMovie {
+			item.JellyfinID = snapshot.jellyfinMovies[tmdbKey(item.TMDBID)]
+			item.RadarrStatus = snapshot.radarr[item.TMDBID]
+		} else {
+			item.JellyfinID = snapshot.jellyfinSeries[tmdbKey(item.TMDBID)]
+			item.SonarrStatus = snapshot.sonarr[item.TMDBID]
+		}
+		item.InLibrary = item.JellyfinID != ""
+		item.Requested = requested[fmt.Sprintf("%s:%d", item.MediaType, item.TMDBID)]
+	}
+}
+
+// autoRequest requests a resolved entry that is not in the library, already
+// in Radarr/Sonarr or requested, through the regular request flow
+func (h *WatchlistHandler) autoRequest(r *http.Request, item *models.WatchlistItem) {
+	if item.InLibrary || item.RadarrStatus != "" || item.SonarrStatus != "" || item.Requested {
+		return
+	}
+
+	request, reqErr := h.requests.create(r, models.CreateMediaRequest{
+		MediaType:  item.MediaType,
+		TMDBID:     item.TMDBID,
+		Title:      item.Title,
+		Year:       item.Year,
+		PosterPath: item.PosterPath,
+	})
+	switch {
+	case reqErr != nil && reqErr.Status == http.StatusConflict:
+		item.Requested = true
+	case reqErr != nil:
+		item.RequestError = reqErr.Message
+	case request.Status == models.RequestStatusFailed:
+		item.RequestError = request.Error
+	default:
+		item.Requested = true
+	}
+}
+
+// writeWatchlist responds with the user's resolved watchlist
+func (h *WatchlistHandler) writeWatchlist(w http.ResponseWriter, user *models.User, items []models.WatchlistItem) {
+	h.resolve(user, items)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.WatchlistResponse{Items: items, AutoRequest: user.WatchlistAutoRequest})
+}
+
+// GetWatchlist returns the caller's watchlist in their order, each entry
+// resolved to its Jellyfin item when the library has it
+func (h *WatchlistHandler) GetWatchlist(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		http.Error(w, "Error loading user", http.StatusInternalServerError)
+		return
+	}
+	items, err := loadWatchlist(ctx, user)
+	if err != nil {
+		http.Error(w, "Error loading watchlist", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeWatchlist(w, user, items)
+}
+
+// AddToWatchlist adds a title to the end of the caller's watchlist. With
+// automatic requests on, a title the library does not have is requested.
+func (h *WatchlistHandler) AddToWatchlist(w http.ResponseWriter, r *http.Request) {
+	var req models.AddWatchlistItem
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MediaType != models.MediaTypeMovie && req.MediaType != models.MediaTypeTV {
+		http.Error(w, "mediaType must be movie or tv", http.StatusBadRequest)
+		return
+	}
+	if req.TMDBID <= 0 {
+		http.Error(w, "tmdbId required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		http.Error(w, "Error loading user", http.StatusInternalServerError)
+		return
+	}
+
+	position := 0
+	var last models.WatchlistItem
+	err = database.WatchlistCollection.FindOne(ctx, bson.M{"userId": user.ID},
+		options.FindOne().SetSort(bson.D{{Key: "position", Value: -1}})).Decode(&last)
+	if err == nil {
+		position = last.Position + 1
+	} else if err != mongo.ErrNoDocuments {
+		http.Error(w, "Error loading watchlist", http.StatusInternalServerError)
+		return
+	}
+
+	item := models.WatchlistItem{
+		UserID:     user.ID,
+		MediaType:  req.MediaType,
+		TMDBID:     req.TMDBID,
+		Title:      strings.TrimSpace(req.Title),
+		Year:       req.Year,
+		PosterPath: req.PosterPath,
+		Position:   position,
+		AddedAt:    time.Now(),
+	}
+	result, err := database.WatchlistCollection.InsertOne(ctx, item)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "This title is already on your watchlist", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error adding to watchlist: %v", err)
+		http.Error(w, "Error adding to watchlist", http.StatusInternalServerError)
+		return
+	}
+	item.ID = result.InsertedID.(primitive.ObjectID)
+
+	items := []models.WatchlistItem{item}
+	h.resolve(user, items)
+	if user.WatchlistAutoRequest {
+		h.autoRequest(r, &items[0])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(items[0])
+}
+
+// RemoveFromWatchlist removes /api/watchlist/{mediaType}/{tmdbId} from the
+// caller's watchlist
+func (h *WatchlistHandler) RemoveFromWatchlist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key, err := watchlistKeyFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	objectID, err := contextUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := database.WatchlistCollection.DeleteOne(ctx, bson.M{
+		"userId":    objectID,
+		"mediaType": key.MediaType,
+		"tmdbId":    key.TMDBID,
+	})
+	if err != nil {
+		http.Error(w, "Error removing from watchlist", http.StatusInternalServerError)
+		return
+	}
+	if result.DeletedCount == 0 {
+		http.Error(w, "Title is not on your watchlist", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Removed from watchlist"})
+}
+
+// reorderWatchlist puts the entries listed in keys first, in that order,
+// followed by the unlisted entries in their current order
+func reorderWatchlist(items []models.WatchlistItem, keys []models.WatchlistKey) ([]models.WatchlistItem, error) {
+	byKey := make(map[models.WatchlistKey]int, len(items))
+	for i, item := range items {
+		byKey[models.WatchlistKey{MediaType: item.MediaType, TMDBID: item.TMDBID}] = i
+	}
+
+	ordered := make([]models.WatchlistItem, 0, len(items))
+	placed := make(map[int]bool, len(keys))
+	for _, key := range keys {
+		i, ok := byKey[key]
+		if !ok {
+			return nil, fmt.Errorf("%s %d is not on your watchlist", key.MediaType, key.TMDBID)
+		}
+		if placed[i] {
+			return nil, fmt.Errorf("%s %d is listed more than once", key.MediaType, key.TMDBID)
+		}
+		placed[i] = true
+		ordered = append(ordered, items[i])
+	}
+	for i, item := range items {
+		if !placed[i] {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered, nil
+}
+
+// ReorderWatchlist puts the caller's watchlist in the order given
+func (h *WatchlistHandler) ReorderWatchlist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ReorderWatchlist
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		http.Error(w, "Error loading user", http.StatusInternalServerError)
+		return
+	}
+	items, err := loadWatchlist(ctx, user)
+	if err != nil {
+		http.Error(w, "Error loading watchlist", http.StatusInternalServerError)
+		return
+	}
+
+	ordered, err := reorderWatchlist(items, req.Items)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var writes []mongo.WriteModel
+	for position := range ordered {
+		if ordered[position].Position == position {
+			continue
+		}
+		ordered[position].Position = position
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": ordered[position].ID}).
+			SetUpdate(bson.M{"$set": bson.M{"position": position}}))
+	}
+	if len(writes) > 0 {
+		if _, err := database.WatchlistCollection.BulkWrite(ctx, writes); err != nil {
+			http.Error(w, "Error saving watchlist order", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.writeWatchlist(w, user, ordered)
+}
+
+// UpdateSettings saves the caller's watchlist options. Turning automatic
+// requests on also requests the titles already listed that the library
+// does not have.
+func (h *WatchlistHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.WatchlistSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		http.Error(w, "Error loading user", http.StatusInternalServerError)
+		return
+	}
+	_, err = database.UsersCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"watchlistAutoRequest": req.AutoRequest, "updatedAt": time.Now()},
+	})
+	if err != nil {
+		http.Error(w, "Error saving watchlist settings", http.StatusInternalServerError)
+		return
+	}
+	user.WatchlistAutoRequest = req.AutoRequest
+
+	items, err := loadWatchlist(ctx, user)
+	if err != nil {
+		http.Error(w, "Error loading watchlist", http.StatusInternalServerError)
+		return
+	}
+	h.resolve(user, items)
+	if req.AutoRequest {
+		for i := range items {
+			h.autoRequest(r, &items[i])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.WatchlistResponse{Items: items, AutoRequest: user.WatchlistAutoRequest})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"jellystreaming/internal/models"
+)
+
+func testWatchlist() []models.WatchlistItem {
+	return []models.WatchlistItem{
+		{MediaType: models.MediaTypeMovie, TMDBID: 348, Title: "Alien", Position: 0},
+		{MediaType: models.MediaTypeTV, TMDBID: 95396, Title: "Severance", Position: 1},
+		{MediaType: models.MediaTypeMovie, TMDBID: 679, Title: "Aliens", Position: 2},
+		{MediaType: models.MediaTypeTV, TMDBID: 348, Title: "Same ID, other type", Position: 3},
+	}
+}
+
+// titles lists the titles of items in order
+func titles(items []models.WatchlistItem) string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Title
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestReorderWatchlist(t *testing.T) {
+	ordered, err := reorderWatchlist(testWatchlist(), []models.WatchlistKey{
+		{MediaType: models.MediaTypeTV, TMDBID: 348},
+		{MediaType: models.MediaTypeMovie, TMDBID: 679},
+		{MediaType: models.MediaTypeMovie, TMDBID: 348},
+		{MediaType: models.MediaTypeTV, TMDBID: 95396},
+	})
+	if err != nil {
+		t.Fatalf("reorderWatchlist: %v", err)
+	}
+	if got, want := titles(ordered), "Same ID, other type, Aliens, Alien, Severance"; got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+}
+
+func TestReorderWatchlistUnlistedFollow(t *testing.T) {
+	ordered, err := reorderWatchlist(testWatchlist(), []models.WatchlistKey{
+		{MediaType: models.MediaTypeMovie, TMDBID: 679},
+	})
+	if err != nil {
+		t.Fatalf("reorderWatchlist: %v", err)
+	}
+	if got, want := titles(ordered), "Aliens, Alien, Severance, Same ID, other type"; got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+
+	ordered, err = reorderWatchlist(testWatchlist(), nil)
+	if err != nil {
+		t.Fatalf("reorderWatchlist with no keys: %v", err)
+	}
+	if got, want := titles(ordered), titles(testWatchlist()); got != want {
+		t.Errorf("order with no keys = %s, want %s", got, want)
+	}
+}
+
+func TestReorderWatchlistUnknownKey(t *testing.T) {
+	for _, key := range []models.WatchlistKey{
+		{MediaType: models.MediaTypeMovie, TMDBID: 1},
+		// On the list as a movie, not as a show
+		{MediaType: models.MediaTypeTV, TMDBID: 679},
+	} {
+		_, err := reorderWatchlist(testWatchlist(), []models.WatchlistKey{
+			{MediaType: models.MediaTypeMovie, TMDBID: 348},
+			key,
+		})
+		if err == nil || !strings.Contains(err.Error(), "is not on your watchlist") {
+			t.Errorf("%s %d: err = %v, want not on your watchlist", key.MediaType, key.TMDBID, err)
+		}
+	}
+}
+
+func TestReorderWatchlistDuplicateKey(t *testing.T) {
+	_, err := reorderWatchlist(testWatchlist(), []models.WatchlistKey{
+		{MediaType: models.MediaTypeMovie, TMDBID: 679},
+		{MediaType: models.MediaTypeMovie, TMDBID: 348},
+		{MediaType: models.MediaTypeMovie, TMDBID: 679},
+	})
+	if err == nil || err.Error() != "movie 679 is listed more than once" {
+		t.Fatalf("err = %v, want movie 679 is listed more than once", err)
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/events"
+	"jellystreaming/internal/models"
+)
+
+// maxWebhookBody bounds the size of an inbound webhook payload
+const maxWebhookBody = 1 << 20
+
+// WebhookHandler receives Radarr and Sonarr Connect webhooks and Jellyfin
+// Webhook plugin events and publishes them as internal events
+type WebhookHandler struct {
+	config *config.Config
+}
+
+// NewWebhookHandler creates a new WebhookHandler
+func NewWebhookHandler(cfg *config.Config) *WebhookHandler {
+	return &WebhookHandler{config: cfg}
+}
+
+// radarrEventTypes maps Radarr webhook event types to internal events
+var radarrEventTypes = map[string]string{
+	"Grab":           events.RadarrGrab,
+	"Download":       events.RadarrDownload,
+	"Rename":         events.RadarrRename,
+	"MovieDelete":    events.RadarrMovieDelete,
+	"Health":         events.RadarrHealth,
+	"HealthRestored": events.RadarrHealth,
+}
+
+// jellyfinEventTypes maps Jellyfin Webhook plugin notification types to
+// internal events
+var jellyfinEventTypes = map[string]string{
+	"ItemAdded":     events.JellyfinItemAdded,
+	"PlaybackStart": events.JellyfinPlaybackStart,
+	"PlaybackStop":  events.JellyfinPlaybackStop,
+	"UserDataSaved": events.JellyfinUserDataSaved,
+}
+
+// sonarrEventTypes maps Sonarr webhook event types to internal events
+var sonarrEventTypes = map[string]string{
+	"Grab":           events.SonarrGrab,
+	"Download":       events.SonarrDownload,
+	"Rename":         events.SonarrRename,
+	"SeriesDelete":   events.SonarrSeriesDelete,
+	"Health":         events.SonarrHealth,
+	"HealthRestored": events.SonarrHealth,
+}
+
+// authorizeWebhook checks a shared secret, sent as the X-Webhook-Secret (or
+// X-Webhook-Token) header, the webhook's basic auth password or a ?secret= (or
+// ?token=) query parameter. A 404 or 401 has been written when it returns false.
+func authorizeWebhook(w http.ResponseWriter, r *http.Request, expected string) bool {
+	if expected == "" {
+		http.Error(w, "Webhooks are not configured", http.StatusNotFound)
+		return false
+	}
+
+	secret := r.Header.Get("X-Webhook-Secret")
+	if secret == "" {
+		secret = r.Header.Get("X-Webhook-Token")
+	}
+	if secret == "" {
+		if _, password, ok := r.BasicAuth(); ok {
+			secret = password
+		}
+	}
+	if secret == "" {
+		secret = r.URL.Query().Get("secret")
+	}
+	if secret == "" {
+		secret = r.URL.Query().Get("token")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) != 1 {
+		http.Error(w, "Invalid webhook secret", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// Radarr receives Radarr webhook events
+func (h *WebhookHandler) Radarr(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeWebhook(w, r, h.config.WebhookSecret) {
+		return
+	}
+
+	var payload models.RadarrWebhookPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	eventType := radarrEventTypes[payload.EventType]
+	if payload.EventType == "Download" && payload.IsUpgrade {
+		eventType = events.RadarrUpgrade
+	}
+	h.publish(w, "Radarr", payload.EventType, eventType, &payload)
+}
+
+// Sonarr receives Sonarr webhook events
+func (h *WebhookHandler) Sonarr(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeWebhook(w, r, h.config.WebhookSecret) {
+		return
+	}
+
+	var payload models.SonarrWebhookPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	eventType := sonarrEventTypes[payload.EventType]
+	if payload.EventType == "Download" && payload.IsUpgrade {
+		eventType = events.SonarrUpgrade
+	}
+	h.publish(w, "Sonarr", payload.EventType, eventType, &payload)
+}
+
+// Jellyfin receives events from the Jellyfin Webhook plugin, authenticated
+// with JELLYFIN_WEBHOOK_TOKEN
+func (h *WebhookHandler) Jellyfin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeWebhook(w, r, h.config.JellyfinWebhookToken) {
+		return
+	}
+
+	var payload models.JellyfinWebhookPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	eventType := jellyfinEventTypes[payload.NotificationType]
+	if eventType == "" {
+		h.publish(w, "Jellyfin", payload.NotificationType, "", nil)
+		return
+	}
+
+	event := payload.Normalize(eventType)
+	if event.JellyfinUserID != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := database.UsersCollection.FindOne(ctx, bson.M{"jellyfinUserId": event.JellyfinUserID}).Decode(&user)
+		if err == nil {
+			event.UserID = user.ID.Hex()
+		} else if err != mongo.ErrNoDocuments {
+			log.Printf("Error resolving Jellyfin user %s: %v", event.JellyfinUserID, err)
+		}
+	}
+
+	h.publish(w, "Jellyfin", payload.NotificationType, eventType, event)
+}
+
+// publish acknowledges a webhook and publishes it when it maps to an internal
+// event. Test and unsupported events are acknowledged so the sender keeps the
+// connection healthy.
+func (h *WebhookHandler) publish(w http.ResponseWriter, source, arrEventType, eventType string, payload interface{}) {
+	message := "Event ignored"
+	if eventType != "" {
+		events.Publish(eventType, payload)
+		message = "Event received"
+	} else if arrEventType == "Test" {
+		log.Printf("%s webhook test received", source)
+		message = "Test received"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
@@ -3,28 +3,55 @@ package middleware
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 
 	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
 )
 
 // Claims represents JWT claims
 type Claims struct {
-	UserID   string `json:"userId"`
-	Username string `json:"username"`
-	IsAdmin  bool   `json:"isAdmin"`
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	IsAdmin   bool   `json:"isAdmin"`
+	SessionID string `json:"sid"`
+	// Permissions are resolved from the user's roles when the token is issued
+	Permissions []string `json:"perms,omitempty"`
+	// MustChangePassword limits the token to the password change routes
+	MustChangePassword bool `json:"mcp,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// passwordChangeRoutes remain reachable while a password change is pending:
+// the change itself, logout, and reading the current user
+var passwordChangeRoutes = map[string]bool{
+	"/api/auth/change-password": true,
+	"/api/auth/logout":          true,
+	"/api/auth/verify":          true,
+	"/api/auth/me":              true,
+}
+
+// apiKeyRoutes are the only /api/auth/ routes reachable with an API key, so a
+// leaked key cannot change the password, manage sessions or mint more keys
+var apiKeyRoutes = map[string]bool{
+	"/api/auth/verify":   true,
+	"/api/auth/me":       true,
+	"/api/auth/me/quota": true,
+}
+
 // EnableCORS adds CORS headers to responses
 func EnableCORS(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range, X-Api-Key")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -51,40 +78,214 @@ func validateToken(tokenString string) (*Claims, error) {
 		return nil, errors.New("invalid token")
 	}
 
+	// Access tokens are always bound to a server-side session
+	if claims.SessionID == "" {
+		return nil, errors.New("token is not bound to a session")
+	}
+
+	// Purpose-specific tokens (MFA challenges, stream tokens) carry an audience
+	if len(claims.Audience) > 0 {
+		return nil, errors.New("token is not an access token")
+	}
+
 	return claims, nil
 }
 
-// Auth validates JWT tokens
+// trustedProxies are the networks whose X-Forwarded-For header is believed
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies configures the reverse proxies (IPs or CIDRs) allowed to
+// report the client address in X-Forwarded-For
+func SetTrustedProxies(proxies []string) error {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %v", proxy, err)
+		}
+		networks = append(networks, network)
+	}
+	trustedProxies = networks
+	return nil
+}
+
+// isTrustedProxy reports whether ip belongs to a trusted proxy
+func isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client making the request. When the
+// request comes from a trusted proxy, X-Forwarded-For is walked from the right
+// and the first address not belonging to a trusted proxy is returned.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(host) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if ip == "" {
+			continue
+		}
+		if !isTrustedProxy(ip) {
+			return ip
+		}
+		host = ip
+	}
+	return host
+}
+
+// queryTokenRoute reports whether a route accepts its token in the
+// access_token query parameter. Only the stream proxy and the notification
+// stream need it; anywhere else the token would end up in access logs and
+// Referer headers.
+func queryTokenRoute(path string) bool {
+	return strings.HasPrefix(path, "/api/stream/") || path == "/api/notifications/stream"
+}
+
+// Auth validates JWT tokens, or API keys sent in X-Api-Key (or as a bearer token)
 func Auth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if apiKey := r.Header.Get("X-Api-Key"); apiKey != "" {
+			authenticateAPIKey(w, r, apiKey, next)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+		tokenString := ""
+		if authHeader != "" {
+			// Extract token from "Bearer <token>"
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+				return
+			}
+			tokenString = parts[1]
+			if strings.HasPrefix(tokenString, models.APIKeyPrefix) {
+				authenticateAPIKey(w, r, tokenString, next)
+				return
+			}
+		} else if queryTokenRoute(r.URL.Path) {
+			// Media elements and EventSource cannot set headers
+			tokenString = r.URL.Query().Get("access_token")
+		}
+
+		if tokenString == "" {
 			http.Error(w, "Authorization header required", http.StatusUnauthorized)
 			return
 		}
 
-		// Extract token from "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+		claims, err := validateToken(tokenString)
+		if err != nil {
+			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
 
-		claims, err := validateToken(parts[1])
-		if err != nil {
+		// Reject tokens whose session was revoked (logout, user deleted or demoted)
+		sessionCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = database.CheckSession(sessionCtx, claims.SessionID)
+		cancel()
+		if errors.Is(err, database.ErrSessionInactive) {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
+		if err != nil {
+			log.Printf("Error checking session: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if claims.MustChangePassword && !passwordChangeRoutes[r.URL.Path] {
+			http.Error(w, "Password change required", http.StatusForbidden)
+			return
+		}
 
 		// Add user info to request context
 		ctx := context.WithValue(r.Context(), "userID", claims.UserID)
 		ctx = context.WithValue(ctx, "username", claims.Username)
 		ctx = context.WithValue(ctx, "isAdmin", claims.IsAdmin)
+		ctx = context.WithValue(ctx, "sessionID", claims.SessionID)
+		ctx = context.WithValue(ctx, "permissions", claims.Permissions)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// authenticateAPIKey authenticates a request made with an API key. Keys never
+// act as admin: they only carry the scopes the owner still holds.
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, key string, next http.HandlerFunc) {
+	if strings.HasPrefix(r.URL.Path, "/api/auth/") && !apiKeyRoutes[r.URL.Path] {
+		http.Error(w, "This endpoint cannot be used with an API key", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	apiKey, user, err := database.AuthenticateAPIKey(ctx, key, ClientIP(r))
+	if errors.Is(err, database.ErrAPIKeyInvalid) {
+		http.Error(w, "Invalid or expired API key", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("Error checking API key: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if user.MustChangePassword {
+		http.Error(w, "Password change required", http.StatusForbidden)
+		return
+	}
+
+	held, err := database.ResolvePermissions(ctx, user)
+	if err != nil {
+		log.Printf("Error resolving permissions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	permissions := []string{}
+	for _, scope := range apiKey.Scopes {
+		for _, permission := range held {
+			if scope == permission {
+				permissions = append(permissions, scope)
+				break
+			}
+		}
+	}
+
+	reqCtx := context.WithValue(r.Context(), "userID", user.ID.Hex())
+	reqCtx = context.WithValue(reqCtx, "username", user.Username)
+	reqCtx = context.WithValue(reqCtx, "isAdmin", false)
+	reqCtx = context.WithValue(reqCtx, "sessionID", "")
+	reqCtx = context.WithValue(reqCtx, "permissions", permissions)
+	reqCtx = context.WithValue(reqCtx, "apiKeyID", apiKey.ID.Hex())
+
+	next.ServeHTTP(w, r.WithContext(reqCtx))
+}
+
 // Admin ensures the user is an admin
 func Admin(next http.HandlerFunc) http.HandlerFunc {
 	return Auth(func(w http.ResponseWriter, r *http.Request) {
@@ -96,3 +297,29 @@ func Admin(next http.HandlerFunc) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// HasPermission reports whether the authenticated user holds a permission;
+// admins hold every permission
+func HasPermission(r *http.Request, permission string) bool {
+	if isAdmin, _ := r.Context().Value("isAdmin").(bool); isAdmin {
+		return true
+	}
+	permissions, _ := r.Context().Value("permissions").([]string)
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission ensures the user holds the given permission
+func RequirePermission(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return Auth(func(w http.ResponseWriter, r *http.Request) {
+		if !HasPermission(r, permission) {
+			http.Error(w, "Permission required: "+permission, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyPrefix starts every API key so keys are recognizable in configs and
+// can be told apart from JWTs in an Authorization header
+const APIKeyPrefix = "jsk_"
+
+// APIKey is a long-lived credential for scripts; only its hash is stored
+type APIKey struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID  primitive.ObjectID `bson:"userId" json:"userId"`
+	Name    string             `bson:"name" json:"name"`
+	KeyHash string             `bson:"keyHash" json:"-"`
+	Hint    string             `bson:"hint" json:"hint"` // First characters of the key, for recognizing it
+	// Scopes are the permissions the key may use, limited to the owner's own
+	Scopes     []string   `bson:"scopes" json:"scopes"`
+	CreatedAt  time.Time  `bson:"createdAt" json:"createdAt"`
+	LastUsedAt *time.Time `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	LastUsedIP string     `bson:"lastUsedIp,omitempty" json:"lastUsedIp,omitempty"`
+	ExpiresAt  *time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	RevokedAt  *time.Time `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// CreateAPIKeyRequest for users creating an API key
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"` // 0 means the key never expires
+}
+
+// CreateAPIKeyResponse returns the plaintext key, which is only shown once
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audited actions
+const (
+	AuditUserCreate     = "user.create"
+	AuditUserUpdate     = "user.update"
+	AuditUserDelete     = "user.delete"
+	AuditPasswordChange = "user.password_change"
+	AuditMovieAdd       = "radarr.movie_add"
+	AuditSeriesAdd      = "sonarr.series_add"
+	AuditSeriesUpdate   = "sonarr.series_update"
+	AuditRadarrRefresh  = "radarr.refresh"
+	AuditSonarrRefresh  = "sonarr.refresh"
+	AuditRequestApprove = "request.approve"
+	AuditRequestDecline = "request.decline"
+	AuditBroadcast      = "notification.broadcast"
+	AuditLibraryUpdate  = "settings.libraries_update"
+	AuditInviteCreate   = "invite.create"
+	AuditInviteRevoke   = "invite.revoke"
+	AuditRoleCreate     = "role.create"
+	AuditRoleUpdate     = "role.update"
+	AuditRoleDelete     = "role.delete"
+)
+
+// AuditActor is the user (and API key, if any) that performed an action
+type AuditActor struct {
+	UserID   string `bson:"userId" json:"userId"`
+	Username string `bson:"username" json:"username"`
+	APIKeyID string `bson:"apiKeyId,omitempty" json:"apiKeyId,omitempty"`
+}
+
+// AuditTarget is what an action was performed on
+type AuditTarget struct {
+	Type string `bson:"type" json:"type"` // "user", "movie", "series", "request", "radarr", "sonarr", "role", "users", "invite"
+	ID   string `bson:"id,omitempty" json:"id,omitempty"`
+	Name string `bson:"name,omitempty" json:"name,omitempty"`
+}
+
+// AuditChange is one field that an action changed; secrets are recorded
+// as changed without their values
+type AuditChange struct {
+	Field  string      `bson:"field" json:"field"`
+	Before interface{} `bson:"before,omitempty" json:"before,omitempty"`
+	After  interface{} `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// AuditEvent is a structured record of an administrative or arr action
+type AuditEvent struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Action  string             `bson:"action" json:"action"`
+	Actor   AuditActor         `bson:"actor" json:"actor"`
+	Target  AuditTarget        `bson:"target" json:"target"`
+	Changes []AuditChange      `bson:"changes,omitempty" json:"changes,omitempty"`
+	// Status is the HTTP status returned by Radarr/Sonarr for arr actions
+	Status    int        `bson:"status,omitempty" json:"status,omitempty"`
+	IP        string     `bson:"ip" json:"ip"`
+	UserAgent string     `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	CreatedAt time.Time  `bson:"createdAt" json:"createdAt"`
+	ExpiresAt *time.Time `bson:"expiresAt,omitempty" json:"-"`
+}
+
+// AuditEventsResponse is a page of audit events
+type AuditEventsResponse struct {
+	Items []AuditEvent `json:"items"`
+	Total int64        `json:"total"`
+}
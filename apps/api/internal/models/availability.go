@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Availability states, from most to least available
+const (
+	AvailabilityAvailable    = "available"     // In the Jellyfin library
+	AvailabilityDownloading  = "downloading"   // In the Radarr/Sonarr download queue
+	AvailabilityMonitored    = "monitored"     // Monitored in Radarr/Sonarr, nothing downloading yet
+	AvailabilityRequested    = "requested"     // A pending or approved request exists
+	AvailabilityNotAvailable = "not_available" // Nowhere yet
+)
+
+// Availability is where a title stands across Jellyfin, Radarr/Sonarr and the
+// request queue. Status is the most advanced state; the other fields carry
+// the details of every state that applies.
+type Availability struct {
+	MediaType string `json:"mediaType"`
+	TmdbId    int    `json:"tmdbId"`
+	TvdbId    int    `json:"tvdbId,omitempty"`
+	ImdbId    string `json:"imdbId,omitempty"`
+	Status    string `json:"status"`
+
+	// Jellyfin is the matching library item; series share the movie fields
+	Jellyfin *JellyfinMovie        `json:"jellyfin,omitempty"`
+	Radarr   *RadarrMovie          `json:"radarr,omitempty"`
+	Sonarr   *SonarrSeries         `json:"sonarr,omitempty"`
+	Download *AvailabilityDownload `json:"download,omitempty"`
+	Request  *AvailabilityRequest  `json:"request,omitempty"`
+
+	// Errors lists the services that could not be checked
+	Errors []string `json:"errors,omitempty"`
+}
+
+// AvailabilityDownload sums up the queue items of the title
+type AvailabilityDownload struct {
+	Items    int     `json:"items"`
+	Status   string  `json:"status"`
+	Size     int64   `json:"size"`
+	Sizeleft int64   `json:"sizeleft"`
+	Progress float64 `json:"progress"` // Percent, 0-100
+	Timeleft string  `json:"timeleft,omitempty"`
+}
+
+// AvailabilityRequest is the most recent open request for the title
+type AvailabilityRequest struct {
+	ID                  primitive.ObjectID `json:"id"`
+	Status              string             `json:"status"`
+	RequestedByUsername string             `json:"requestedByUsername"`
+	Mine                bool               `json:"mine"`
+	CreatedAt           time.Time          `json:"createdAt"`
+}
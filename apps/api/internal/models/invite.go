@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Invite states, derived from the stored invite
+const (
+	InviteStatusActive    = "active"
+	InviteStatusExpired   = "expired"
+	InviteStatusExhausted = "exhausted"
+	InviteStatusRevoked   = "revoked"
+)
+
+// Invite is a code that lets someone register an account
+type Invite struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Code        string             `bson:"code" json:"code"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	// Role is given to registered users; empty means the default role
+	Role  string        `bson:"role,omitempty" json:"role,omitempty"`
+	Quota *RequestQuota `bson:"quota,omitempty" json:"quota,omitempty"`
+	// MaxUses is the number of accounts the code can create; 0 means unlimited
+	MaxUses   int        `bson:"maxUses" json:"maxUses"`
+	Uses      int        `bson:"uses" json:"uses"`
+	ExpiresAt *time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+
+	CreatedBy   string             `bson:"createdBy" json:"createdBy"`
+	CreatedByID primitive.ObjectID `bson:"createdById" json:"createdById"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+
+	Revoked   bool       `bson:"revoked" json:"revoked"`
+	RevokedBy string     `bson:"revokedBy,omitempty" json:"revokedBy,omitempty"`
+	RevokedAt *time.Time `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+
+	Redemptions []InviteRedemption `bson:"redemptions,omitempty" json:"redemptions"`
+	Status      string             `bson:"-" json:"status"`
+}
+
+// InviteRedemption records an account created with an invite
+type InviteRedemption struct {
+	UserID     primitive.ObjectID `bson:"userId" json:"userId"`
+	Username   string             `bson:"username" json:"username"`
+	IP         string             `bson:"ip" json:"ip"`
+	RedeemedAt time.Time          `bson:"redeemedAt" json:"redeemedAt"`
+}
+
+// CreateInviteRequest for admins generating an invite
+type CreateInviteRequest struct {
+	Description    string        `json:"description"`
+	Role           string        `json:"role,omitempty"`
+	Quota          *RequestQuota `json:"quota,omitempty"`
+	MaxUses        int           `json:"maxUses"`        // 0 means unlimited
+	ExpiresInHours int           `json:"expiresInHours"` // 0 means the invite never expires
+}
+
+// CurrentStatus reports whether the invite can still be redeemed
+func (i *Invite) CurrentStatus(now time.Time) string {
+	switch {
+	case i.Revoked:
+		return InviteStatusRevoked
+	case i.ExpiresAt != nil && !now.Before(*i.ExpiresAt):
+		return InviteStatusExpired
+	case i.MaxUses > 0 && i.Uses >= i.MaxUses:
+		return InviteStatusExhausted
+	}
+	return InviteStatusActive
+}
@@ -16,6 +16,7 @@ type JellyfinMovie struct {
 	ImageTags               map[string]string `json:"ImageTags"`
 	BackdropImageTags       []string          `json:"BackdropImageTags"`
 	ProviderIds             map[string]string `json:"ProviderIds"`
+	Genres                  []string          `json:"Genres,omitempty"`
 }
 
 // JellyfinResponse represents the response from Jellyfin API for movies
@@ -23,6 +24,11 @@ type JellyfinResponse struct {
 	Items            []JellyfinMovie `json:"Items"`
 	TotalRecordCount int             `json:"TotalRecordCount"`
 	StartIndex       int             `json:"StartIndex"`
+
+	// Paging of the library endpoints; Jellyfin does not send these
+	Page       int    `json:"Page,omitempty"`
+	Limit      int    `json:"Limit,omitempty"`
+	NextCursor string `json:"NextCursor,omitempty"`
 }
 
 // JellyfinSeries represents a TV series from Jellyfin
@@ -42,6 +48,7 @@ type JellyfinSeries struct {
 	ProviderIds             map[string]string `json:"ProviderIds"`
 	Type                    string            `json:"Type"`
 	IsFolder                bool              `json:"IsFolder"`
+	Genres                  []string          `json:"Genres,omitempty"`
 }
 
 // JellyfinSeriesResponse represents the response from Jellyfin API for series
@@ -49,4 +56,101 @@ type JellyfinSeriesResponse struct {
 	Items            []JellyfinSeries `json:"Items"`
 	TotalRecordCount int              `json:"TotalRecordCount"`
 	StartIndex       int              `json:"StartIndex"`
+
+	// Paging of the library endpoints; Jellyfin does not send these
+	Page       int    `json:"Page,omitempty"`
+	Limit      int    `json:"Limit,omitempty"`
+	NextCursor string `json:"NextCursor,omitempty"`
+}
+
+// JellyfinUserData is a Jellyfin user's playback state for an item
+type JellyfinUserData struct {
+	PlaybackPositionTicks int64   `json:"PlaybackPositionTicks"`
+	PlayCount             int     `json:"PlayCount"`
+	IsFavorite            bool    `json:"IsFavorite"`
+	Played                bool    `json:"Played"`
+	PlayedPercentage      float64 `json:"PlayedPercentage,omitempty"`
+	UnplayedItemCount     int     `json:"UnplayedItemCount,omitempty"`
+	LastPlayedDate        string  `json:"LastPlayedDate,omitempty"`
+}
+
+// JellyfinSeason represents a season of a Jellyfin series
+type JellyfinSeason struct {
+	Name              string            `json:"Name"`
+	Id                string            `json:"Id"`
+	SeriesId          string            `json:"SeriesId"`
+	SeriesName        string            `json:"SeriesName"`
+	IndexNumber       int               `json:"IndexNumber"`
+	Overview          string            `json:"Overview,omitempty"`
+	PremiereDate      string            `json:"PremiereDate,omitempty"`
+	ProductionYear    int               `json:"ProductionYear,omitempty"`
+	ChildCount        int               `json:"ChildCount,omitempty"`
+	ImageTags         map[string]string `json:"ImageTags"`
+	BackdropImageTags []string          `json:"BackdropImageTags"`
+	UserData          *JellyfinUserData `json:"UserData,omitempty"`
+}
+
+// JellyfinSeasonsResponse represents the response of /Shows/{id}/Seasons
+type JellyfinSeasonsResponse struct {
+	Items            []JellyfinSeason `json:"Items"`
+	TotalRecordCount int              `json:"TotalRecordCount"`
+}
+
+// JellyfinEpisode represents an episode of a Jellyfin series
+type JellyfinEpisode struct {
+	Name              string            `json:"Name"`
+	Id                string            `json:"Id"`
+	SeriesId          string            `json:"SeriesId"`
+	SeriesName        string            `json:"SeriesName"`
+	SeasonId          string            `json:"SeasonId"`
+	SeasonName        string            `json:"SeasonName"`
+	IndexNumber       int               `json:"IndexNumber"`
+	IndexNumberEnd    int               `json:"IndexNumberEnd,omitempty"` // Last episode of a multi-episode file
+	ParentIndexNumber int               `json:"ParentIndexNumber"`        // Season number
+	Overview          string            `json:"Overview,omitempty"`
+	PremiereDate      string            `json:"PremiereDate,omitempty"`
+	CommunityRating   float64           `json:"CommunityRating,omitempty"`
+	RunTimeTicks      int64             `json:"RunTimeTicks"`
+	Container         string            `json:"Container,omitempty"`
+	HasSubtitles      bool              `json:"HasSubtitles"`
+	LocationType      string            `json:"LocationType,omitempty"` // "Virtual" for missing episodes
+	ImageTags         map[string]string `json:"ImageTags"`
+	UserData          *JellyfinUserData `json:"UserData,omitempty"`
+}
+
+// JellyfinEpisodesResponse represents the response of /Shows/{id}/Episodes
+type JellyfinEpisodesResponse struct {
+	Items            []JellyfinEpisode `json:"Items"`
+	TotalRecordCount int               `json:"TotalRecordCount"`
+}
+
+// JellyfinUserPolicy holds the permission flags of a Jellyfin user
+type JellyfinUserPolicy struct {
+	IsAdministrator bool `json:"IsAdministrator"`
+	IsDisabled      bool `json:"IsDisabled"`
+}
+
+// JellyfinUser represents a Jellyfin user account
+type JellyfinUser struct {
+	Name     string             `json:"Name"`
+	Id       string             `json:"Id"`
+	ServerId string             `json:"ServerId"`
+	Policy   JellyfinUserPolicy `json:"Policy"`
+}
+
+// JellyfinAuthenticationResult represents the response of /Users/AuthenticateByName
+type JellyfinAuthenticationResult struct {
+	User        JellyfinUser `json:"User"`
+	AccessToken string       `json:"AccessToken"`
+	ServerId    string       `json:"ServerId"`
+}
+
+// JellyfinUserMapping shows which account, if any, is linked to a Jellyfin user
+type JellyfinUserMapping struct {
+	JellyfinUserID  string `json:"jellyfinUserId"`
+	JellyfinName    string `json:"jellyfinName"`
+	IsAdministrator bool   `json:"isAdministrator"`
+	IsDisabled      bool   `json:"isDisabled"`
+	LinkedUserID    string `json:"linkedUserId,omitempty"`
+	LinkedUsername  string `json:"linkedUsername,omitempty"`
 }
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// JellyfinWebhookPayload is the body the Jellyfin Webhook plugin posts with
+// "Send All Properties" enabled. Which fields are set depends on
+// NotificationType.
+type JellyfinWebhookPayload struct {
+	NotificationType string `json:"NotificationType"` // ItemAdded, PlaybackStart, PlaybackStop, UserDataSaved, ...
+	ServerId         string `json:"ServerId,omitempty"`
+	ServerName       string `json:"ServerName,omitempty"`
+	UtcTimestamp     string `json:"UtcTimestamp,omitempty"`
+
+	ItemId        string `json:"ItemId"`
+	ItemType      string `json:"ItemType"` // Movie, Series, Season, Episode, ...
+	Name          string `json:"Name"`
+	Year          int    `json:"Year,omitempty"`
+	RunTimeTicks  int64  `json:"RunTimeTicks,omitempty"`
+	SeriesId      string `json:"SeriesId,omitempty"`
+	SeriesName    string `json:"SeriesName,omitempty"`
+	SeasonNumber  int    `json:"SeasonNumber,omitempty"`
+	EpisodeNumber int    `json:"EpisodeNumber,omitempty"`
+	ProviderTmdb  string `json:"Provider_tmdb,omitempty"`
+	ProviderTvdb  string `json:"Provider_tvdb,omitempty"`
+	ProviderImdb  string `json:"Provider_imdb,omitempty"`
+
+	// Playback and user data events
+	UserId                string `json:"UserId,omitempty"`
+	NotificationUsername  string `json:"NotificationUsername,omitempty"`
+	PlaybackPositionTicks int64  `json:"PlaybackPositionTicks,omitempty"`
+	IsPaused              bool   `json:"IsPaused,omitempty"`
+	PlayedToCompletion    bool   `json:"PlayedToCompletion,omitempty"`
+	DeviceName            string `json:"DeviceName,omitempty"`
+	ClientName            string `json:"ClientName,omitempty"`
+	SaveReason            string `json:"SaveReason,omitempty"` // UserDataSaved: PlaybackFinished, TogglePlayed, UpdateUserRating, ...
+	Played                bool   `json:"Played,omitempty"`
+	IsFavorite            bool   `json:"Favorite,omitempty"`
+}
+
+// JellyfinEvent is a Jellyfin webhook normalised for internal subscribers.
+// IDs use Jellyfin's compact form (no dashes) and ProviderIds uses the same
+// keys as JellyfinMovie.ProviderIds.
+type JellyfinEvent struct {
+	Type          string            `json:"type"`
+	ItemID        string            `json:"itemId"`
+	ItemType      string            `json:"itemType"`
+	Name          string            `json:"name"`
+	Year          int               `json:"year,omitempty"`
+	SeriesID      string            `json:"seriesId,omitempty"`
+	SeriesName    string            `json:"seriesName,omitempty"`
+	SeasonNumber  int               `json:"seasonNumber,omitempty"`
+	EpisodeNumber int               `json:"episodeNumber,omitempty"`
+	ProviderIds   map[string]string `json:"providerIds,omitempty"`
+	RunTimeTicks  int64             `json:"runTimeTicks,omitempty"`
+
+	// JellyfinUserID is the Jellyfin user behind playback and user data
+	// events; UserID is the linked account, when there is one
+	JellyfinUserID string `json:"jellyfinUserId,omitempty"`
+	JellyfinUser   string `json:"jellyfinUser,omitempty"`
+	UserID         string `json:"userId,omitempty"`
+
+	PositionTicks      int64  `json:"positionTicks,omitempty"`
+	IsPaused           bool   `json:"isPaused,omitempty"`
+	PlayedToCompletion bool   `json:"playedToCompletion,omitempty"`
+	DeviceName         string `json:"deviceName,omitempty"`
+	ClientName         string `json:"clientName,omitempty"`
+	SaveReason         string `json:"saveReason,omitempty"`
+	Played             bool   `json:"played,omitempty"`
+	IsFavorite         bool   `json:"isFavorite,omitempty"`
+
+	Time time.Time `json:"time"`
+}
+
+// CompactJellyfinID normalises a Jellyfin GUID to the dash-less lowercase
+// form the Jellyfin API returns
+func CompactJellyfinID(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
+}
+
+// Normalize converts the plugin payload into a JellyfinEvent of the given type
+func (p *JellyfinWebhookPayload) Normalize(eventType string) *JellyfinEvent {
+	event := &JellyfinEvent{
+		Type:               eventType,
+		ItemID:             CompactJellyfinID(p.ItemId),
+		ItemType:           p.ItemType,
+		Name:               p.Name,
+		Year:               p.Year,
+		SeriesID:           CompactJellyfinID(p.SeriesId),
+		SeriesName:         p.SeriesName,
+		SeasonNumber:       p.SeasonNumber,
+		EpisodeNumber:      p.EpisodeNumber,
+		RunTimeTicks:       p.RunTimeTicks,
+		JellyfinUserID:     CompactJellyfinID(p.UserId),
+		JellyfinUser:       p.NotificationUsername,
+		PositionTicks:      p.PlaybackPositionTicks,
+		IsPaused:           p.IsPaused,
+		PlayedToCompletion: p.PlayedToCompletion,
+		DeviceName:         p.DeviceName,
+		ClientName:         p.ClientName,
+		SaveReason:         p.SaveReason,
+		Played:             p.Played,
+		IsFavorite:         p.IsFavorite,
+		Time:               time.Now(),
+	}
+
+	for key, value := range map[string]string{"Tmdb": p.ProviderTmdb, "Tvdb": p.ProviderTvdb, "Imdb": p.ProviderImdb} {
+		if value != "" {
+			if event.ProviderIds == nil {
+				event.ProviderIds = make(map[string]string)
+			}
+			event.ProviderIds[key] = value
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, p.UtcTimestamp); err == nil {
+		event.Time = t
+	}
+	return event
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Jellyfin collection types of the libraries the app can show
+const (
+	CollectionTypeMovies  = "movies"
+	CollectionTypeTVShows = "tvshows"
+	CollectionTypeMixed   = "mixed" // Jellyfin also reports mixed libraries with no type
+)
+
+// JellyfinVirtualFolder is a library as configured on the Jellyfin server
+type JellyfinVirtualFolder struct {
+	Name           string   `json:"Name"`
+	ItemId         string   `json:"ItemId"`
+	CollectionType string   `json:"CollectionType"`
+	Locations      []string `json:"Locations"`
+}
+
+// JellyfinUserView is a library as seen by a Jellyfin user
+type JellyfinUserView struct {
+	Name           string `json:"Name"`
+	Id             string `json:"Id"`
+	CollectionType string `json:"CollectionType"`
+}
+
+// JellyfinUserViewsResponse represents the response of /Users/{id}/Views
+type JellyfinUserViewsResponse struct {
+	Items []JellyfinUserView `json:"Items"`
+}
+
+// Library is a Jellyfin library and whether the app shows it
+type Library struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	CollectionType string   `json:"collectionType"`
+	Locations      []string `json:"locations,omitempty"`
+	// Whether the movie and TV pages show the library
+	Movies bool `json:"movies"`
+	TV     bool `json:"tv"`
+}
+
+// LibrarySettingsID is the _id of the library settings document
+const LibrarySettingsID = "libraries"
+
+// LibrarySettings are the Jellyfin libraries the movie and TV pages show.
+// An empty list falls back to JELLYFIN_PARENT_ID or
+// JELLYFIN_TVSHOWS_PARENT_ID, or to every library when those are unset.
+type LibrarySettings struct {
+	ID             string    `bson:"_id" json:"-"`
+	MovieLibraries []string  `bson:"movieLibraries" json:"movieLibraries"`
+	TVLibraries    []string  `bson:"tvLibraries" json:"tvLibraries"`
+	UpdatedAt      time.Time `bson:"updatedAt" json:"updatedAt,omitempty"`
+	UpdatedBy      string    `bson:"updatedBy,omitempty" json:"updatedBy,omitempty"`
+}
+
+// LibrariesResponse lists the Jellyfin libraries with the current settings
+type LibrariesResponse struct {
+	Libraries      []Library `json:"libraries"`
+	MovieLibraries []string  `json:"movieLibraries"`
+	TVLibraries    []string  `json:"tvLibraries"`
+}
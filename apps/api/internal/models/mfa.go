@@ -0,0 +1,37 @@
+package models
+
+// MFASetupResponse contains a new TOTP secret awaiting confirmation
+type MFASetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+// MFACodeRequest carries a TOTP code or a recovery code
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// MFADisableRequest requires the password, for accounts that have one, and a
+// second factor
+type MFADisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// MFARecoveryCodesResponse lists freshly generated recovery codes (shown once)
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// MFAChallengeResponse is returned by login when a second factor is required
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+	ExpiresIn   int    `json:"expiresIn"` // Challenge lifetime in seconds
+}
+
+// MFAVerifyRequest completes a login challenge with a TOTP or recovery code
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
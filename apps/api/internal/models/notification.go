@@ -0,0 +1,108 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification events users can subscribe to
+const (
+	NotificationRequestApproved   = "request.approved"
+	NotificationRequestDeclined   = "request.declined"
+	NotificationDownloadCompleted = "download.completed"
+	NotificationMediaAvailable    = "media.available"
+	NotificationTest              = "test"         // Sent by the test endpoint to every selected channel
+	NotificationAnnouncement      = "announcement" // Broadcast by an admin; inbox only
+)
+
+// NotificationEvents lists the events a channel can subscribe to
+var NotificationEvents = []string{
+	NotificationRequestApproved,
+	NotificationRequestDeclined,
+	NotificationDownloadCompleted,
+	NotificationMediaAvailable,
+}
+
+// Notification channel types
+const (
+	ChannelInbox   = "inbox"   // Stored in Mongo and shown in the app
+	ChannelEmail   = "email"   // Sent through the configured SMTP server
+	ChannelWebhook = "webhook" // Generic JSON POST
+	ChannelDiscord = "discord" // Discord-compatible webhook
+	ChannelNtfy    = "ntfy"    // ntfy topic URL
+	ChannelGotify  = "gotify"  // Gotify server URL and application token
+)
+
+// NotificationChannel is one of a user's notification destinations
+type NotificationChannel struct {
+	Type    string `bson:"type" json:"type"`
+	Enabled bool   `bson:"enabled" json:"enabled"`
+	// Events the channel receives; empty subscribes to every event
+	Events []string `bson:"events,omitempty" json:"events,omitempty"`
+
+	Email    string `bson:"email,omitempty" json:"email,omitempty"` // email: defaults to the account email
+	URL      string `bson:"url,omitempty" json:"url,omitempty"`     // webhook, discord, ntfy topic or gotify server
+	Token    string `bson:"token,omitempty" json:"token,omitempty"` // ntfy access token or gotify application token
+	Priority int    `bson:"priority,omitempty" json:"priority,omitempty"`
+}
+
+// Wants reports whether the channel is enabled and subscribed to event
+func (c *NotificationChannel) Wants(event string) bool {
+	if !c.Enabled {
+		return false
+	}
+	if len(c.Events) == 0 || event == NotificationTest {
+		return true
+	}
+	for _, e := range c.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultNotificationChannels apply to users who never saved preferences
+var DefaultNotificationChannels = []NotificationChannel{{Type: ChannelInbox, Enabled: true}}
+
+// Notification is an item in a user's in-app inbox
+type Notification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Event     string             `bson:"event" json:"event"`
+	Title     string             `bson:"title" json:"title"`
+	Body      string             `bson:"body" json:"body"`
+	Link      string             `bson:"link,omitempty" json:"link,omitempty"`
+	Read      bool               `bson:"read" json:"read"`
+	ReadAt    *time.Time         `bson:"readAt,omitempty" json:"readAt,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// NotificationPreferences is the body of the notification settings endpoints
+type NotificationPreferences struct {
+	Channels []NotificationChannel `json:"channels"`
+}
+
+// TestNotificationResult reports the outcome of a test for one channel
+type TestNotificationResult struct {
+	Type  string `json:"type"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// NotificationsResponse is a page of the user's inbox
+type NotificationsResponse struct {
+	Items  []Notification `json:"items"`
+	Total  int64          `json:"total"`
+	Unread int64          `json:"unread"`
+}
+
+// BroadcastRequest is an announcement sent to every user, or to the users
+// holding Role
+type BroadcastRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Link  string `json:"link,omitempty"`
+	Role  string `json:"role,omitempty"`
+}
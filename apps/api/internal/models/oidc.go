@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OIDCState tracks an in-flight single sign-on login between redirect and callback
+type OIDCState struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	State        string             `bson:"state"`
+	Nonce        string             `bson:"nonce"`
+	CodeVerifier string             `bson:"codeVerifier"`
+	CreatedAt    time.Time          `bson:"createdAt"`
+	ExpiresAt    time.Time          `bson:"expiresAt"`
+}
+
+// OIDCConfigResponse tells the login page whether single sign-on is available
+type OIDCConfigResponse struct {
+	Enabled  bool   `json:"enabled"`
+	LoginURL string `json:"loginUrl,omitempty"`
+}
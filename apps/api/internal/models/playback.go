@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PlaybackReport is sent by the player when playback of a Jellyfin item
+// starts, progresses or stops
+type PlaybackReport struct {
+	ItemID        string `json:"itemId"`
+	MediaSourceID string `json:"mediaSourceId,omitempty"`
+	PlaySessionID string `json:"playSessionId,omitempty"`
+	PositionTicks int64  `json:"positionTicks"`
+	IsPaused      bool   `json:"isPaused,omitempty"`
+	PlayMethod    string `json:"playMethod,omitempty"` // DirectPlay, DirectStream or Transcode
+}
+
+// PlaybackState is where a user stands in an item, returned when playback
+// starts so the player can resume
+type PlaybackState struct {
+	ItemID        string `json:"itemId"`
+	PositionTicks int64  `json:"positionTicks"`
+	Played        bool   `json:"played"`
+}
+
+// PlaybackProgress is the stored playback position of a user without a
+// linked Jellyfin account
+type PlaybackProgress struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID        primitive.ObjectID `bson:"userId" json:"-"`
+	ItemID        string             `bson:"itemId" json:"itemId"`
+	ItemType      string             `bson:"itemType" json:"itemType"` // Movie or Episode
+	SeriesID      string             `bson:"seriesId,omitempty" json:"seriesId,omitempty"`
+	PositionTicks int64              `bson:"positionTicks" json:"positionTicks"`
+	RunTimeTicks  int64              `bson:"runTimeTicks" json:"runTimeTicks"`
+	Played        bool               `bson:"played" json:"played"`
+	UpdatedAt     time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// WatchItem is a movie or episode in the continue watching and next up feeds
+type WatchItem struct {
+	Name                 string            `json:"Name"`
+	Id                   string            `json:"Id"`
+	Type                 string            `json:"Type"` // Movie or Episode
+	ProductionYear       int               `json:"ProductionYear,omitempty"`
+	SeriesId             string            `json:"SeriesId,omitempty"`
+	SeriesName           string            `json:"SeriesName,omitempty"`
+	SeasonId             string            `json:"SeasonId,omitempty"`
+	IndexNumber          int               `json:"IndexNumber,omitempty"`
+	ParentIndexNumber    int               `json:"ParentIndexNumber,omitempty"`
+	Overview             string            `json:"Overview,omitempty"`
+	RunTimeTicks         int64             `json:"RunTimeTicks"`
+	ImageTags            map[string]string `json:"ImageTags"`
+	BackdropImageTags    []string          `json:"BackdropImageTags,omitempty"`
+	ParentBackdropItemId string            `json:"ParentBackdropItemId,omitempty"`
+	UserData             *JellyfinUserData `json:"UserData,omitempty"`
+}
+
+// WatchFeedResponse is the response of the continue watching and next up
+// feeds
+type WatchFeedResponse struct {
+	Items []WatchItem `json:"Items"`
+}
@@ -43,6 +43,7 @@ type RadarrMovie struct {
 	Id          int    `json:"id"`
 	Title       string `json:"title"`
 	TmdbId      int    `json:"tmdbId"`
+	ImdbId      string `json:"imdbId,omitempty"`
 	Monitored   bool   `json:"monitored"`
 	HasFile     bool   `json:"hasFile"`
 	IsAvailable bool   `json:"isAvailable"`
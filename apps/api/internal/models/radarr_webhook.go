@@ -0,0 +1,117 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// RadarrWebhookPayload is the body Radarr posts to a Connect > Webhook
+// notification. Which fields are set depends on EventType.
+type RadarrWebhookPayload struct {
+	EventType      string `json:"eventType"` // Grab, Download, Rename, MovieDelete, MovieFileDelete, Health, Test, ...
+	InstanceName   string `json:"instanceName,omitempty"`
+	ApplicationUrl string `json:"applicationUrl,omitempty"`
+
+	Movie       *RadarrWebhookMovie       `json:"movie,omitempty"`
+	RemoteMovie *RadarrWebhookRemoteMovie `json:"remoteMovie,omitempty"`
+	Release     *ArrWebhookRelease        `json:"release,omitempty"`
+	MovieFile   *RadarrWebhookMovieFile   `json:"movieFile,omitempty"`
+
+	// Download events; IsUpgrade marks a replaced file
+	IsUpgrade          bool                     `json:"isUpgrade,omitempty"`
+	DownloadClient     string                   `json:"downloadClient,omitempty"`
+	DownloadClientType string                   `json:"downloadClientType,omitempty"`
+	DownloadId         string                   `json:"downloadId,omitempty"`
+	DeletedFiles       []RadarrWebhookMovieFile `json:"deletedFiles,omitempty"` // Files replaced by an upgrade
+
+	// Rename events
+	RenamedMovieFiles []RadarrWebhookMovieFile `json:"renamedMovieFiles,omitempty"`
+
+	// MovieDelete events; FilesDeleted reports whether files left the disk
+	FilesDeleted    bool  `json:"filesDeleted,omitempty"`
+	MovieFolderSize int64 `json:"movieFolderSize,omitempty"`
+
+	ArrWebhookHealth
+}
+
+// UnmarshalJSON decodes "deletedFiles", which is a list of replaced files on
+// Download events but a boolean on MovieDelete events
+func (p *RadarrWebhookPayload) UnmarshalJSON(data []byte) error {
+	type payload RadarrWebhookPayload
+	aux := struct {
+		*payload
+		DeletedFiles json.RawMessage `json:"deletedFiles"`
+	}{payload: (*payload)(p)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	var err error
+	p.FilesDeleted, err = decodeDeletedFiles(aux.DeletedFiles, &p.DeletedFiles)
+	return err
+}
+
+// decodeDeletedFiles decodes an arr "deletedFiles" field into files when it
+// is a list, or returns its value when it is a boolean
+func decodeDeletedFiles(raw json.RawMessage, files interface{}) (bool, error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+		return false, nil
+	case raw[0] == '[':
+		return false, json.Unmarshal(raw, files)
+	default:
+		var deleted bool
+		return deleted, json.Unmarshal(raw, &deleted)
+	}
+}
+
+// RadarrWebhookMovie is the movie a webhook event is about
+type RadarrWebhookMovie struct {
+	Id          int    `json:"id"`
+	Title       string `json:"title"`
+	Year        int    `json:"year,omitempty"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+	FolderPath  string `json:"folderPath,omitempty"`
+	TmdbId      int    `json:"tmdbId"`
+	ImdbId      string `json:"imdbId,omitempty"`
+	Overview    string `json:"overview,omitempty"`
+}
+
+// RadarrWebhookRemoteMovie is the movie as parsed from a grabbed release
+type RadarrWebhookRemoteMovie struct {
+	TmdbId int    `json:"tmdbId"`
+	ImdbId string `json:"imdbId,omitempty"`
+	Title  string `json:"title"`
+	Year   int    `json:"year,omitempty"`
+}
+
+// RadarrWebhookMovieFile is an imported, renamed or deleted movie file
+type RadarrWebhookMovieFile struct {
+	Id             int    `json:"id"`
+	RelativePath   string `json:"relativePath"`
+	Path           string `json:"path"`
+	PreviousPath   string `json:"previousPath,omitempty"` // Rename events
+	Quality        string `json:"quality,omitempty"`
+	QualityVersion int    `json:"qualityVersion,omitempty"`
+	ReleaseGroup   string `json:"releaseGroup,omitempty"`
+	SceneName      string `json:"sceneName,omitempty"`
+	Size           int64  `json:"size,omitempty"`
+}
+
+// ArrWebhookRelease describes a release grabbed by Radarr or Sonarr
+type ArrWebhookRelease struct {
+	Quality        string `json:"quality,omitempty"`
+	QualityVersion int    `json:"qualityVersion,omitempty"`
+	ReleaseGroup   string `json:"releaseGroup,omitempty"`
+	ReleaseTitle   string `json:"releaseTitle,omitempty"`
+	Indexer        string `json:"indexer,omitempty"`
+	Size           int64  `json:"size,omitempty"`
+}
+
+// ArrWebhookHealth carries the fields of Health and HealthRestored events
+type ArrWebhookHealth struct {
+	Level      string `json:"level,omitempty"` // ok, notice, warning or error
+	Message    string `json:"message,omitempty"`
+	HealthType string `json:"type,omitempty"`
+	WikiUrl    string `json:"wikiUrl,omitempty"`
+}
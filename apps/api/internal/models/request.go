@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Media types that can be requested
+const (
+	MediaTypeMovie = "movie"
+	MediaTypeTV    = "tv"
+)
+
+// Media request states
+const (
+	RequestStatusPending  = "pending"
+	RequestStatusApproved = "approved"
+	RequestStatusDeclined = "declined"
+	RequestStatusFailed   = "failed" // Approved, but Radarr/Sonarr rejected the add
+)
+
+// MediaRequest is a user's request for a movie or TV show, added to Radarr or
+// Sonarr once approved
+type MediaRequest struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	MediaType  string             `bson:"mediaType" json:"mediaType"`
+	TMDBID     int                `bson:"tmdbId,omitempty" json:"tmdbId,omitempty"`
+	TVDBID     int                `bson:"tvdbId,omitempty" json:"tvdbId,omitempty"`
+	Title      string             `bson:"title" json:"title"`
+	Year       int                `bson:"year,omitempty" json:"year,omitempty"`
+	PosterPath string             `bson:"posterPath,omitempty" json:"posterPath,omitempty"`
+	// Seasons requested for a TV show; empty means every season.
+	// SeasonCount is what the request counts against the season quota.
+	Seasons     []int  `bson:"seasons,omitempty" json:"seasons,omitempty"`
+	SeasonCount int    `bson:"seasonCount,omitempty" json:"seasonCount,omitempty"`
+	Status      string `bson:"status" json:"status"`
+
+	RequestedBy         primitive.ObjectID `bson:"requestedBy" json:"requestedBy"`
+	RequestedByUsername string             `bson:"requestedByUsername" json:"requestedByUsername"`
+	CreatedAt           time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt           time.Time          `bson:"updatedAt" json:"updatedAt"`
+
+	ReviewedBy    string     `bson:"reviewedBy,omitempty" json:"reviewedBy,omitempty"`
+	ReviewedAt    *time.Time `bson:"reviewedAt,omitempty" json:"reviewedAt,omitempty"`
+	DeclineReason string     `bson:"declineReason,omitempty" json:"declineReason,omitempty"`
+
+	// Set when the request is approved
+	QualityProfileID int    `bson:"qualityProfileId,omitempty" json:"qualityProfileId,omitempty"`
+	RootFolderPath   string `bson:"rootFolderPath,omitempty" json:"rootFolderPath,omitempty"`
+	Error            string `bson:"error,omitempty" json:"error,omitempty"`
+
+	// Set when Jellyfin reports the approved title in the library
+	AvailableAt    *time.Time `bson:"availableAt,omitempty" json:"availableAt,omitempty"`
+	JellyfinItemID string     `bson:"jellyfinItemId,omitempty" json:"jellyfinItemId,omitempty"`
+}
+
+// CreateMediaRequest is the body of a new media request
+type CreateMediaRequest struct {
+	MediaType  string `json:"mediaType"`
+	TMDBID     int    `json:"tmdbId"`
+	TVDBID     int    `json:"tvdbId,omitempty"`
+	Title      string `json:"title"`
+	Year       int    `json:"year,omitempty"`
+	PosterPath string `json:"posterPath,omitempty"`
+	Seasons    []int  `json:"seasons,omitempty"`
+}
+
+// ApproveMediaRequest optionally overrides where and how the media is added
+type ApproveMediaRequest struct {
+	QualityProfileID int    `json:"qualityProfileId,omitempty"`
+	RootFolderPath   string `json:"rootFolderPath,omitempty"`
+}
+
+// DeclineMediaRequest explains why a request was declined
+type DeclineMediaRequest struct {
+	Reason string `json:"reason"`
+}
+
+// MediaRequestsResponse is a page of media requests
+type MediaRequestsResponse struct {
+	Items []MediaRequest `json:"items"`
+	Total int64          `json:"total"`
+}
+
+// QuotaUsage reports one quota counter over the rolling window. ResetsAt is
+// when the oldest counted request leaves the window and frees a slot.
+type QuotaUsage struct {
+	Unlimited bool       `json:"unlimited"`
+	Limit     int        `json:"limit,omitempty"`
+	Used      int        `json:"used"`
+	Remaining int        `json:"remaining,omitempty"`
+	ResetsAt  *time.Time `json:"resetsAt,omitempty"`
+}
+
+// QuotaStatus is a user's request quota and current usage; admins are exempt
+type QuotaStatus struct {
+	Days    int        `json:"days"`
+	Exempt  bool       `json:"exempt"`
+	Custom  bool       `json:"custom"` // Set on the user rather than the default
+	Movies  QuotaUsage `json:"movies"`
+	Seasons QuotaUsage `json:"seasons"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Permissions that can be granted through roles; administrators hold all of
+// them. Each is described in Permissions.
+const (
+	PermissionRequestMovie  = "request.movie"
+	PermissionRequestTV     = "request.tv"
+	PermissionRequest4K     = "request.4k"
+	PermissionDownloadsView = "downloads.view"
+	PermissionUsersManage   = "users.manage"
+	PermissionArrRefresh    = "arr.refresh"
+	PermissionAuditView     = "audit.view"
+
+	PermissionRequestAutoApprove = "request.autoapprove"
+	PermissionRequestsManage     = "requests.manage"
+
+	PermissionNotificationsWebhooks  = "notifications.webhooks"
+	PermissionNotificationsBroadcast = "notifications.broadcast"
+
+	PermissionLibrariesManage = "libraries.manage"
+)
+
+// DefaultRoleName is the built-in role applied to users without any role
+const DefaultRoleName = "user"
+
+// PermissionInfo describes a permission for role editors
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions lists every known permission
+var Permissions = []PermissionInfo{
+	{Name: PermissionRequestMovie, Description: "Request movies"},
+	{Name: PermissionRequestTV, Description: "Request TV shows"},
+	{Name: PermissionRequest4K, Description: "Request with a 4K quality profile"},
+	{Name: PermissionDownloadsView, Description: "View the Radarr and Sonarr download queues"},
+	{Name: PermissionUsersManage, Description: "Manage users and roles"},
+	{Name: PermissionArrRefresh, Description: "Trigger Radarr and Sonarr refreshes"},
+	{Name: PermissionAuditView, Description: "View and export the audit log"},
+	{Name: PermissionRequestAutoApprove, Description: "Have media requests approved automatically"},
+	{Name: PermissionRequestsManage, Description: "See every media request, approve or decline them, and add directly to Radarr and Sonarr"},
+	{Name: PermissionNotificationsWebhooks, Description: "Send notifications to webhook, Discord, ntfy and Gotify URLs"},
+	{Name: PermissionNotificationsBroadcast, Description: "Send announcements to every user or to a role"},
+	{Name: PermissionLibrariesManage, Description: "Choose which Jellyfin libraries the movie and TV pages show"},
+}
+
+// AllPermissionNames returns the names of every known permission
+func AllPermissionNames() []string {
+	names := make([]string, len(Permissions))
+	for i, permission := range Permissions {
+		names[i] = permission.Name
+	}
+	return names
+}
+
+// IsValidPermission reports whether name is a known permission
+func IsValidPermission(name string) bool {
+	for _, permission := range Permissions {
+		if permission.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is a named set of permissions assigned to users
+type Role struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"` // Immutable; users reference roles by name
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Permissions []string           `bson:"permissions" json:"permissions"`
+	BuiltIn     bool               `bson:"builtIn" json:"builtIn"` // Built-in roles cannot be deleted
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// CreateRoleRequest for creating a custom role
+type CreateRoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest for updating a role
+type UpdateRoleRequest struct {
+	Description *string   `json:"description,omitempty"`
+	Permissions *[]string `json:"permissions,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reasons recorded for failed login attempts
+const (
+	FailedLoginInvalidCredentials = "invalid_credentials"
+	FailedLoginInvalidMFACode     = "invalid_mfa_code"
+	FailedLoginThrottled          = "throttled"
+	FailedLoginInvalidInvite      = "invalid_invite"
+)
+
+// LoginThrottle counts recent failed logins for a username ("user:<name>")
+// or client IP ("ip:<address>") and holds the resulting lockout
+type LoginThrottle struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Key            string             `bson:"key" json:"key"`
+	Failures       int                `bson:"failures" json:"failures"`
+	FirstFailureAt time.Time          `bson:"firstFailureAt" json:"firstFailureAt"`
+	LastFailureAt  time.Time          `bson:"lastFailureAt" json:"lastFailureAt"`
+	LockedUntil    *time.Time         `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	ExpiresAt      time.Time          `bson:"expiresAt" json:"expiresAt"` // Counter resets after a quiet period
+}
+
+// FailedLogin is an audit record of a rejected login attempt
+type FailedLogin struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Username  string             `bson:"username" json:"username"`
+	IP        string             `bson:"ip" json:"ip"`
+	UserAgent string             `bson:"userAgent" json:"userAgent"`
+	Reason    string             `bson:"reason" json:"reason"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"-"`
+}
+
+// FailedLoginsResponse is a page of failed login records
+type FailedLoginsResponse struct {
+	Items []FailedLogin `json:"items"`
+	Total int64         `json:"total"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session represents a server-side login session backing a refresh token
+type Session struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID            primitive.ObjectID `bson:"userId" json:"userId"`
+	RefreshTokenHash  string             `bson:"refreshTokenHash" json:"-"`
+	PreviousTokenHash string             `bson:"previousTokenHash,omitempty" json:"-"` // Used to detect refresh token reuse
+	UserAgent         string             `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	IP                string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	CreatedAt         time.Time          `bson:"createdAt" json:"createdAt"`
+	LastUsedAt        time.Time          `bson:"lastUsedAt" json:"lastUsedAt"`
+	ExpiresAt         time.Time          `bson:"expiresAt" json:"expiresAt"`
+	RevokedAt         *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// SessionResponse is used for API responses listing a user's sessions
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+// RefreshRequest carries a refresh token to exchange for a new token pair
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// ToResponse converts Session to SessionResponse
+func (s *Session) ToResponse(currentSessionID string) SessionResponse {
+	return SessionResponse{
+		ID:         s.ID.Hex(),
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: s.LastUsedAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.ID.Hex() == currentSessionID,
+	}
+}
@@ -13,12 +13,15 @@ type SonarrSeries struct {
 	Title     string              `json:"title"`
 	TvdbId    int                 `json:"tvdbId"`
 	TmdbId    int                 `json:"tmdbId,omitempty"`
+	ImdbId    string              `json:"imdbId,omitempty"`
 	Monitored bool                `json:"monitored"`
 	Status    string              `json:"status"`
 	Seasons   []SonarrSeason      `json:"seasons"`
 	Year      int                 `json:"year,omitempty"`
 	Images    []map[string]string `json:"images,omitempty"`
 	TitleSlug string              `json:"titleSlug,omitempty"`
+	// Statistics holds episode and file counts when Sonarr includes them
+	Statistics map[string]interface{} `json:"statistics,omitempty"`
 }
 
 // SonarrAddSeriesRequest represents a request to add a series to Sonarr
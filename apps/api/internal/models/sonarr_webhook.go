@@ -0,0 +1,85 @@
+package models
+
+import "encoding/json"
+
+// SonarrWebhookPayload is the body Sonarr posts to a Connect > Webhook
+// notification. Which fields are set depends on EventType.
+type SonarrWebhookPayload struct {
+	EventType      string `json:"eventType"` // Grab, Download, Rename, SeriesDelete, EpisodeFileDelete, Health, Test, ...
+	InstanceName   string `json:"instanceName,omitempty"`
+	ApplicationUrl string `json:"applicationUrl,omitempty"`
+
+	Series      *SonarrWebhookSeries      `json:"series,omitempty"`
+	Episodes    []SonarrWebhookEpisode    `json:"episodes,omitempty"`
+	EpisodeFile *SonarrWebhookEpisodeFile `json:"episodeFile,omitempty"`
+	Release     *ArrWebhookRelease        `json:"release,omitempty"`
+
+	// Download events; IsUpgrade marks a replaced file
+	IsUpgrade          bool                       `json:"isUpgrade,omitempty"`
+	DownloadClient     string                     `json:"downloadClient,omitempty"`
+	DownloadClientType string                     `json:"downloadClientType,omitempty"`
+	DownloadId         string                     `json:"downloadId,omitempty"`
+	DeletedFiles       []SonarrWebhookEpisodeFile `json:"deletedFiles,omitempty"` // Files replaced by an upgrade
+
+	// Rename events
+	RenamedEpisodeFiles []SonarrWebhookEpisodeFile `json:"renamedEpisodeFiles,omitempty"`
+
+	// SeriesDelete events; FilesDeleted reports whether files left the disk
+	FilesDeleted bool `json:"filesDeleted,omitempty"`
+
+	ArrWebhookHealth
+}
+
+// UnmarshalJSON decodes "deletedFiles", which is a list of replaced files on
+// Download events but a boolean on SeriesDelete events
+func (p *SonarrWebhookPayload) UnmarshalJSON(data []byte) error {
+	type payload SonarrWebhookPayload
+	aux := struct {
+		*payload
+		DeletedFiles json.RawMessage `json:"deletedFiles"`
+	}{payload: (*payload)(p)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	var err error
+	p.FilesDeleted, err = decodeDeletedFiles(aux.DeletedFiles, &p.DeletedFiles)
+	return err
+}
+
+// SonarrWebhookSeries is the series a webhook event is about
+type SonarrWebhookSeries struct {
+	Id        int    `json:"id"`
+	Title     string `json:"title"`
+	TitleSlug string `json:"titleSlug,omitempty"`
+	Path      string `json:"path,omitempty"`
+	TvdbId    int    `json:"tvdbId"`
+	TvMazeId  int    `json:"tvMazeId,omitempty"`
+	TmdbId    int    `json:"tmdbId,omitempty"`
+	ImdbId    string `json:"imdbId,omitempty"`
+	Type      string `json:"type,omitempty"` // standard, daily or anime
+	Year      int    `json:"year,omitempty"`
+}
+
+// SonarrWebhookEpisode is an episode covered by a grab, import or deletion
+type SonarrWebhookEpisode struct {
+	Id            int    `json:"id"`
+	EpisodeNumber int    `json:"episodeNumber"`
+	SeasonNumber  int    `json:"seasonNumber"`
+	Title         string `json:"title"`
+	AirDate       string `json:"airDate,omitempty"`
+	AirDateUtc    string `json:"airDateUtc,omitempty"`
+	Overview      string `json:"overview,omitempty"`
+}
+
+// SonarrWebhookEpisodeFile is an imported, renamed or deleted episode file
+type SonarrWebhookEpisodeFile struct {
+	Id             int    `json:"id"`
+	RelativePath   string `json:"relativePath"`
+	Path           string `json:"path"`
+	PreviousPath   string `json:"previousPath,omitempty"` // Rename events
+	Quality        string `json:"quality,omitempty"`
+	QualityVersion int    `json:"qualityVersion,omitempty"`
+	ReleaseGroup   string `json:"releaseGroup,omitempty"`
+	SceneName      string `json:"sceneName,omitempty"`
+	Size           int64  `json:"size,omitempty"`
+}
@@ -8,23 +8,82 @@ import (
 
 // User represents a user in the database
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Username  string             `bson:"username" json:"username"`
-	Email     string             `bson:"email,omitempty" json:"email,omitempty"`
-	Password  string             `bson:"password" json:"-"` // Never send password in JSON
-	IsAdmin   bool               `bson:"isAdmin" json:"isAdmin"`
-	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
-	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Username string             `bson:"username" json:"username"`
+	Email    string             `bson:"email,omitempty" json:"email,omitempty"`
+	Password string             `bson:"password" json:"-"` // Never send password in JSON
+	IsAdmin  bool               `bson:"isAdmin" json:"isAdmin"`
+	// MustChangePassword restricts the account to changing its password
+	MustChangePassword bool      `bson:"mustChangePassword,omitempty" json:"mustChangePassword"`
+	CreatedAt          time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt          time.Time `bson:"updatedAt" json:"updatedAt"`
+
+	// Two-factor authentication (TOTP)
+	MFAEnabled       bool     `bson:"mfaEnabled" json:"mfaEnabled"`
+	MFASecret        string   `bson:"mfaSecret,omitempty" json:"-"`
+	MFAPendingSecret string   `bson:"mfaPendingSecret,omitempty" json:"-"` // Awaiting confirmation with a code
+	MFALastCounter   int64    `bson:"mfaLastCounter,omitempty" json:"-"`   // Last accepted time step, prevents code replay
+	RecoveryCodes    []string `bson:"recoveryCodes,omitempty" json:"-"`    // Hashed single-use recovery codes
+
+	// External identity; empty AuthProvider means a local account
+	AuthProvider string `bson:"authProvider,omitempty" json:"authProvider,omitempty"`
+	OIDCIssuer   string `bson:"oidcIssuer,omitempty" json:"-"`
+	OIDCSubject  string `bson:"oidcSubject,omitempty" json:"-"`
+
+	// JellyfinUserID links the account to a Jellyfin user; JellyfinToken is
+	// the encrypted access token of the user's last Jellyfin login, used to
+	// report playback as that user
+	JellyfinUserID string `bson:"jellyfinUserId,omitempty" json:"jellyfinUserId,omitempty"`
+	JellyfinToken  string `bson:"jellyfinToken,omitempty" json:"-"`
+
+	// Roles are role names; users without roles get the default role
+	Roles []string `bson:"roles,omitempty" json:"roles,omitempty"`
+
+	// Quota overrides the default request quota; InviteID records the invite
+	// the account registered with
+	Quota    *RequestQuota       `bson:"quota,omitempty" json:"quota,omitempty"`
+	InviteID *primitive.ObjectID `bson:"inviteId,omitempty" json:"inviteId,omitempty"`
+
+	// Notifications are the user's notification channels; nil means
+	// DefaultNotificationChannels
+	Notifications []NotificationChannel `bson:"notifications,omitempty" json:"notifications,omitempty"`
+
+	// WatchlistAutoRequest requests titles added to the watchlist that are
+	// not in the library
+	WatchlistAutoRequest bool `bson:"watchlistAutoRequest,omitempty" json:"watchlistAutoRequest,omitempty"`
+}
+
+// RequestQuota limits how much a user may request per rolling period;
+// a zero limit means unlimited
+type RequestQuota struct {
+	MovieLimit  int `bson:"movieLimit" json:"movieLimit"`
+	SeasonLimit int `bson:"seasonLimit" json:"seasonLimit"`
+	Days        int `bson:"days" json:"days"`
 }
 
+// Authentication providers a user account can come from
+const (
+	AuthProviderLocal    = ""
+	AuthProviderOIDC     = "oidc"
+	AuthProviderJellyfin = "jellyfin"
+)
+
 // UserResponse is used for API responses (without sensitive data)
 type UserResponse struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email,omitempty"`
-	IsAdmin   bool      `json:"isAdmin"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID                 string        `json:"id"`
+	Username           string        `json:"username"`
+	Email              string        `json:"email,omitempty"`
+	IsAdmin            bool          `json:"isAdmin"`
+	MustChangePassword bool          `json:"mustChangePassword"`
+	MFAEnabled         bool          `json:"mfaEnabled"`
+	AuthProvider       string        `json:"authProvider,omitempty"`
+	JellyfinUserID     string        `json:"jellyfinUserId,omitempty"`
+	Roles              []string      `json:"roles,omitempty"`
+	Permissions        []string      `json:"permissions,omitempty"` // Effective permissions, set for the current user
+	Quota              *RequestQuota `json:"quota,omitempty"`
+	InviteID           string        `json:"inviteId,omitempty"`
+	CreatedAt          time.Time     `json:"createdAt"`
+	UpdatedAt          time.Time     `json:"updatedAt"`
 }
 
 // LoginRequest represents login credentials
@@ -33,18 +92,25 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
-// LoginResponse contains JWT token and user info
+// LoginResponse contains the access token, refresh token and user info
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refreshToken"`
+	ExpiresIn    int          `json:"expiresIn"` // Access token lifetime in seconds
+	User         UserResponse `json:"user"`
 }
 
 // CreateUserRequest for admin creating new users
 type CreateUserRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	IsAdmin  bool   `json:"isAdmin"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Password string   `json:"password"`
+	IsAdmin  bool     `json:"isAdmin"`
+	Roles    []string `json:"roles,omitempty"`
+	// MustChangePassword forces the user to pick a new password at first login
+	MustChangePassword bool `json:"mustChangePassword"`
+	// Quota overrides the default request quota
+	Quota *RequestQuota `json:"quota,omitempty"`
 }
 
 // UpdateUserRequest for updating user details
@@ -52,6 +118,24 @@ type UpdateUserRequest struct {
 	Email    *string `json:"email,omitempty"`
 	Password *string `json:"password,omitempty"`
 	IsAdmin  *bool   `json:"isAdmin,omitempty"`
+	ResetMFA *bool   `json:"resetMfa,omitempty"` // Disables two-factor authentication for the user
+	// JellyfinUserID links the account to a Jellyfin user; an empty string unlinks it
+	JellyfinUserID *string `json:"jellyfinUserId,omitempty"`
+	// Roles replaces the user's roles; an empty list falls back to the default role
+	Roles *[]string `json:"roles,omitempty"`
+	// MustChangePassword forces (or waives) a password change at next login
+	MustChangePassword *bool `json:"mustChangePassword,omitempty"`
+	// Quota overrides the default request quota; an empty object ({})
+	// reverts the user to the default
+	Quota *RequestQuota `json:"quota,omitempty"`
+}
+
+// RegisterRequest redeems an invite code to create an account
+type RegisterRequest struct {
+	Code     string `json:"code"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
 }
 
 // ChangePasswordRequest for users changing their own password
@@ -60,14 +144,33 @@ type ChangePasswordRequest struct {
 	NewPassword     string `json:"newPassword"`
 }
 
+// ChangePasswordResponse carries a fresh access token for the current session,
+// since the previous one may still be restricted to changing the password
+type ChangePasswordResponse struct {
+	Message   string       `json:"message"`
+	Token     string       `json:"token"`
+	ExpiresIn int          `json:"expiresIn"`
+	User      UserResponse `json:"user"`
+}
+
 // ToResponse converts User to UserResponse
 func (u *User) ToResponse() UserResponse {
-	return UserResponse{
-		ID:        u.ID.Hex(),
-		Username:  u.Username,
-		Email:     u.Email,
-		IsAdmin:   u.IsAdmin,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+	response := UserResponse{
+		ID:                 u.ID.Hex(),
+		Username:           u.Username,
+		Email:              u.Email,
+		IsAdmin:            u.IsAdmin,
+		MustChangePassword: u.MustChangePassword,
+		MFAEnabled:         u.MFAEnabled,
+		AuthProvider:       u.AuthProvider,
+		JellyfinUserID:     u.JellyfinUserID,
+		Roles:              u.Roles,
+		Quota:              u.Quota,
+		CreatedAt:          u.CreatedAt,
+		UpdatedAt:          u.UpdatedAt,
+	}
+	if u.InviteID != nil {
+		response.InviteID = u.InviteID.Hex()
 	}
+	return response
 }
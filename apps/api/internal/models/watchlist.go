@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WatchlistItem is a movie or show on a user's watchlist, identified by its
+// TMDB ID and media type whether or not it is in the library
+type WatchlistItem struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"userId" json:"-"`
+	MediaType  string             `bson:"mediaType" json:"mediaType"`
+	TMDBID     int                `bson:"tmdbId" json:"tmdbId"`
+	Title      string             `bson:"title" json:"title"`
+	Year       int                `bson:"year,omitempty" json:"year,omitempty"`
+	PosterPath string             `bson:"posterPath,omitempty" json:"posterPath,omitempty"`
+	Position   int                `bson:"position" json:"position"` // Lowest first
+	AddedAt    time.Time          `bson:"addedAt" json:"addedAt"`
+
+	// Resolved when the list is read
+	InLibrary    bool   `bson:"-" json:"inLibrary"`
+	JellyfinID   string `bson:"-" json:"jellyfinId,omitempty"`
+	RadarrStatus string `bson:"-" json:"radarrStatus,omitempty"`
+	SonarrStatus string `bson:"-" json:"sonarrStatus,omitempty"`
+	Requested    bool   `bson:"-" json:"requested"`
+
+	// Set on an added item when the automatic request was not made
+	RequestError string `bson:"-" json:"requestError,omitempty"`
+}
+
+// AddWatchlistItem is the body of a new watchlist entry
+type AddWatchlistItem struct {
+	MediaType  string `json:"mediaType"`
+	TMDBID     int    `json:"tmdbId"`
+	Title      string `json:"title"`
+	Year       int    `json:"year,omitempty"`
+	PosterPath string `json:"posterPath,omitempty"`
+}
+
+// WatchlistKey identifies a watchlist entry
+type WatchlistKey struct {
+	MediaType string `json:"mediaType"`
+	TMDBID    int    `json:"tmdbId"`
+}
+
+// ReorderWatchlist lists watchlist entries in their new order. Entries left
+// out keep their relative order after the listed ones.
+type ReorderWatchlist struct {
+	Items []WatchlistKey `json:"items"`
+}
+
+// WatchlistSettings are a user's watchlist options
+type WatchlistSettings struct {
+	AutoRequest bool `json:"autoRequest"`
+}
+
+// WatchlistResponse is a user's watchlist with their options
+type WatchlistResponse struct {
+	Items       []WatchlistItem `json:"items"`
+	AutoRequest bool            `json:"autoRequest"`
+}
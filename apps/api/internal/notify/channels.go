@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/events"
+	"jellystreaming/internal/models"
+)
+
+// validateURL requires an absolute http(s) URL
+func validateURL(raw string) error {
+	if raw == "" {
+		return errors.New("url required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// post sends body to rawURL and treats any non-2xx response as an error
+func post(ctx context.Context, client *http.Client, rawURL, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", appName)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned status %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// postJSON encodes payload and posts it
+func postJSON(ctx context.Context, client *http.Client, rawURL string, payload interface{}, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return post(ctx, client, rawURL, "application/json", body, headers)
+}
+
+// inboxChannel stores notifications in the user's in-app inbox
+type inboxChannel struct{}
+
+func (inboxChannel) Validate(target models.NotificationChannel) error {
+	return nil
+}
+
+func (inboxChannel) Send(ctx context.Context, user *models.User, target models.NotificationChannel, msg Message) error {
+	notification := models.Notification{
+		UserID:    user.ID,
+		Event:     msg.Event,
+		Title:     msg.Title,
+		Body:      msg.Body,
+		Link:      msg.Link,
+		CreatedAt: time.Now(),
+	}
+	result, err := database.NotificationsCollection.InsertOne(ctx, notification)
+	if err != nil {
+		return err
+	}
+	notification.ID, _ = result.InsertedID.(primitive.ObjectID)
+
+	events.Publish(events.NotificationCreated, &notification)
+	return nil
+}
+
+// webhookChannel posts a generic JSON document to any URL
+type webhookChannel struct {
+	client *http.Client
+}
+
+func (webhookChannel) Validate(target models.NotificationChannel) error {
+	return validateURL(target.URL)
+}
+
+func (c webhookChannel) Send(ctx context.Context, user *models.User, target models.NotificationChannel, msg Message) error {
+	headers := map[string]string{}
+	if target.Token != "" {
+		headers["Authorization"] = "Bearer " + target.Token
+	}
+	return postJSON(ctx, c.client, target.URL, map[string]interface{}{
+		"event":     msg.Event,
+		"title":     msg.Title,
+		"body":      msg.Body,
+		"link":      msg.Link,
+		"username":  user.Username,
+		"mediaType": msg.Data.MediaType,
+		"mediaName": msg.Data.Title,
+		"year":      msg.Data.Year,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}, headers)
+}
+
+// discordChannel posts an embed to a Discord (or compatible) webhook URL
+type discordChannel struct {
+	client *http.Client
+}
+
+// discordColors gives each event a recognisable embed colour
+var discordColors = map[string]int{
+	models.NotificationRequestApproved:   0x2ecc71,
+	models.NotificationRequestDeclined:   0xe74c3c,
+	models.NotificationDownloadCompleted: 0x3498db,
+	models.NotificationMediaAvailable:    0x9b59b6,
+}
+
+func (discordChannel) Validate(target models.NotificationChannel) error {
+	return validateURL(target.URL)
+}
+
+func (c discordChannel) Send(ctx context.Context, user *models.User, target models.NotificationChannel, msg Message) error {
+	embed := map[string]interface{}{
+		"title":       msg.Title,
+		"description": msg.Body,
+		"color":       discordColors[msg.Event],
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+	}
+	if msg.Link != "" {
+		embed["url"] = msg.Link
+	}
+	return postJSON(ctx, c.client, target.URL, map[string]interface{}{
+		"username": appName,
+		"embeds":   []interface{}{embed},
+	}, nil)
+}
+
+// ntfyChannel publishes to an ntfy topic URL, such as https://ntfy.sh/mytopic
+type ntfyChannel struct {
+	client *http.Client
+}
+
+func (ntfyChannel) Validate(target models.NotificationChannel) error {
+	if target.Priority < 0 || target.Priority > 5 {
+		return errors.New("ntfy priority must be between 1 and 5")
+	}
+	return validateURL(target.URL)
+}
+
+func (c ntfyChannel) Send(ctx context.Context, user *models.User, target models.NotificationChannel, msg Message) error {
+	headers := map[string]string{"Title": msg.Title}
+	if msg.Link != "" {
+		headers["Click"] = msg.Link
+	}
+	if target.Priority > 0 {
+		headers["Priority"] = strconv.Itoa(target.Priority)
+	}
+	if target.Token != "" {
+		headers["Authorization"] = "Bearer " + target.Token
+	}
+	return post(ctx, c.client, target.URL, "text/plain; charset=utf-8", []byte(msg.Body), headers)
+}
+
+// gotifyChannel sends a message to a Gotify server with an application token
+type gotifyChannel struct {
+	client *http.Client
+}
+
+func (gotifyChannel) Validate(target models.NotificationChannel) error {
+	if target.Token == "" {
+		return errors.New("gotify application token required")
+	}
+	if target.Priority < 0 || target.Priority > 10 {
+		return errors.New("gotify priority must be between 0 and 10")
+	}
+	return validateURL(target.URL)
+}
+
+func (c gotifyChannel) Send(ctx context.Context, user *models.User, target models.NotificationChannel, msg Message) error {
+	payload := map[string]interface{}{
+		"title":    msg.Title,
+		"message":  msg.Body,
+		"priority": target.Priority,
+	}
+	if msg.Link != "" {
+		payload["extras"] = map[string]interface{}{
+			"client::notification": map[string]interface{}{"click": map[string]string{"url": msg.Link}},
+		}
+	}
+	return postJSON(ctx, c.client, strings.TrimRight(target.URL, "/")+"/message", payload,
+		map[string]string{"X-Gotify-Key": target.Token})
+}
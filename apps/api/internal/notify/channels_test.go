@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jellystreaming/internal/models"
+)
+
+// capturedRequest is what a test server received
+type capturedRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// newCaptureServer records every request and answers with status
+func newCaptureServer(t *testing.T, status int) (*httptest.Server, chan capturedRequest) {
+	t.Helper()
+	requests := make(chan capturedRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- capturedRequest{method: r.Method, path: r.URL.Path, header: r.Header, body: body}
+		w.WriteHeader(status)
+		w.Write([]byte("rejected"))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func testMessage() Message {
+	return Message{
+		Event: models.NotificationRequestApproved,
+		Title: "Request approved: Alien (1979)",
+		Body:  "Your request for Alien was approved.",
+		Link:  "https://stream.example.com/requests",
+		Data:  Data{Title: "Alien", Year: 1979, MediaType: models.MediaTypeMovie},
+	}
+}
+
+var testUser = &models.User{Username: "ripley", Email: "ripley@example.com"}
+
+// decodeJSON unmarshals a captured body into a generic document
+func decodeJSON(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+	var document map[string]interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		t.Fatalf("body is not JSON: %v: %s", err, body)
+	}
+	return document
+}
+
+func TestWebhookChannel(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusNoContent)
+	channel := webhookChannel{client: server.Client()}
+
+	target := models.NotificationChannel{Type: models.ChannelWebhook, URL: server.URL + "/hook", Token: "secret"}
+	if err := channel.Send(context.Background(), testUser, target, testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/hook" {
+		t.Errorf("request = %s %s, want POST /hook", req.method, req.path)
+	}
+	if got := req.header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want Bearer secret", got)
+	}
+	document := decodeJSON(t, req.body)
+	for key, want := range map[string]interface{}{
+		"event":     models.NotificationRequestApproved,
+		"title":     "Request approved: Alien (1979)",
+		"username":  "ripley",
+		"mediaType": models.MediaTypeMovie,
+		"mediaName": "Alien",
+		"year":      float64(1979),
+	} {
+		if document[key] != want {
+			t.Errorf("%s = %v, want %v", key, document[key], want)
+		}
+	}
+}
+
+func TestWebhookChannelErrorStatus(t *testing.T) {
+	server, _ := newCaptureServer(t, http.StatusInternalServerError)
+	channel := webhookChannel{client: server.Client()}
+
+	err := channel.Send(context.Background(), testUser, models.NotificationChannel{URL: server.URL}, testMessage())
+	if err == nil || !strings.Contains(err.Error(), "status 500") || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("err = %v, want the status and response body", err)
+	}
+}
+
+func TestWebhookChannelValidate(t *testing.T) {
+	channel := webhookChannel{}
+	for _, raw := range []string{"", "example.com/hook", "ftp://example.com/hook", "https://"} {
+		if err := channel.Validate(models.NotificationChannel{URL: raw}); err == nil {
+			t.Errorf("Validate accepted %q", raw)
+		}
+	}
+	if err := channel.Validate(models.NotificationChannel{URL: "https://example.com/hook"}); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestDiscordChannel(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusNoContent)
+	channel := discordChannel{client: server.Client()}
+
+	if err := channel.Send(context.Background(), testUser, models.NotificationChannel{URL: server.URL}, testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	document := decodeJSON(t, (<-requests).body)
+	if document["username"] != appName {
+		t.Errorf("username = %v, want %s", document["username"], appName)
+	}
+	embeds, _ := document["embeds"].([]interface{})
+	if len(embeds) != 1 {
+		t.Fatalf("embeds = %v, want one embed", document["embeds"])
+	}
+	embed, _ := embeds[0].(map[string]interface{})
+	if embed["title"] != "Request approved: Alien (1979)" || embed["url"] != "https://stream.example.com/requests" {
+		t.Errorf("embed = %v", embed)
+	}
+	if embed["color"] != float64(discordColors[models.NotificationRequestApproved]) {
+		t.Errorf("color = %v, want %d", embed["color"], discordColors[models.NotificationRequestApproved])
+	}
+}
+
+func TestNtfyChannel(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusOK)
+	channel := ntfyChannel{client: server.Client()}
+
+	target := models.NotificationChannel{URL: server.URL + "/movies", Token: "tk_abc", Priority: 4}
+	if err := channel.Send(context.Background(), testUser, target, testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := <-requests
+	if req.path != "/movies" {
+		t.Errorf("path = %s, want /movies", req.path)
+	}
+	if string(req.body) != "Your request for Alien was approved." {
+		t.Errorf("body = %q", req.body)
+	}
+	for header, want := range map[string]string{
+		"Title":         "Request approved: Alien (1979)",
+		"Click":         "https://stream.example.com/requests",
+		"Priority":      "4",
+		"Authorization": "Bearer tk_abc",
+		"Content-Type":  "text/plain; charset=utf-8",
+	} {
+		if got := req.header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestNtfyChannelValidate(t *testing.T) {
+	channel := ntfyChannel{}
+	if err := channel.Validate(models.NotificationChannel{URL: "https://ntfy.sh/movies", Priority: 6}); err == nil {
+		t.Error("Validate accepted priority 6")
+	}
+	if err := channel.Validate(models.NotificationChannel{URL: "https://ntfy.sh/movies", Priority: 5}); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestGotifyChannel(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusOK)
+	channel := gotifyChannel{client: server.Client()}
+
+	target := models.NotificationChannel{URL: server.URL + "/gotify/", Token: "app-token", Priority: 7}
+	if err := channel.Send(context.Background(), testUser, target, testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := <-requests
+	if req.path != "/gotify/message" {
+		t.Errorf("path = %s, want /gotify/message", req.path)
+	}
+	if got := req.header.Get("X-Gotify-Key"); got != "app-token" {
+		t.Errorf("X-Gotify-Key = %q, want app-token", got)
+	}
+	document := decodeJSON(t, req.body)
+	if document["title"] != "Request approved: Alien (1979)" || document["message"] != "Your request for Alien was approved." {
+		t.Errorf("message = %v", document)
+	}
+	if document["priority"] != float64(7) {
+		t.Errorf("priority = %v, want 7", document["priority"])
+	}
+	extras, _ := document["extras"].(map[string]interface{})
+	notification, _ := extras["client::notification"].(map[string]interface{})
+	click, _ := notification["click"].(map[string]interface{})
+	if click["url"] != "https://stream.example.com/requests" {
+		t.Errorf("extras = %v", document["extras"])
+	}
+}
+
+func TestGotifyChannelValidate(t *testing.T) {
+	channel := gotifyChannel{}
+	if err := channel.Validate(models.NotificationChannel{URL: "https://gotify.example.com"}); err == nil {
+		t.Error("Validate accepted a channel without a token")
+	}
+	if err := channel.Validate(models.NotificationChannel{URL: "https://gotify.example.com", Token: "t", Priority: 11}); err == nil {
+		t.Error("Validate accepted priority 11")
+	}
+	if err := channel.Validate(models.NotificationChannel{URL: "https://gotify.example.com", Token: "t", Priority: 10}); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/models"
+)
+
+// SMTP connection security modes (SMTP_SECURITY)
+const (
+	smtpSecurityStartTLS = "starttls"
+	smtpSecurityTLS      = "tls"
+	smtpSecurityNone     = "none"
+)
+
+// emailChannel sends plain-text email through the configured SMTP server
+type emailChannel struct {
+	config *config.Config
+	// rootCAs verifies the server certificate; nil uses the system roots
+	rootCAs *x509.CertPool
+}
+
+func (emailChannel) Validate(target models.NotificationChannel) error {
+	if target.Email == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(target.Email); err != nil {
+		return errors.New("invalid email address")
+	}
+	return nil
+}
+
+func (c emailChannel) Send(ctx context.Context, user *models.User, target models.NotificationChannel, msg Message) error {
+	if c.config.SMTPHost == "" {
+		return errors.New("email is not configured on this server")
+	}
+
+	to := target.Email
+	if to == "" {
+		to = user.Email
+	}
+	if to == "" {
+		return errors.New("no email address on the channel or the account")
+	}
+
+	from := c.config.SMTPFrom
+	if from == "" {
+		from = c.config.SMTPUsername
+	}
+	fromAddress, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP_FROM address: %w", err)
+	}
+	toAddress, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	body := msg.Body
+	if msg.Link != "" && !strings.Contains(body, msg.Link) {
+		body += "\n\n" + msg.Link
+	}
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "From: %s\r\n", fromAddress.String())
+	fmt.Fprintf(&content, "To: %s\r\n", toAddress.String())
+	fmt.Fprintf(&content, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title))
+	fmt.Fprintf(&content, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	content.WriteString("MIME-Version: 1.0\r\n")
+	content.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	content.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	writer := quotedprintable.NewWriter(&content)
+	writer.Write([]byte(body))
+	writer.Close()
+
+	return c.deliver(ctx, fromAddress.Address, toAddress.Address, content.Bytes())
+}
+
+// deliver hands one message to the SMTP server
+func (c emailChannel) deliver(ctx context.Context, from, to string, content []byte) error {
+	host := c.config.SMTPHost
+	addr := net.JoinHostPort(host, strconv.Itoa(c.config.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: host, RootCAs: c.rootCAs}
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	switch c.config.SMTPSecurity {
+	case smtpSecurityTLS:
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	case smtpSecurityStartTLS, smtpSecurityNone:
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	default:
+		return fmt.Errorf("invalid SMTP_SECURITY %q, expected starttls, tls or none", c.config.SMTPSecurity)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if c.config.SMTPSecurity == smtpSecurityStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if c.config.SMTPUsername != "" {
+		// PlainAuth refuses to send credentials without TLS, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", c.config.SMTPUsername, c.config.SMTPPassword, host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(content); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
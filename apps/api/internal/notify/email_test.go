@@ -0,0 +1,284 @@
+package notify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/models"
+)
+
+// smtpSession is what the SMTP stub received during one connection
+type smtpSession struct {
+	tls  bool
+	auth string // Decoded AUTH PLAIN response
+	from string
+	to   []string
+	data string
+}
+
+// smtpStub is a single-connection SMTP server. With a certificate it offers
+// STARTTLS.
+type smtpStub struct {
+	listener net.Listener
+	cert     *tls.Certificate
+	sessions chan smtpSession
+}
+
+func newSMTPStub(t *testing.T, cert *tls.Certificate) *smtpStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	stub := &smtpStub{listener: listener, cert: cert, sessions: make(chan smtpSession, 1)}
+	go stub.serve()
+	return stub
+}
+
+// port is the port the stub listens on
+func (s *smtpStub) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStub) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var session smtpSession
+	defer func() { s.sessions <- session }()
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP stub")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			extensions := []string{"250-localhost", "250-AUTH PLAIN"}
+			if s.cert != nil && !session.tls {
+				extensions = append(extensions, "250-STARTTLS")
+			}
+			extensions = append(extensions, "250 8BITMIME")
+			for _, extension := range extensions {
+				text.PrintfLine("%s", extension)
+			}
+		case "STARTTLS":
+			if s.cert == nil {
+				text.PrintfLine("502 Not implemented")
+				continue
+			}
+			text.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*s.cert}})
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			session.tls = true
+			text = textproto.NewConn(tlsConn)
+		case "AUTH":
+			mechanism, response, _ := strings.Cut(arg, " ")
+			decoded, err := base64.StdEncoding.DecodeString(response)
+			if mechanism != "PLAIN" || err != nil {
+				text.PrintfLine("504 Unsupported")
+				continue
+			}
+			session.auth = string(decoded)
+			text.PrintfLine("235 Authenticated")
+		case "MAIL":
+			session.from = smtpPath(arg, "FROM:")
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			session.to = append(session.to, smtpPath(arg, "TO:"))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			session.data = string(data)
+			text.PrintfLine("250 Queued")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// smtpPath extracts the address from a MAIL or RCPT argument such as
+// "FROM:<a@example.com> BODY=8BITMIME"
+func smtpPath(arg, prefix string) string {
+	path, _, _ := strings.Cut(strings.TrimPrefix(arg, prefix), ">")
+	return strings.TrimPrefix(path, "<")
+}
+
+// selfSignedCert returns a certificate for 127.0.0.1 and a pool trusting it
+func selfSignedCert(t *testing.T) (*tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func testSMTPConfig(port int, security string) *config.Config {
+	return &config.Config{
+		SMTPHost:     "127.0.0.1",
+		SMTPPort:     port,
+		SMTPSecurity: security,
+		SMTPFrom:     "JellyStreaming <noreply@example.com>",
+	}
+}
+
+// receive waits for the stub to finish its session
+func (s *smtpStub) receive(t *testing.T) smtpSession {
+	t.Helper()
+	select {
+	case session := <-s.sessions:
+		return session
+	case <-time.After(10 * time.Second):
+		t.Fatal("SMTP stub received no session")
+		return smtpSession{}
+	}
+}
+
+func TestEmailChannelNoSecurity(t *testing.T) {
+	stub := newSMTPStub(t, nil)
+	channel := emailChannel{config: testSMTPConfig(stub.port(), smtpSecurityNone)}
+
+	if err := channel.Send(context.Background(), testUser, models.NotificationChannel{Type: models.ChannelEmail}, testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	session := stub.receive(t)
+	if session.tls {
+		t.Error("session used TLS with SMTP_SECURITY=none")
+	}
+	if session.from != "noreply@example.com" {
+		t.Errorf("MAIL FROM = %q, want noreply@example.com", session.from)
+	}
+	if len(session.to) != 1 || session.to[0] != "ripley@example.com" {
+		t.Errorf("RCPT TO = %v, want the account email", session.to)
+	}
+	for _, want := range []string{
+		"From: \"JellyStreaming\" <noreply@example.com>",
+		"To: <ripley@example.com>",
+		"Subject: Request approved: Alien (1979)",
+		"Content-Transfer-Encoding: quoted-printable",
+		"Your request for Alien was approved.",
+		"https://stream.example.com/requests",
+	} {
+		if !strings.Contains(session.data, want) {
+			t.Errorf("message is missing %q:\n%s", want, session.data)
+		}
+	}
+}
+
+func TestEmailChannelStartTLS(t *testing.T) {
+	cert, pool := selfSignedCert(t)
+	stub := newSMTPStub(t, cert)
+	cfg := testSMTPConfig(stub.port(), smtpSecurityStartTLS)
+	cfg.SMTPUsername = "mailer"
+	cfg.SMTPPassword = "hunter2"
+	channel := emailChannel{config: cfg, rootCAs: pool}
+
+	target := models.NotificationChannel{Type: models.ChannelEmail, Email: "alerts@example.com"}
+	if err := channel.Send(context.Background(), testUser, target, testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	session := stub.receive(t)
+	if !session.tls {
+		t.Error("session did not switch to TLS")
+	}
+	if session.auth != "\x00mailer\x00hunter2" {
+		t.Errorf("AUTH PLAIN = %q", session.auth)
+	}
+	if len(session.to) != 1 || session.to[0] != "alerts@example.com" {
+		t.Errorf("RCPT TO = %v, want the channel email", session.to)
+	}
+}
+
+func TestEmailChannelStartTLSUntrustedCertificate(t *testing.T) {
+	cert, _ := selfSignedCert(t)
+	stub := newSMTPStub(t, cert)
+	channel := emailChannel{config: testSMTPConfig(stub.port(), smtpSecurityStartTLS)}
+
+	err := channel.Send(context.Background(), testUser, models.NotificationChannel{Type: models.ChannelEmail}, testMessage())
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS failed") {
+		t.Fatalf("err = %v, want STARTTLS to fail certificate verification", err)
+	}
+}
+
+func TestEmailChannelStartTLSNotOffered(t *testing.T) {
+	stub := newSMTPStub(t, nil)
+	channel := emailChannel{config: testSMTPConfig(stub.port(), smtpSecurityStartTLS)}
+
+	err := channel.Send(context.Background(), testUser, models.NotificationChannel{Type: models.ChannelEmail}, testMessage())
+	if err == nil {
+		t.Fatal("Send fell back to plain text when the server did not offer STARTTLS")
+	}
+	if session := stub.receive(t); session.data != "" {
+		t.Error("message was delivered without TLS")
+	}
+}
+
+func TestEmailChannelInvalidSecurity(t *testing.T) {
+	channel := emailChannel{config: testSMTPConfig(25, "ssl")}
+	err := channel.Send(context.Background(), testUser, models.NotificationChannel{Type: models.ChannelEmail}, testMessage())
+	if err == nil || !strings.Contains(err.Error(), strconv.Quote("ssl")) {
+		t.Fatalf("err = %v, want invalid SMTP_SECURITY", err)
+	}
+}
+
+func TestEmailChannelNoRecipient(t *testing.T) {
+	channel := emailChannel{config: testSMTPConfig(25, smtpSecurityNone)}
+	err := channel.Send(context.Background(), &models.User{Username: "ripley"}, models.NotificationChannel{Type: models.ChannelEmail}, testMessage())
+	if err == nil {
+		t.Fatal("Send accepted a user without an email address")
+	}
+}
@@ -0,0 +1,151 @@
+// Package notify delivers notifications about requests, downloads and new
+// library titles to each user's channels: the in-app inbox, email, generic
+// and Discord webhooks, ntfy and Gotify.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jellystreaming/internal/config"
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/models"
+)
+
+// appName is used in notifications when no other name fits
+const appName = "JellyStreaming"
+
+// sendTimeout bounds the delivery to a single channel
+const sendTimeout = 15 * time.Second
+
+// Message is a rendered notification
+type Message struct {
+	Event string
+	Title string
+	Body  string
+	Link  string
+	Data  Data
+}
+
+// Channel delivers messages to one kind of destination
+type Channel interface {
+	// Validate checks a user's settings for the channel before they are saved
+	Validate(target models.NotificationChannel) error
+	// Send delivers msg to user through the destination in target
+	Send(ctx context.Context, user *models.User, target models.NotificationChannel, msg Message) error
+}
+
+// Notifier renders notifications and sends them through each user's channels
+type Notifier struct {
+	config    *config.Config
+	templates map[string]*template.Template
+	channels  map[string]Channel
+}
+
+// New creates a Notifier with every built-in channel
+func New(cfg *config.Config) *Notifier {
+	client := &http.Client{Timeout: sendTimeout}
+	return &Notifier{
+		config:    cfg,
+		templates: loadTemplates(cfg.NotificationTemplatesDir),
+		channels: map[string]Channel{
+			models.ChannelInbox:   inboxChannel{},
+			models.ChannelEmail:   emailChannel{config: cfg},
+			models.ChannelWebhook: webhookChannel{client: client},
+			models.ChannelDiscord: discordChannel{client: client},
+			models.ChannelNtfy:    ntfyChannel{client: client},
+			models.ChannelGotify:  gotifyChannel{client: client},
+		},
+	}
+}
+
+// Validate checks a channel's type and settings
+func (n *Notifier) Validate(target models.NotificationChannel) error {
+	channel, ok := n.channels[target.Type]
+	if !ok {
+		return fmt.Errorf("unknown notification channel: %s", target.Type)
+	}
+	for _, event := range target.Events {
+		if !isNotificationEvent(event) {
+			return fmt.Errorf("unknown notification event: %s", event)
+		}
+	}
+	return channel.Validate(target)
+}
+
+// isNotificationEvent reports whether event can be subscribed to
+func isNotificationEvent(event string) bool {
+	for _, e := range models.NotificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// UserChannels returns the user's channels, or the defaults if they never
+// saved any
+func UserChannels(user *models.User) []models.NotificationChannel {
+	if user.Notifications == nil {
+		return models.DefaultNotificationChannels
+	}
+	return user.Notifications
+}
+
+// Send delivers data to one channel regardless of its event subscriptions
+func (n *Notifier) Send(ctx context.Context, user *models.User, target models.NotificationChannel, data Data) error {
+	channel, ok := n.channels[target.Type]
+	if !ok {
+		return fmt.Errorf("unknown notification channel: %s", target.Type)
+	}
+
+	msg, err := n.render(n.withDefaults(user, data))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return channel.Send(ctx, user, target, msg)
+}
+
+// NotifyUser sends data to every channel of the user that wants its event.
+// Delivery failures are logged; one failing channel does not stop the others.
+func (n *Notifier) NotifyUser(userID primitive.ObjectID, data Data) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := database.UsersCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		log.Printf("Error loading user %s for notification: %v", userID.Hex(), err)
+		return
+	}
+
+	for _, target := range UserChannels(&user) {
+		if !target.Wants(data.Event) {
+			continue
+		}
+		if err := n.Send(context.Background(), &user, target, data); err != nil {
+			log.Printf("Error sending %s notification to %s via %s: %v", data.Event, user.Username, target.Type, err)
+		}
+	}
+}
+
+// withDefaults fills in the fields every notification shares
+func (n *Notifier) withDefaults(user *models.User, data Data) Data {
+	data.AppName = appName
+	if data.Username == "" {
+		data.Username = user.Username
+	}
+	if data.Link == "" {
+		data.Link = n.config.AppURL
+	}
+	return data
+}
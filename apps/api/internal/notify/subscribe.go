@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jellystreaming/internal/database"
+	"jellystreaming/internal/events"
+	"jellystreaming/internal/models"
+)
+
+// Subscribe registers the notifier for the events users are notified about
+func (n *Notifier) Subscribe() {
+	events.Subscribe(events.RequestApproved, n.onRequest(models.NotificationRequestApproved))
+	events.Subscribe(events.RequestDeclined, n.onRequest(models.NotificationRequestDeclined))
+	events.Subscribe(events.RequestAvailable, n.onRequest(models.NotificationMediaAvailable))
+	events.Subscribe(events.RadarrDownload, n.onRadarrDownload)
+	events.Subscribe(events.SonarrDownload, n.onSonarrDownload)
+}
+
+// requestData is the template data for a notification about a request
+func requestData(event string, request *models.MediaRequest) Data {
+	return Data{
+		Event:     event,
+		Title:     request.Title,
+		Year:      request.Year,
+		MediaType: request.MediaType,
+		Seasons:   request.Seasons,
+		Reason:    request.DeclineReason,
+	}
+}
+
+// onRequest notifies the requester when their request changes state
+func (n *Notifier) onRequest(notification string) events.Handler {
+	return func(event events.Event) {
+		request, ok := event.Payload.(*models.MediaRequest)
+		if !ok {
+			return
+		}
+		n.NotifyUser(request.RequestedBy, requestData(notification, request))
+	}
+}
+
+// onRadarrDownload tells everyone with an approved request for the movie that
+// it finished downloading
+func (n *Notifier) onRadarrDownload(event events.Event) {
+	payload, ok := event.Payload.(*models.RadarrWebhookPayload)
+	if !ok || payload.Movie == nil || payload.Movie.TmdbId == 0 {
+		return
+	}
+
+	data := Data{
+		Event:     models.NotificationDownloadCompleted,
+		Title:     payload.Movie.Title,
+		Year:      payload.Movie.Year,
+		MediaType: models.MediaTypeMovie,
+	}
+	if payload.MovieFile != nil {
+		data.Quality = payload.MovieFile.Quality
+	}
+
+	n.notifyRequesters(bson.M{
+		"mediaType": models.MediaTypeMovie,
+		"tmdbId":    payload.Movie.TmdbId,
+	}, nil, data)
+}
+
+// onSonarrDownload tells everyone with an approved request covering the
+// downloaded episodes' season that they finished downloading
+func (n *Notifier) onSonarrDownload(event events.Event) {
+	payload, ok := event.Payload.(*models.SonarrWebhookPayload)
+	if !ok || payload.Series == nil {
+		return
+	}
+
+	ids := bson.A{}
+	if payload.Series.TvdbId > 0 {
+		ids = append(ids, bson.M{"tvdbId": payload.Series.TvdbId})
+	}
+	if payload.Series.TmdbId > 0 {
+		ids = append(ids, bson.M{"tmdbId": payload.Series.TmdbId})
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	seasons := []int{}
+	episodes := make([]string, 0, len(payload.Episodes))
+	for _, episode := range payload.Episodes {
+		episodes = append(episodes, fmt.Sprintf("S%02dE%02d", episode.SeasonNumber, episode.EpisodeNumber))
+		seasons = append(seasons, episode.SeasonNumber)
+	}
+	sort.Ints(seasons)
+
+	data := Data{
+		Event:     models.NotificationDownloadCompleted,
+		Title:     payload.Series.Title,
+		Year:      payload.Series.Year,
+		MediaType: models.MediaTypeTV,
+		Episode:   strings.Join(episodes, ", "),
+	}
+	if payload.EpisodeFile != nil {
+		data.Quality = payload.EpisodeFile.Quality
+	}
+
+	n.notifyRequesters(bson.M{
+		"mediaType": models.MediaTypeTV,
+		"$or":       ids,
+	}, seasons, data)
+}
+
+// notifyRequesters notifies each user with an approved request matching
+// filter once. When seasons is set, requests limited to other seasons are
+// skipped.
+func (n *Notifier) notifyRequesters(filter bson.M, seasons []int, data Data) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter["status"] = models.RequestStatusApproved
+	cursor, err := database.RequestsCollection.Find(ctx, filter)
+	if err != nil {
+		log.Printf("Error finding requests for %s notification: %v", data.Event, err)
+		return
+	}
+	var requests []models.MediaRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		log.Printf("Error decoding requests for %s notification: %v", data.Event, err)
+		return
+	}
+
+	notified := make(map[primitive.ObjectID]bool)
+	for _, request := range requests {
+		if notified[request.RequestedBy] || !coversSeasons(request.Seasons, seasons) {
+			continue
+		}
+		notified[request.RequestedBy] = true
+		n.NotifyUser(request.RequestedBy, data)
+	}
+}
+
+// coversSeasons reports whether a request for the given seasons (all when
+// empty) includes any of the downloaded seasons (any when empty)
+func coversSeasons(requested, downloaded []int) bool {
+	if len(requested) == 0 || len(downloaded) == 0 {
+		return true
+	}
+	for _, season := range downloaded {
+		for _, wanted := range requested {
+			if season == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
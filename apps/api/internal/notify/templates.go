@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// templateFuncs are available to every notification template
+var templateFuncs = template.FuncMap{
+	"join": func(numbers []int) string {
+		parts := make([]string, len(numbers))
+		for i, n := range numbers {
+			parts[i] = strconv.Itoa(n)
+		}
+		return strings.Join(parts, ", ")
+	},
+}
+
+// Data is what notification templates render. Each template file defines a
+// "title" and a "body" template.
+type Data struct {
+	Event     string
+	AppName   string
+	Username  string
+	Title     string
+	Year      int
+	MediaType string // movie or tv
+	Seasons   []int
+	Episode   string // Episodes covered by a download, such as S01E02
+	Quality   string
+	Reason    string // Why a request was declined
+	Link      string
+}
+
+// loadTemplates parses the built-in templates and replaces any that have an
+// <event>.tmpl override in dir. A broken override is logged and skipped.
+func loadTemplates(dir string) map[string]*template.Template {
+	templates := make(map[string]*template.Template)
+
+	entries, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		log.Fatalf("Error reading notification templates: %v", err)
+	}
+	for _, entry := range entries {
+		event := strings.TrimSuffix(entry.Name(), ".tmpl")
+		templates[event] = template.Must(template.New(event).Funcs(templateFuncs).ParseFS(builtinTemplates, "templates/"+entry.Name()))
+	}
+
+	if dir == "" {
+		return templates
+	}
+	for event := range templates {
+		path := filepath.Join(dir, event+".tmpl")
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Warning: Could not read notification template %s: %v", path, err)
+			continue
+		}
+		tmpl, err := template.New(event).Funcs(templateFuncs).Parse(string(content))
+		if err != nil {
+			log.Printf("Warning: Ignoring notification template %s: %v", path, err)
+			continue
+		}
+		templates[event] = tmpl
+		log.Printf("Using notification template %s", path)
+	}
+	return templates
+}
+
+// render executes the title and body templates for data.Event
+func (n *Notifier) render(data Data) (Message, error) {
+	tmpl, ok := n.templates[data.Event]
+	if !ok {
+		return Message{}, fmt.Errorf("no template for notification event %s", data.Event)
+	}
+
+	var title, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&title, "title", data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Event: data.Event,
+		Title: strings.TrimSpace(title.String()),
+		Body:  strings.TrimSpace(body.String()),
+		Link:  data.Link,
+		Data:  data,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"jellystreaming/internal/models"
+)
+
+func TestRenderBuiltinTemplates(t *testing.T) {
+	n := &Notifier{templates: loadTemplates("")}
+
+	for _, event := range models.NotificationEvents {
+		msg, err := n.render(Data{Event: event, AppName: appName, Username: "ripley", Title: "Alien", Year: 1979})
+		if err != nil {
+			t.Errorf("%s: %v", event, err)
+			continue
+		}
+		if msg.Title == "" || msg.Body == "" {
+			t.Errorf("%s rendered an empty title or body: %+v", event, msg)
+		}
+	}
+
+	msg, err := n.render(Data{Event: models.NotificationRequestApproved, Title: "Severance", Year: 2022, Seasons: []int{1, 2}})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if msg.Title != "Request approved: Severance (2022)" {
+		t.Errorf("title = %q", msg.Title)
+	}
+	if want := "Your request for Severance (season 1, 2) was approved and is on its way. We'll let you know when it's ready to watch."; msg.Body != want {
+		t.Errorf("body = %q, want %q", msg.Body, want)
+	}
+}
+
+func TestRenderUnknownEvent(t *testing.T) {
+	n := &Notifier{templates: loadTemplates("")}
+	if _, err := n.render(Data{Event: "request.unknown"}); err == nil {
+		t.Fatal("render accepted an event without a template")
+	}
+}
+
+func TestTemplateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	overrides := map[string]string{
+		// Replaces the built-in template
+		models.NotificationRequestApproved: `{{define "title"}}[{{.AppName}}] {{.Title}} approved{{end}}` +
+			`{{define "body"}}Hi {{.Username}}, {{.Title}} is coming.{{end}}`,
+		// Broken: the built-in template stays in use
+		models.NotificationRequestDeclined: `{{define "title"}}{{.Title{{end}}`,
+		// Not an event: ignored
+		"request.unknown": `{{define "title"}}unused{{end}}{{define "body"}}unused{{end}}`,
+	}
+	for event, content := range overrides {
+		if err := os.WriteFile(filepath.Join(dir, event+".tmpl"), []byte(content), 0o644); err != nil {
+			t.Fatalf("error writing override: %v", err)
+		}
+	}
+
+	n := &Notifier{templates: loadTemplates(dir)}
+
+	msg, err := n.render(Data{Event: models.NotificationRequestApproved, AppName: appName, Username: "ripley", Title: "Alien"})
+	if err != nil {
+		t.Fatalf("render override: %v", err)
+	}
+	if msg.Title != "[JellyStreaming] Alien approved" || msg.Body != "Hi ripley, Alien is coming." {
+		t.Errorf("override rendered %q / %q", msg.Title, msg.Body)
+	}
+
+	builtin := &Notifier{templates: loadTemplates("")}
+	declined := Data{Event: models.NotificationRequestDeclined, Title: "Alien", Reason: "Not available"}
+	got, err := n.render(declined)
+	if err != nil {
+		t.Fatalf("render after broken override: %v", err)
+	}
+	want, _ := builtin.render(declined)
+	if got.Title != want.Title || got.Body != want.Body {
+		t.Errorf("broken override was used: got %q / %q, want %q / %q", got.Title, got.Body, want.Title, want.Body)
+	}
+
+	if _, ok := n.templates["request.unknown"]; ok {
+		t.Error("an override for an unknown event was loaded")
+	}
+}
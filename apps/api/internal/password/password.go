@@ -0,0 +1,99 @@
+// Package password implements the password policy applied wherever a user
+// password is set.
+package password
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxLength is bcrypt's input limit; longer passwords would be truncated
+const maxLength = 72
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords is the embedded list of frequently used passwords
+var commonPasswords = parseList(commonPasswordList)
+
+// Policy describes the requirements a new password must meet
+type Policy struct {
+	MinLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	BlockCommon      bool
+}
+
+// Validate returns an error describing the first requirement the password
+// does not meet. The username is used to reject passwords derived from it.
+func (p Policy) Validate(password, username string) error {
+	if len(password) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if len(password) > maxLength {
+		return fmt.Errorf("password must be at most %d bytes", maxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUppercase && !hasUpper {
+		return errors.New("password must contain an uppercase letter")
+	}
+	if p.RequireLowercase && !hasLower {
+		return errors.New("password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		return errors.New("password must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		return errors.New("password must contain a symbol")
+	}
+
+	lower := strings.ToLower(password)
+	// Very short usernames would match too many unrelated passwords
+	if len(username) >= 3 && strings.Contains(lower, strings.ToLower(username)) {
+		return errors.New("password must not contain the username")
+	}
+	if p.BlockCommon && IsCommon(password) {
+		return errors.New("password is too common, choose another one")
+	}
+
+	return nil
+}
+
+// IsCommon reports whether a password appears in the common password list,
+// ignoring case
+func IsCommon(password string) bool {
+	_, ok := commonPasswords[strings.ToLower(password)]
+	return ok
+}
+
+// parseList turns the embedded list into a set, skipping blanks and comments
+func parseList(list string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+	}
+	return set
+}
@@ -3,21 +3,42 @@ package routes
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"jellystreaming/internal/config"
 	"jellystreaming/internal/handlers"
 	"jellystreaming/internal/middleware"
+	"jellystreaming/internal/models"
+	"jellystreaming/internal/notify"
 )
 
 // Setup configures all application routes
 func Setup(cfg *config.Config) {
 	// Initialize handlers
 	healthHandler := handlers.NewHealthHandler()
-	authHandler := handlers.NewAuthHandler()
+	authHandler := handlers.NewAuthHandler(cfg)
 	jellyfinHandler := handlers.NewJellyfinHandler(cfg)
 	tmdbHandler := handlers.NewTMDBHandler(cfg)
 	radarrHandler := handlers.NewRadarrHandler(cfg)
 	sonarrHandler := handlers.NewSonarrHandler(cfg)
+	streamHandler := handlers.NewStreamHandler(cfg)
+	roleHandler := handlers.NewRoleHandler(cfg)
+	securityHandler := handlers.NewSecurityHandler()
+	inviteHandler := handlers.NewInviteHandler(cfg)
+	auditHandler := handlers.NewAuditHandler()
+	requestHandler := handlers.NewRequestHandler(cfg)
+	webhookHandler := handlers.NewWebhookHandler(cfg)
+	notifier := notify.New(cfg)
+	notificationHandler := handlers.NewNotificationHandler(cfg, notifier)
+	playbackHandler := handlers.NewPlaybackHandler(cfg)
+	watchlistHandler := handlers.NewWatchlistHandler(cfg, tmdbHandler, requestHandler)
+	availabilityHandler := handlers.NewAvailabilityHandler(cfg, tmdbHandler)
+
+	// Internal event subscribers
+	requestHandler.SubscribeEvents()
+	notifier.Subscribe()
+	notificationHandler.SubscribeEvents()
+	tmdbHandler.SubscribeEvents()
 
 	// Public routes
 	http.HandleFunc("/health", middleware.EnableCORS(healthHandler.Check))
@@ -26,15 +47,68 @@ func Setup(cfg *config.Config) {
 	http.HandleFunc("/api/auth/login", middleware.EnableCORS(authHandler.Login))
 	http.HandleFunc("/api/auth/verify", middleware.EnableCORS(middleware.Auth(authHandler.VerifyToken)))
 	http.HandleFunc("/api/auth/me", middleware.EnableCORS(middleware.Auth(authHandler.GetCurrentUser)))
+	http.HandleFunc("/api/auth/me/quota", middleware.EnableCORS(middleware.Auth(authHandler.GetQuota)))
+	http.HandleFunc("/api/auth/me/notifications", middleware.EnableCORS(middleware.Auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			notificationHandler.GetPreferences(w, r)
+		case http.MethodPut:
+			notificationHandler.UpdatePreferences(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	http.HandleFunc("/api/auth/me/notifications/test", middleware.EnableCORS(middleware.Auth(notificationHandler.TestNotification)))
 	http.HandleFunc("/api/auth/change-password", middleware.EnableCORS(middleware.Auth(authHandler.ChangePassword)))
+	http.HandleFunc("/api/auth/refresh", middleware.EnableCORS(authHandler.Refresh))
+	http.HandleFunc("/api/auth/logout", middleware.EnableCORS(middleware.Auth(authHandler.Logout)))
+	http.HandleFunc("/api/auth/register", middleware.EnableCORS(authHandler.Register))
+
+	// Single sign-on routes
+	http.HandleFunc("/api/auth/oidc/config", middleware.EnableCORS(authHandler.OIDCConfig))
+	http.HandleFunc("/api/auth/oidc/login", authHandler.OIDCLogin)
+	http.HandleFunc("/api/auth/oidc/callback", authHandler.OIDCCallback)
 
-	// User management routes (admin only)
+	// Two-factor authentication routes
+	http.HandleFunc("/api/auth/mfa/verify", middleware.EnableCORS(authHandler.VerifyMFA))
+	http.HandleFunc("/api/auth/mfa/setup", middleware.EnableCORS(middleware.Auth(authHandler.SetupMFA)))
+	http.HandleFunc("/api/auth/mfa/enable", middleware.EnableCORS(middleware.Auth(authHandler.EnableMFA)))
+	http.HandleFunc("/api/auth/mfa/disable", middleware.EnableCORS(middleware.Auth(authHandler.DisableMFA)))
+	http.HandleFunc("/api/auth/mfa/recovery-codes", middleware.EnableCORS(middleware.Auth(authHandler.RegenerateRecoveryCodes)))
+
+	// Session management routes
+	http.HandleFunc("/api/auth/sessions", middleware.EnableCORS(middleware.Auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			authHandler.ListSessions(w, r)
+		case http.MethodDelete:
+			authHandler.RevokeAllSessions(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	http.HandleFunc("/api/auth/sessions/", middleware.EnableCORS(middleware.Auth(authHandler.RevokeSession)))
+
+	// API key routes
+	http.HandleFunc("/api/auth/api-keys", middleware.EnableCORS(middleware.Auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			authHandler.ListAPIKeys(w, r)
+		case http.MethodPost:
+			authHandler.CreateAPIKey(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	http.HandleFunc("/api/auth/api-keys/", middleware.EnableCORS(middleware.Auth(authHandler.RevokeAPIKey)))
+
+	// User management routes
 	http.HandleFunc("/api/users", middleware.EnableCORS(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			middleware.Admin(authHandler.ListUsers)(w, r)
+			middleware.RequirePermission(models.PermissionUsersManage, authHandler.ListUsers)(w, r)
 		case http.MethodPost:
-			middleware.Admin(authHandler.CreateUser)(w, r)
+			middleware.RequirePermission(models.PermissionUsersManage, authHandler.CreateUser)(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
@@ -43,19 +117,183 @@ func Setup(cfg *config.Config) {
 	http.HandleFunc("/api/users/", middleware.EnableCORS(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPut:
-			middleware.Admin(authHandler.UpdateUser)(w, r)
+			middleware.RequirePermission(models.PermissionUsersManage, authHandler.UpdateUser)(w, r)
 		case http.MethodDelete:
-			middleware.Admin(authHandler.DeleteUser)(w, r)
+			middleware.RequirePermission(models.PermissionUsersManage, authHandler.DeleteUser)(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
 
+	// Role management routes
+	http.HandleFunc("/api/roles", middleware.EnableCORS(middleware.RequirePermission(models.PermissionUsersManage, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			roleHandler.ListRoles(w, r)
+		case http.MethodPost:
+			roleHandler.CreateRole(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	http.HandleFunc("/api/roles/", middleware.EnableCORS(middleware.RequirePermission(models.PermissionUsersManage, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/roles/permissions" {
+			roleHandler.ListPermissions(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			roleHandler.UpdateRole(w, r)
+		case http.MethodDelete:
+			roleHandler.DeleteRole(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	// Invite routes
+	http.HandleFunc("/api/invites", middleware.EnableCORS(middleware.RequirePermission(models.PermissionUsersManage, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			inviteHandler.ListInvites(w, r)
+		case http.MethodPost:
+			inviteHandler.CreateInvite(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	http.HandleFunc("/api/invites/", middleware.EnableCORS(middleware.RequirePermission(models.PermissionUsersManage, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			inviteHandler.GetInvite(w, r)
+		case http.MethodDelete:
+			inviteHandler.RevokeInvite(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	// Security routes
+	http.HandleFunc("/api/security/lockouts", middleware.EnableCORS(middleware.RequirePermission(models.PermissionUsersManage, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			securityHandler.ListLockouts(w, r)
+		case http.MethodDelete:
+			securityHandler.Unlock(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	http.HandleFunc("/api/security/failed-logins", middleware.EnableCORS(middleware.RequirePermission(models.PermissionUsersManage, securityHandler.ListFailedLogins)))
+
+	// Audit log routes
+	http.HandleFunc("/api/audit", middleware.EnableCORS(middleware.RequirePermission(models.PermissionAuditView, auditHandler.List)))
+	http.HandleFunc("/api/audit/export", middleware.EnableCORS(middleware.RequirePermission(models.PermissionAuditView, auditHandler.Export)))
+
+	// Media request routes (handlers check request.movie/request.tv and ownership)
+	http.HandleFunc("/api/requests", middleware.EnableCORS(middleware.Auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			requestHandler.ListRequests(w, r)
+		case http.MethodPost:
+			requestHandler.CreateRequest(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	http.HandleFunc("/api/requests/", middleware.EnableCORS(middleware.Auth(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/approve"):
+			middleware.RequirePermission(models.PermissionRequestsManage, requestHandler.ApproveRequest)(w, r)
+		case strings.HasSuffix(r.URL.Path, "/decline"):
+			middleware.RequirePermission(models.PermissionRequestsManage, requestHandler.DeclineRequest)(w, r)
+		case r.Method == http.MethodGet:
+			requestHandler.GetRequest(w, r)
+		case r.Method == http.MethodDelete:
+			requestHandler.CancelRequest(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	// Availability of a title across Jellyfin, Radarr/Sonarr and requests
+	http.HandleFunc("/api/availability", middleware.EnableCORS(middleware.Auth(availabilityHandler.GetAvailability)))
+
+	// Notification inbox routes (each user only sees their own inbox)
+	http.HandleFunc("/api/notifications", middleware.EnableCORS(middleware.Auth(notificationHandler.ListNotifications)))
+	http.HandleFunc("/api/notifications/unread-count", middleware.EnableCORS(middleware.Auth(notificationHandler.UnreadCount)))
+	http.HandleFunc("/api/notifications/read-all", middleware.EnableCORS(middleware.Auth(notificationHandler.MarkAllRead)))
+	http.HandleFunc("/api/notifications/stream", middleware.EnableCORS(middleware.Auth(notificationHandler.Stream)))
+	http.HandleFunc("/api/notifications/broadcast", middleware.EnableCORS(middleware.RequirePermission(models.PermissionNotificationsBroadcast, notificationHandler.Broadcast)))
+	http.HandleFunc("/api/notifications/", middleware.EnableCORS(middleware.Auth(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/read"):
+			notificationHandler.MarkRead(w, r)
+		case r.Method == http.MethodDelete:
+			notificationHandler.DeleteNotification(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	// Inbound webhooks (authenticated with WEBHOOK_SECRET or JELLYFIN_WEBHOOK_TOKEN)
+	http.HandleFunc("/api/webhooks/radarr", middleware.EnableCORS(webhookHandler.Radarr))
+	http.HandleFunc("/api/webhooks/sonarr", middleware.EnableCORS(webhookHandler.Sonarr))
+	http.HandleFunc("/api/webhooks/jellyfin", middleware.EnableCORS(webhookHandler.Jellyfin))
+
 	// Jellyfin routes
 	http.HandleFunc("/api/jellyfin/movies", middleware.EnableCORS(middleware.Auth(jellyfinHandler.GetMovies)))
 	http.HandleFunc("/api/config", middleware.EnableCORS(middleware.Auth(jellyfinHandler.GetConfig)))
 	http.HandleFunc("/api/jellyfin/movies/search", middleware.EnableCORS(middleware.Auth(jellyfinHandler.SearchMovies)))
 	http.HandleFunc("/api/jellyfin/series", middleware.EnableCORS(middleware.Auth(jellyfinHandler.GetSeries)))
+	http.HandleFunc("/api/jellyfin/series/", middleware.EnableCORS(middleware.Auth(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/episodes"):
+			jellyfinHandler.GetEpisodes(w, r)
+		case strings.HasSuffix(r.URL.Path, "/seasons"):
+			jellyfinHandler.GetSeasons(w, r)
+		case strings.HasSuffix(r.URL.Path, "/next-episode"):
+			jellyfinHandler.GetNextEpisode(w, r)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+	})))
+	http.HandleFunc("/api/jellyfin/libraries", middleware.EnableCORS(middleware.Auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			jellyfinHandler.GetLibraries(w, r)
+		case http.MethodPut:
+			middleware.RequirePermission(models.PermissionLibrariesManage, jellyfinHandler.UpdateLibraries)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	http.HandleFunc("/api/jellyfin/users", middleware.EnableCORS(middleware.RequirePermission(models.PermissionUsersManage, jellyfinHandler.ListUsers)))
+
+	// Playback reporting and the caller's watch feeds
+	http.HandleFunc("/api/playback/start", middleware.EnableCORS(middleware.Auth(playbackHandler.StartPlayback)))
+	http.HandleFunc("/api/playback/progress", middleware.EnableCORS(middleware.Auth(playbackHandler.ReportProgress)))
+	http.HandleFunc("/api/playback/stop", middleware.EnableCORS(middleware.Auth(playbackHandler.StopPlayback)))
+	http.HandleFunc("/api/me/continue-watching", middleware.EnableCORS(middleware.Auth(playbackHandler.ContinueWatching)))
+	http.HandleFunc("/api/me/next-up", middleware.EnableCORS(middleware.Auth(playbackHandler.NextUp)))
+
+	// Watchlist routes (each user only sees their own list)
+	http.HandleFunc("/api/watchlist", middleware.EnableCORS(middleware.Auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			watchlistHandler.GetWatchlist(w, r)
+		case http.MethodPost:
+			watchlistHandler.AddToWatchlist(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	http.HandleFunc("/api/watchlist/order", middleware.EnableCORS(middleware.Auth(watchlistHandler.ReorderWatchlist)))
+	http.HandleFunc("/api/watchlist/settings", middleware.EnableCORS(middleware.Auth(watchlistHandler.UpdateSettings)))
+	http.HandleFunc("/api/watchlist/", middleware.EnableCORS(middleware.Auth(watchlistHandler.RemoveFromWatchlist)))
+
+	// Streaming proxy (authenticates itself: bearer/access_token, stream token, or none for images)
+	http.HandleFunc("/api/stream/", middleware.EnableCORS(streamHandler.Proxy))
 
 	// TMDB routes
 	http.HandleFunc("/api/tmdb/proxy", middleware.EnableCORS(middleware.Auth(tmdbHandler.Proxy)))
@@ -67,18 +305,18 @@ func Setup(cfg *config.Config) {
 	http.HandleFunc("/api/tmdb/search", middleware.EnableCORS(middleware.Auth(tmdbHandler.Search)))
 
 	// Radarr routes
-	http.HandleFunc("/api/radarr/movie", middleware.EnableCORS(middleware.Auth(radarrHandler.AddMovie)))
-	http.HandleFunc("/api/radarr/queue", middleware.EnableCORS(middleware.Auth(radarrHandler.GetQueue)))
+	http.HandleFunc("/api/radarr/movie", middleware.EnableCORS(middleware.RequirePermission(models.PermissionRequestsManage, radarrHandler.AddMovie)))
+	http.HandleFunc("/api/radarr/queue", middleware.EnableCORS(middleware.RequirePermission(models.PermissionDownloadsView, radarrHandler.GetQueue)))
 	http.HandleFunc("/api/radarr/movies", middleware.EnableCORS(middleware.Auth(radarrHandler.GetMovies)))
 	http.HandleFunc("/api/radarr/rootfolders", middleware.EnableCORS(middleware.Auth(radarrHandler.GetRootFolders)))
-	http.HandleFunc("/api/radarr/refresh", middleware.EnableCORS(middleware.Auth(radarrHandler.Refresh)))
+	http.HandleFunc("/api/radarr/refresh", middleware.EnableCORS(middleware.RequirePermission(models.PermissionArrRefresh, radarrHandler.Refresh)))
 
 	// Sonarr routes
-	http.HandleFunc("/api/sonarr/series", middleware.EnableCORS(middleware.Auth(sonarrHandler.AddSeries)))
-	http.HandleFunc("/api/sonarr/queue", middleware.EnableCORS(middleware.Auth(sonarrHandler.GetQueue)))
+	http.HandleFunc("/api/sonarr/series", middleware.EnableCORS(middleware.RequirePermission(models.PermissionRequestsManage, sonarrHandler.AddSeries)))
+	http.HandleFunc("/api/sonarr/queue", middleware.EnableCORS(middleware.RequirePermission(models.PermissionDownloadsView, sonarrHandler.GetQueue)))
 	http.HandleFunc("/api/sonarr/allseries", middleware.EnableCORS(middleware.Auth(sonarrHandler.GetSeries)))
 	http.HandleFunc("/api/sonarr/rootfolders", middleware.EnableCORS(middleware.Auth(sonarrHandler.GetRootFolders)))
-	http.HandleFunc("/api/sonarr/refresh", middleware.EnableCORS(middleware.Auth(sonarrHandler.Refresh)))
+	http.HandleFunc("/api/sonarr/refresh", middleware.EnableCORS(middleware.RequirePermission(models.PermissionArrRefresh, sonarrHandler.Refresh)))
 	http.HandleFunc("/api/sonarr/lookup", middleware.EnableCORS(middleware.Auth(sonarrHandler.Lookup)))
 
 	// TMDB TV routes (order matters - most specific first)
@@ -107,39 +345,98 @@ func Setup(cfg *config.Config) {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"message":        "JellyStreaming API",
 			"version":        "2.2.0",
-			"authentication": "JWT token required for most endpoints",
+			"authentication": "JWT token (or an API key in X-Api-Key) required for most endpoints",
 			"endpoints": map[string]string{
-				"/health":                     "GET - Health check",
-				"/api/auth/login":             "POST - Login with username/password",
-				"/api/auth/verify":            "GET - Verify JWT token (requires auth)",
-				"/api/auth/me":                "GET - Get current user info (requires auth)",
-				"/api/auth/change-password":   "POST - Change own password (requires auth)",
-				"/api/users":                  "GET/POST - List or create users (admin only)",
-				"/api/users/:id":              "PUT/DELETE - Update or delete user (admin only)",
-				"/api/jellyfin/movies":        "GET - Fetch movies from Jellyfin (requires auth)",
-				"/api/jellyfin/movies/search": "GET - Search movie in Jellyfin (requires auth)",
-				"/api/jellyfin/series":        "GET - Fetch TV shows from Jellyfin (requires auth)",
-				"/api/config":                 "GET - Get Jellyfin configuration (requires auth)",
-				"/api/tmdb/trending":          "GET - Get trending movies from TMDB (requires auth)",
-				"/api/tmdb/popular":           "GET - Get popular movies from TMDB (requires auth)",
-				"/api/tmdb/movie":             "GET - Get movie details from TMDB (requires auth)",
-				"/api/tmdb/genres":            "GET - Get movie genres from TMDB (requires auth)",
-				"/api/tmdb/discover":          "GET - Discover movies from TMDB (requires auth)",
-				"/api/tmdb/search":            "GET - Search movies from TMDB (requires auth)",
-				"/api/tmdb/tv/trending":       "GET - Get trending TV shows from TMDB (requires auth)",
-				"/api/tmdb/tv/popular":        "GET - Get popular TV shows from TMDB (requires auth)",
-				"/api/tmdb/tv":                "GET - Get TV show details from TMDB (requires auth)",
-				"/api/tmdb/tv/search":         "GET - Search TV shows from TMDB (requires auth)",
-				"/api/radarr/movie":           "POST - Add movie to Radarr (requires auth)",
-				"/api/radarr/queue":           "GET - Get Radarr download queue (requires auth)",
-				"/api/radarr/movies":          "GET - Get all movies in Radarr (requires auth)",
-				"/api/radarr/rootfolders":     "GET - Get Radarr root folders (requires auth)",
-				"/api/radarr/refresh":         "POST - Refresh Radarr monitored downloads (requires auth)",
-				"/api/sonarr/series":          "POST - Add TV show to Sonarr (requires auth)",
-				"/api/sonarr/queue":           "GET - Get Sonarr download queue (requires auth)",
-				"/api/sonarr/allseries":       "GET - Get all TV shows in Sonarr (requires auth)",
-				"/api/sonarr/rootfolders":     "GET - Get Sonarr root folders (requires auth)",
-				"/api/sonarr/refresh":         "POST - Refresh Sonarr monitored downloads (requires auth)",
+				"/health":                                             "GET - Health check",
+				"/api/auth/login":                                     "POST - Login with username/password",
+				"/api/auth/verify":                                    "GET - Verify JWT token (requires auth)",
+				"/api/auth/me":                                        "GET - Get current user info (requires auth)",
+				"/api/auth/me/quota":                                  "GET - Request quota, usage and reset times for the current user (requires auth)",
+				"/api/auth/me/notifications":                          "GET/PUT - Notification channels: inbox, email, webhook, discord, ntfy, gotify (external ones need notifications.webhooks)",
+				"/api/auth/me/notifications/test":                     "POST - Send a test notification to the given or saved channels (requires auth)",
+				"/api/auth/change-password":                           "POST - Change own password (requires auth)",
+				"/api/auth/refresh":                                   "POST - Exchange a refresh token for a new token pair",
+				"/api/auth/logout":                                    "POST - Revoke the current session (requires auth)",
+				"/api/auth/register":                                  "POST - Create an account with an invite code",
+				"/api/auth/sessions":                                  "GET/DELETE - List own sessions or log out everywhere (requires auth)",
+				"/api/auth/sessions/:id":                              "DELETE - Revoke one of own sessions (requires auth)",
+				"/api/auth/api-keys":                                  "GET/POST - List or create own API keys with scopes (requires auth)",
+				"/api/auth/api-keys/:id":                              "DELETE - Revoke one of own API keys (requires auth)",
+				"/api/auth/oidc/config":                               "GET - Check whether single sign-on is available",
+				"/api/auth/oidc/login":                                "GET - Start single sign-on (browser redirect)",
+				"/api/auth/oidc/callback":                             "GET - Single sign-on redirect target",
+				"/api/auth/mfa/verify":                                "POST - Complete a login with a TOTP or recovery code",
+				"/api/auth/mfa/setup":                                 "POST - Start two-factor enrollment (requires auth)",
+				"/api/auth/mfa/enable":                                "POST - Confirm two-factor enrollment with a code (requires auth)",
+				"/api/auth/mfa/disable":                               "POST - Disable two-factor authentication {password, code}; password is not needed for accounts without one (requires auth)",
+				"/api/auth/mfa/recovery-codes":                        "POST - Regenerate recovery codes (requires auth)",
+				"/api/users":                                          "GET/POST - List or create users (users.manage)",
+				"/api/users/:id":                                      "PUT/DELETE - Update (incl. resetMfa, jellyfinUserId, roles, quota) or delete user (users.manage)",
+				"/api/roles":                                          "GET/POST - List or create roles (users.manage)",
+				"/api/roles/:id":                                      "PUT/DELETE - Update or delete a role; users who lose a permission are signed out (users.manage)",
+				"/api/roles/permissions":                              "GET - List available permissions (users.manage)",
+				"/api/invites":                                        "GET/POST - List (?status=) or create invite codes (users.manage)",
+				"/api/invites/:id":                                    "GET/DELETE - Invite details with registered users, or revoke (users.manage); 409 if already revoked",
+				"/api/security/lockouts":                              "GET/DELETE - List login lockouts or unlock ?username=/?ip= (users.manage)",
+				"/api/security/failed-logins":                         "GET - Query failed logins by username, ip, reason, since (users.manage)",
+				"/api/audit":                                          "GET - Query audit events by actor, action, targetType, targetId, ip, since, until (audit.view)",
+				"/api/audit/export":                                   "GET - Export matching audit events as ?format=csv or json (audit.view)",
+				"/api/requests":                                       "GET/POST - List requests (own, or all with requests.manage; ?mine, status, mediaType) or request a movie/show (request.movie/request.tv)",
+				"/api/requests/:id":                                   "GET/DELETE - Request details, or cancel a pending request (requester or requests.manage)",
+				"/api/requests/:id/approve":                           "POST - Approve and add to Radarr/Sonarr, optional qualityProfileId/rootFolderPath (requests.manage)",
+				"/api/requests/:id/decline":                           "POST - Decline with a reason (requests.manage)",
+				"/api/availability":                                   "GET - Status of a title by TMDB ID: available, downloading, monitored, requested or not_available (?tmdbId=&type=movie|tv)",
+				"/api/notifications":                                  "GET - Current user's inbox with total and unread counts (?unread=true&limit=&skip=)",
+				"/api/notifications/unread-count":                     "GET - Number of unread inbox items (requires auth)",
+				"/api/notifications/read-all":                         "POST - Mark every inbox item as read (requires auth)",
+				"/api/notifications/stream":                           "GET - Server-Sent Events: unread counts and new inbox items (access_token query param allowed)",
+				"/api/notifications/broadcast":                        "POST - Send an announcement to everyone or to a role (notifications.broadcast)",
+				"/api/notifications/:id":                              "DELETE - Delete an inbox item (requires auth)",
+				"/api/notifications/:id/read":                         "POST - Mark an inbox item as read (requires auth)",
+				"/api/webhooks/radarr":                                "POST - Radarr Connect webhook (shared secret)",
+				"/api/webhooks/sonarr":                                "POST - Sonarr Connect webhook (shared secret)",
+				"/api/webhooks/jellyfin":                              "POST - Jellyfin Webhook plugin: ItemAdded, PlaybackStart, PlaybackStop, UserDataSaved (shared token)",
+				"/api/jellyfin/movies":                                "GET - Fetch movies from the enabled Jellyfin libraries, or one (?libraryId=&page=&limit=&cursor=&sortBy=name|dateAdded|year|rating|runtime&sortOrder=asc|desc&genre=&yearFrom=&yearTo=&officialRating=&watched=&container=)",
+				"/api/jellyfin/movies/search":                         "GET - Search movie in Jellyfin (requires auth)",
+				"/api/jellyfin/series":                                "GET - Fetch TV shows from Jellyfin (same paging, sorting and filters as movies, except container)",
+				"/api/jellyfin/series/:id/seasons":                    "GET - Seasons of a series with the caller's watch state (requires auth)",
+				"/api/jellyfin/series/:id/seasons/:seasonId/episodes": "GET - Episodes of a season with the caller's watch state (requires auth)",
+				"/api/jellyfin/series/:id/next-episode":               "GET - Next up for the caller, or the episode after ?after={episodeId} (requires auth)",
+				"/api/playback/start":                                 "POST - Report playback start {itemId, mediaSourceId?, playSessionId?, positionTicks, playMethod?}; returns the resume position. Accounts without a linked Jellyfin user keep their progress here, which needs JELLYFIN_USER_ID; 403 otherwise",
+				"/api/playback/progress":                              "POST - Report the current position (send every few seconds and on pause)",
+				"/api/playback/stop":                                  "POST - Report playback stop; marks the item played past 90%",
+				"/api/me/continue-watching":                           "GET - Movies and episodes the caller started but did not finish (?limit=, default 20)",
+				"/api/me/next-up":                                     "GET - Next episode of each series the caller is watching (?limit=, default 20)",
+				"/api/watchlist":                                      "GET - Caller's watchlist, each entry resolved to its Jellyfin item and request state; POST - Add {mediaType, tmdbId, title, year?, posterPath?}",
+				"/api/watchlist/:mediaType/:tmdbId":                   "DELETE - Remove a title from the caller's watchlist",
+				"/api/watchlist/order":                                "PUT - Reorder the watchlist {items: [{mediaType, tmdbId}]}; unlisted entries follow",
+				"/api/watchlist/settings":                             "PUT - {autoRequest}: request listed titles the library does not have, now and when added",
+				"/api/jellyfin/libraries":                             "GET - Enabled libraries the caller can see, or every library with ?all=true; PUT - Set movieLibraries/tvLibraries (libraries.manage)",
+				"/api/jellyfin/users":                                 "GET - List Jellyfin users and their linked accounts (users.manage)",
+				"/api/config":                                         "GET - Get Jellyfin configuration (requires auth)",
+				"/api/stream/:itemId/playbackinfo":                    "GET/POST - Playback info with proxied media URLs (requires auth)",
+				"/api/stream/:itemId/images/:type":                    "GET - Item artwork",
+				"/api/stream/:itemId/*":                               "GET - HLS playlists, segments, subtitles and direct streams via Jellyfin (requires auth or stream token)",
+				"/api/tmdb/trending":                                  "GET - Get trending movies from TMDB; ?enrich=true adds library, download and request status (requires auth)",
+				"/api/tmdb/popular":                                   "GET - Get popular movies from TMDB; ?enrich=true adds library, download and request status (requires auth)",
+				"/api/tmdb/movie":                                     "GET - Get movie details from TMDB (requires auth)",
+				"/api/tmdb/genres":                                    "GET - Get movie genres from TMDB (requires auth)",
+				"/api/tmdb/discover":                                  "GET - Discover movies from TMDB; ?enrich=true adds library, download and request status (requires auth)",
+				"/api/tmdb/search":                                    "GET - Search movies from TMDB; ?enrich=true adds library, download and request status (requires auth)",
+				"/api/tmdb/tv/trending":                               "GET - Get trending TV shows from TMDB; ?enrich=true adds library, download and request status (requires auth)",
+				"/api/tmdb/tv/popular":                                "GET - Get popular TV shows from TMDB; ?enrich=true adds library, download and request status (requires auth)",
+				"/api/tmdb/tv":                                        "GET - Get TV show details from TMDB (requires auth)",
+				"/api/tmdb/tv/search":                                 "GET - Search TV shows from TMDB; ?enrich=true adds library, download and request status (requires auth)",
+				"/api/radarr/movie":                                   "POST - Add movie to Radarr directly (requests.manage, request.4k for 4K profiles)",
+				"/api/radarr/queue":                                   "GET - Get Radarr download queue (downloads.view)",
+				"/api/radarr/movies":                                  "GET - Get all movies in Radarr (requires auth)",
+				"/api/radarr/rootfolders":                             "GET - Get Radarr root folders (requires auth)",
+				"/api/radarr/refresh":                                 "POST - Refresh Radarr monitored downloads (arr.refresh)",
+				"/api/sonarr/series":                                  "POST - Add TV show to Sonarr directly (requests.manage, request.4k for 4K profiles)",
+				"/api/sonarr/queue":                                   "GET - Get Sonarr download queue (downloads.view)",
+				"/api/sonarr/allseries":                               "GET - Get all TV shows in Sonarr (requires auth)",
+				"/api/sonarr/rootfolders":                             "GET - Get Sonarr root folders (requires auth)",
+				"/api/sonarr/refresh":                                 "POST - Refresh Sonarr monitored downloads (arr.refresh)",
 			},
 		})
 	}))
@@ -1,15 +1,16 @@
 import React from 'react';
+import { streamApi } from '../services/api';
 import './MovieList.css';
 
 const MovieList = ({ movies, onMovieClick, config }) => {
   const getImageUrl = (movie) => {
     if (!config || !movie.ImageTags?.Primary) return null;
-    return `${config.jellyfinUrl}/Items/${movie.Id}/Images/Primary?api_key=${config.apiKey}`;
+    return streamApi.getImageUrl(movie.Id, 'Primary');
   };
 
   const getBackdropUrl = (movie) => {
     if (!config || !movie.BackdropImageTags?.[0]) return null;
-    return `${config.jellyfinUrl}/Items/${movie.Id}/Images/Backdrop?api_key=${config.apiKey}`;
+    return streamApi.getImageUrl(movie.Id, 'Backdrop');
   };
 
   const formatRuntime = (ticks) => {
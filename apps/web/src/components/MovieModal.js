@@ -1,8 +1,10 @@
 import React, { useEffect, useState } from 'react';
-import { tmdbApi, jellyfinApi, radarrApi } from '../services/api';
+import { tmdbApi, radarrApi, requestsApi, availabilityApi, watchlistApi } from '../services/api';
+import { useAuth } from '../context/AuthContext';
 import '../styles/MovieModal.css';
 
 const MovieModal = ({ movie, onClose, onPlay }) => {
+  const { hasPermission } = useAuth();
   const [jellyfinMovie, setJellyfinMovie] = useState(null);
   const [checkingJellyfin, setCheckingJellyfin] = useState(true);
   const [downloading, setDownloading] = useState(false);
@@ -11,6 +13,7 @@ const MovieModal = ({ movie, onClose, onPlay }) => {
   const [queueItem, setQueueItem] = useState(null);
   const [downloadProgress, setDownloadProgress] = useState(0);
   const [justAdded, setJustAdded] = useState(false);
+  const [onWatchlist, setOnWatchlist] = useState(false);
 
   useEffect(() => {
     const handleEscape = (e) => {
@@ -36,113 +39,21 @@ const MovieModal = ({ movie, onClose, onPlay }) => {
       try {
         if (!isMounted) return;
         setCheckingJellyfin(true);
-        
-        // Check Jellyfin
-        const movieTitle = movie.title || movie.name;
-        const results = await jellyfinApi.searchMovie(movieTitle);
-        
-        if (results && results.length > 0) {
-          // PRIORITY 1: Match by TMDB ID (most reliable)
-          const tmdbId = movie.id?.toString();
-          let bestMatch = null;
-          
-          if (tmdbId) {
-            bestMatch = results.find(m => m.ProviderIds?.Tmdb === tmdbId);
-          }
-          
-          // FALLBACK: If no TMDB ID match, use title + year matching
-          if (!bestMatch) {
-            const normalizeTitle = (title) => {
-              return title
-                .toLowerCase()
-                .replace(/[:\-–—]/g, '') // Remove colons, hyphens, dashes
-                .replace(/\s+/g, ' ')     // Normalize spaces
-                .trim();
-            };
-            
-            const normalizedSearchTitle = normalizeTitle(movieTitle);
-            const movieYear = movie.release_date ? new Date(movie.release_date).getFullYear() : null;
-            
-            // PRIORITY 2: Exact title + year match
-            if (movieYear) {
-              bestMatch = results.find(
-                m => m.ProductionYear === movieYear && 
-                     m.Name.toLowerCase() === movieTitle.toLowerCase()
-              );
-            }
-            
-            // PRIORITY 3: Normalized title + year match
-            if (!bestMatch && movieYear) {
-              bestMatch = results.find(
-                m => m.ProductionYear === movieYear && 
-                     normalizeTitle(m.Name) === normalizedSearchTitle
-              );
-            }
-            
-            // PRIORITY 4: Year match with partial title match
-            if (!bestMatch && movieYear) {
-              bestMatch = results.find(
-                m => m.ProductionYear === movieYear && 
-                     normalizeTitle(m.Name).includes(normalizedSearchTitle.split(' ')[0])
-              );
-            }
-            
-            // PRIORITY 5: Exact title match (no year check) - only if no year available
-            if (!bestMatch && !movieYear) {
-              bestMatch = results.find(
-                m => m.Name.toLowerCase() === movieTitle.toLowerCase()
-              );
-            }
-            
-            // PRIORITY 6: Normalized title match (no year check) - only if no year available
-            if (!bestMatch && !movieYear) {
-              bestMatch = results.find(
-                m => normalizeTitle(m.Name) === normalizedSearchTitle
-              );
-            }
-          }
-          
-          // If we found a match, use it; otherwise, no match found
-          setJellyfinMovie(bestMatch || null);
-          
-          // Log for debugging
-          console.log('Search results for:', movieTitle, movie.release_date ? `(${new Date(movie.release_date).getFullYear()})` : '', `[TMDB: ${tmdbId}]`);
-          console.log('Found movies:', results.map(m => ({ name: m.Name, year: m.ProductionYear, tmdb: m.ProviderIds?.Tmdb })));
-          console.log('Best match:', bestMatch ? `${bestMatch.Name} (${bestMatch.ProductionYear}) [TMDB: ${bestMatch.ProviderIds?.Tmdb}]` : 'No match found');
-        } else {
-          setJellyfinMovie(null);
+
+        const availability = await availabilityApi.get(movie.id, 'movie');
+        if (!isMounted) return;
+
+        setJellyfinMovie(availability.jellyfin || null);
+        setRadarrMovie(availability.radarr || null);
+        setQueueItem(availability.download || null);
+        if (availability.download) {
+          setDownloadProgress(Math.max(0, Math.min(100, availability.download.progress)));
         }
       } catch (error) {
-        console.error('Error checking Jellyfin library:', error);
+        console.error('Error checking availability:', error);
         setJellyfinMovie(null);
       } finally {
-        if (!isMounted) return;
-        setCheckingJellyfin(false);
-      }
-      
-      // Check Radarr for this movie
-      try {
-        const radarrMovies = await radarrApi.getMovies();
-        const radarrMatch = radarrMovies.find(m => m.tmdbId === movie.id);
-        
-        if (!isMounted) return;
-        setRadarrMovie(radarrMatch || null);
-        
-        // If movie is in Radarr, check queue for download status
-        if (radarrMatch) {
-          const queue = await radarrApi.getQueue();
-          const queueMatch = queue.records?.find(q => q.movieId === radarrMatch.id);
-          
-          if (!isMounted) return;
-          setQueueItem(queueMatch || null);
-          
-          if (queueMatch && queueMatch.size > 0) {
-            const progress = ((queueMatch.size - queueMatch.sizeleft) / queueMatch.size) * 100;
-            setDownloadProgress(Math.max(0, Math.min(100, progress)));
-          }
-        }
-      } catch (error) {
-        console.error('Error checking Radarr:', error);
+        if (isMounted) setCheckingJellyfin(false);
       }
     };
 
@@ -153,101 +64,47 @@ const MovieModal = ({ movie, onClose, onPlay }) => {
     };
   }, [movie]);
   
+  useEffect(() => {
+    let isMounted = true;
+    watchlistApi.get()
+      .then((watchlist) => {
+        if (isMounted) {
+          setOnWatchlist(watchlist.items.some((item) => item.mediaType === 'movie' && item.tmdbId === movie.id));
+        }
+      })
+      .catch(() => {});
+    return () => {
+      isMounted = false;
+    };
+  }, [movie.id]);
+
   // Separate useEffect for polling that doesn't depend on radarrMovie/jellyfinMovie
   useEffect(() => {
     if (!radarrMovie || jellyfinMovie) {
       return; // Don't poll if not in Radarr or already in Jellyfin
     }
     
-    // Use refs to track current values without triggering re-renders
-    const radarrMovieIdRef = { current: radarrMovie.id };
-    
     const pollQueue = async () => {
       try {
         // Trigger Radarr to refresh download status
         await radarrApi.refreshMonitoredDownloads();
-        
-        // First, refresh the Radarr movie data to get updated hasFile status
-        const radarrMovies = await radarrApi.getMovies();
-        const updatedRadarrMovie = radarrMovies.find(m => m.id === radarrMovieIdRef.current);
-        
-        if (updatedRadarrMovie) {
-          // Update radarrMovie state only if hasFile changed
-          if (updatedRadarrMovie.hasFile !== radarrMovie.hasFile) {
-            setRadarrMovie(updatedRadarrMovie);
-          }
-          
-          // If movie now has a file, check Jellyfin with full matching logic
-          if (updatedRadarrMovie.hasFile) {
-            const movieTitle = movie.title || movie.name;
-            const results = await jellyfinApi.searchMovie(movieTitle);
-            
-            if (results && results.length > 0) {
-              // PRIORITY 1: Match by TMDB ID (most reliable)
-              const tmdbId = movie.id?.toString();
-              let bestMatch = null;
-              
-              if (tmdbId) {
-                bestMatch = results.find(m => m.ProviderIds?.Tmdb === tmdbId);
-              }
-              
-              // FALLBACK: If no TMDB ID match, use title + year matching
-              if (!bestMatch) {
-                const normalizeTitle = (title) => {
-                  return title.toLowerCase().replace(/[:\-–—]/g, '').replace(/\s+/g, ' ').trim();
-                };
-                const normalizedSearchTitle = normalizeTitle(movieTitle);
-                const movieYear = movie.release_date ? new Date(movie.release_date).getFullYear() : null;
-                
-                // PRIORITY 2: Exact title + year match
-                if (movieYear) {
-                  bestMatch = results.find(m => m.ProductionYear === movieYear && m.Name.toLowerCase() === movieTitle.toLowerCase());
-                }
-                
-                // PRIORITY 3: Normalized title + year match
-                if (!bestMatch && movieYear) {
-                  bestMatch = results.find(m => m.ProductionYear === movieYear && normalizeTitle(m.Name) === normalizedSearchTitle);
-                }
-                
-                // PRIORITY 4: Year match with partial title match
-                if (!bestMatch && movieYear) {
-                  bestMatch = results.find(
-                    m => m.ProductionYear === movieYear && 
-                         normalizeTitle(m.Name).includes(normalizedSearchTitle.split(' ')[0])
-                  );
-                }
-                
-                // PRIORITY 5: Exact title match (no year check) - only if no year available
-                if (!bestMatch && !movieYear) {
-                  bestMatch = results.find(m => m.Name.toLowerCase() === movieTitle.toLowerCase());
-                }
-                
-                // PRIORITY 6: Normalized title match (no year check) - only if no year available
-                if (!bestMatch && !movieYear) {
-                  bestMatch = results.find(m => normalizeTitle(m.Name) === normalizedSearchTitle);
-                }
-              }
-              
-              if (bestMatch) {
-                setJellyfinMovie(bestMatch);
-                return; // Stop this poll cycle, interval will be cleared by useEffect cleanup
-              }
-            }
-          }
+
+        const availability = await availabilityApi.get(movie.id, 'movie');
+
+        // Update radarrMovie state only if hasFile changed
+        if (availability.radarr && availability.radarr.hasFile !== radarrMovie.hasFile) {
+          setRadarrMovie(availability.radarr);
         }
-        
-        // Check the queue
-        const queue = await radarrApi.getQueue();
-        const queueMatch = queue.records?.find(q => q.movieId === radarrMovieIdRef.current);
-        
+
+        if (availability.jellyfin) {
+          setJellyfinMovie(availability.jellyfin);
+          return; // Stop this poll cycle, interval will be cleared by useEffect cleanup
+        }
+
         // Always update queueItem to trigger re-render (create new object reference)
-        if (queueMatch) {
-          setQueueItem({ ...queueMatch });
-          
-          if (queueMatch.size > 0) {
-            const progress = ((queueMatch.size - queueMatch.sizeleft) / queueMatch.size) * 100;
-            setDownloadProgress(Math.max(0, Math.min(100, progress)));
-          }
+        if (availability.download) {
+          setQueueItem({ ...availability.download });
+          setDownloadProgress(Math.max(0, Math.min(100, availability.download.progress)));
         } else {
           setQueueItem(null);
         }
@@ -275,13 +132,52 @@ const MovieModal = ({ movie, onClose, onPlay }) => {
     return hours > 0 ? `${hours}h ${mins}m` : `${mins}m`;
   };
 
-  const handleAddToList = () => {
-    alert('Add to list functionality to be implemented');
+  const handleAddToList = async () => {
+    try {
+      if (onWatchlist) {
+        await watchlistApi.remove('movie', movie.id);
+        setOnWatchlist(false);
+        return;
+      }
+
+      const item = await watchlistApi.add({
+        mediaType: 'movie',
+        tmdbId: movie.id,
+        title: movie.title || movie.name,
+        year: movie.release_date ? new Date(movie.release_date).getFullYear() : 0,
+        posterPath: movie.poster_path || '',
+      });
+      setOnWatchlist(true);
+      if (item.requestError) {
+        alert(`Added to My List, but it could not be requested: ${item.requestError}`);
+      }
+    } catch (error) {
+      if (error.status === 409) {
+        setOnWatchlist(true);
+        return;
+      }
+      alert(`Failed to update My List: ${error.message}`);
+    }
   };
 
   const handleDownload = async () => {
     try {
       setDownloading(true);
+
+      // Without requests.manage the movie goes through the request queue
+      if (!hasPermission('requests.manage')) {
+        const request = await requestsApi.create({
+          mediaType: 'movie',
+          tmdbId: movie.id,
+          title: movie.title || movie.name,
+          year: movie.release_date ? new Date(movie.release_date).getFullYear() : 0,
+          posterPath: movie.poster_path || '',
+        });
+        alert(request.status === 'approved'
+          ? 'Request approved, the movie is being added.'
+          : 'Request sent! An admin will review it.');
+        return;
+      }
       
       // Get root folders to use the first one
       const rootFolders = await radarrApi.getRootFolders();
@@ -327,7 +223,7 @@ const MovieModal = ({ movie, onClose, onPlay }) => {
       console.log('Movie added to Radarr successfully');
     } catch (error) {
       console.error('Error adding movie to Radarr:', error);
-      alert('Failed to add movie to download queue. Please try again.');
+      alert('Failed to add movie: ' + error.message);
     } finally {
       setDownloading(false);
     }
@@ -547,7 +443,7 @@ const MovieModal = ({ movie, onClose, onPlay }) => {
 
           <div className="modal-secondary">
             <button className="btn-text" onClick={handleAddToList}>
-              + Add to My List
+              {onWatchlist ? '✓ On My List' : '+ Add to My List'}
             </button>
             <button className="btn-text" onClick={handleShare}>
               Share
@@ -1,15 +1,21 @@
 import React, { useState, useEffect, useRef } from 'react';
 import { Link, useLocation, useNavigate } from 'react-router-dom';
 import { useAuth } from '../context/AuthContext';
+import { notificationsApi } from '../services/api';
+import { refreshAccessToken } from '../utils/api';
 import '../styles/Navbar.css';
 
 const Navbar = () => {
   const [scrolled, setScrolled] = useState(false);
   const [showDropdown, setShowDropdown] = useState(false);
+  const [showInbox, setShowInbox] = useState(false);
+  const [unread, setUnread] = useState(0);
+  const [notifications, setNotifications] = useState([]);
   const location = useLocation();
   const navigate = useNavigate();
   const { user, logout, isAdmin } = useAuth();
   const dropdownRef = useRef(null);
+  const inboxRef = useRef(null);
 
   useEffect(() => {
     const handleScroll = () => {
@@ -25,12 +31,102 @@ const Navbar = () => {
       if (dropdownRef.current && !dropdownRef.current.contains(event.target)) {
         setShowDropdown(false);
       }
+      if (inboxRef.current && !inboxRef.current.contains(event.target)) {
+        setShowInbox(false);
+      }
     };
 
     document.addEventListener('mousedown', handleClickOutside);
     return () => document.removeEventListener('mousedown', handleClickOutside);
   }, []);
 
+  // Live unread count and new notifications over Server-Sent Events
+  useEffect(() => {
+    if (!user) return undefined;
+
+    let source = null;
+    let retryTimer = null;
+    let closed = false;
+
+    const connect = () => {
+      source = notificationsApi.stream();
+      source.addEventListener('unread', (event) => {
+        setUnread(JSON.parse(event.data).count);
+      });
+      source.addEventListener('notification', (event) => {
+        const notification = JSON.parse(event.data);
+        setNotifications((current) => [notification, ...current.filter((n) => n.id !== notification.id)].slice(0, 20));
+      });
+      source.onerror = async () => {
+        // The browser reconnects by itself unless the server refused the
+        // stream, which usually means the access token expired
+        if (source.readyState !== EventSource.CLOSED || closed) return;
+        await refreshAccessToken();
+        if (!closed) retryTimer = setTimeout(connect, 5000);
+      };
+    };
+
+    connect();
+    return () => {
+      closed = true;
+      clearTimeout(retryTimer);
+      if (source) source.close();
+    };
+  }, [user]);
+
+  const toggleInbox = async () => {
+    const open = !showInbox;
+    setShowInbox(open);
+    if (open) {
+      try {
+        const data = await notificationsApi.list({ limit: 20 });
+        setNotifications(data.items);
+        setUnread(data.unread);
+      } catch (error) {
+        // The previous list stays visible
+      }
+    }
+  };
+
+  const handleNotificationClick = async (notification) => {
+    if (!notification.read) {
+      setNotifications((current) => current.map((n) => (n.id === notification.id ? { ...n, read: true } : n)));
+      try {
+        await notificationsApi.markRead(notification.id);
+      } catch (error) {
+        // The stream corrects the badge if this failed
+      }
+    }
+    if (notification.link) {
+      setShowInbox(false);
+      if (notification.link.startsWith('/')) {
+        navigate(notification.link);
+      } else {
+        window.open(notification.link, '_blank', 'noopener');
+      }
+    }
+  };
+
+  const handleMarkAllRead = async () => {
+    try {
+      await notificationsApi.markAllRead();
+      setNotifications((current) => current.map((n) => ({ ...n, read: true })));
+      setUnread(0);
+    } catch (error) {
+      // Leave the list unchanged
+    }
+  };
+
+  const handleDeleteNotification = async (event, id) => {
+    event.stopPropagation();
+    try {
+      await notificationsApi.remove(id);
+      setNotifications((current) => current.filter((n) => n.id !== id));
+    } catch (error) {
+      // Leave the list unchanged
+    }
+  };
+
   const isActive = (path) => {
     return location.pathname === path;
   };
@@ -71,6 +167,57 @@ const Navbar = () => {
             </Link>
           </li>
         </ul>
+        <div className="nav-inbox" ref={inboxRef}>
+          <button
+            className="inbox-button"
+            onClick={toggleInbox}
+            aria-label={`Notifications${unread > 0 ? ` (${unread} unread)` : ''}`}
+          >
+            <span className="inbox-icon">🔔</span>
+            {unread > 0 && (
+              <span className="inbox-badge">{unread > 99 ? '99+' : unread}</span>
+            )}
+          </button>
+          {showInbox && (
+            <div className="inbox-dropdown">
+              <div className="inbox-header">
+                <span>Notifications</span>
+                {unread > 0 && (
+                  <button className="inbox-mark-all" onClick={handleMarkAllRead}>
+                    Mark all read
+                  </button>
+                )}
+              </div>
+              <div className="dropdown-divider"></div>
+              {notifications.length === 0 ? (
+                <div className="inbox-empty">No notifications</div>
+              ) : (
+                <ul className="inbox-list">
+                  {notifications.map((notification) => (
+                    <li
+                      key={notification.id}
+                      className={`inbox-item ${notification.read ? '' : 'unread'}`}
+                      onClick={() => handleNotificationClick(notification)}
+                    >
+                      <div className="inbox-item-title">{notification.title}</div>
+                      <div className="inbox-item-body">{notification.body}</div>
+                      <div className="inbox-item-meta">
+                        {new Date(notification.createdAt).toLocaleString()}
+                        <button
+                          className="inbox-item-delete"
+                          onClick={(event) => handleDeleteNotification(event, notification.id)}
+                          aria-label="Delete notification"
+                        >
+                          ✕
+                        </button>
+                      </div>
+                    </li>
+                  ))}
+                </ul>
+              )}
+            </div>
+          )}
+        </div>
         <div className="nav-user" ref={dropdownRef}>
           <button 
             className="user-button"
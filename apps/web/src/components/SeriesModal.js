@@ -1,8 +1,10 @@
 import React, { useEffect, useState } from 'react';
-import { tmdbTVApi, jellyfinTVApi, jellyfinApi, sonarrApi } from '../services/api';
+import { tmdbTVApi, jellyfinTVApi, sonarrApi, requestsApi, availabilityApi } from '../services/api';
+import { useAuth } from '../context/AuthContext';
 import '../styles/MovieModal.css'; // Reuse movie modal styles for now
 
 const SeriesModal = ({ series, onClose, onPlay }) => {
+  const { hasPermission } = useAuth();
   const [tvDetails, setTvDetails] = useState(null);
   const [loading, setLoading] = useState(true);
   const [sonarrSeries, setSonarrSeries] = useState(null);
@@ -42,66 +44,23 @@ const SeriesModal = ({ series, onClose, onPlay }) => {
         if (isTMDBSeries) {
           setTvDetails(series);
           
-          // Fetch external IDs from TMDB to get TVDB ID
+          // Resolve TVDB ID, Jellyfin item, Sonarr series and queue in one call
           try {
-            const response = await fetch(`/api/tmdb/tv/${series.id}/external_ids`);
-            const externalIds = await response.json();
-            console.log('External IDs from TMDB:', externalIds);
-            if (externalIds.tvdb_id) {
-              console.log('Found TVDB ID:', externalIds.tvdb_id);
-              setTvdbId(externalIds.tvdb_id);
-            } else {
-              console.warn('No TVDB ID found in external IDs');
+            const availability = await availabilityApi.get(series.id, 'tv');
+            if (availability.tvdbId) {
+              setTvdbId(availability.tvdbId);
             }
-          } catch (error) {
-            console.error('Error fetching TVDB ID:', error);
-          }
-          
-          // Try to find in Jellyfin by TMDB ID
-          let foundInJellyfin = false;
-          try {
-            const jellyfinSeriesList = await jellyfinTVApi.getSeries();
-            const matchingSeries = jellyfinSeriesList.find(s => 
-              s.ProviderIds?.Tmdb === series.id.toString()
-            );
-            if (matchingSeries) {
-              setJellyfinSeries(matchingSeries);
-              foundInJellyfin = true;
-            }
-          } catch (error) {
-            console.log('Series not in Jellyfin yet');
-          }
-          
-          // Check Sonarr by TMDB ID
-          try {
-            const allSonarrSeries = await sonarrApi.getSeries();
-            const existingSeries = allSonarrSeries.find(s => 
-              s.tmdbId === series.id
-            );
-            setSonarrSeries(existingSeries);
-            
-            if (existingSeries) {
-              const queue = await sonarrApi.getQueue();
-              const seriesQueue = queue.records?.find(q => q.seriesId === existingSeries.id);
-              setQueueItem(seriesQueue);
-              
-              // If we haven't found the Jellyfin series yet, try by TVDB ID
-              if (!foundInJellyfin && existingSeries.tvdbId) {
-                try {
-                  const jellyfinSeriesList = await jellyfinTVApi.getSeries();
-                  const matchingByTvdb = jellyfinSeriesList.find(s => 
-                    s.ProviderIds?.Tvdb === existingSeries.tvdbId.toString()
-                  );
-                  if (matchingByTvdb) {
-                    setJellyfinSeries(matchingByTvdb);
-                  }
-                } catch (err) {
-                  console.log('Could not find in Jellyfin by TVDB:', err);
-                }
-              }
+            setJellyfinSeries(availability.jellyfin || null);
+            setQueueItem(availability.download || null);
+
+            // Season edits send the whole series back to Sonarr, so keep
+            // Sonarr's full record rather than the summary
+            if (availability.sonarr) {
+              const allSonarrSeries = await sonarrApi.getSeries();
+              setSonarrSeries(allSonarrSeries.find(s => s.id === availability.sonarr.id));
             }
           } catch (error) {
-            console.log('Error checking Sonarr:', error);
+            console.log('Error checking availability:', error);
           }
         } 
         // If it's a Jellyfin series
@@ -218,6 +177,28 @@ const SeriesModal = ({ series, onClose, onPlay }) => {
         isUpdate: !!sonarrSeries
       });
 
+      // Without requests.manage the show goes through the request queue
+      if (!hasPermission('requests.manage')) {
+        if (!tvdbId && !tmdbId) {
+          alert('Cannot find TVDB or TMDB ID for this series.');
+          return;
+        }
+        const firstAirDate = tvDetails?.first_air_date || series.PremiereDate;
+        const request = await requestsApi.create({
+          mediaType: 'tv',
+          tmdbId: tmdbId || 0,
+          tvdbId: tvdbId ? parseInt(tvdbId) : 0,
+          title: tvDetails?.name || series.Name || series.name,
+          year: firstAirDate ? new Date(firstAirDate).getFullYear() : series.ProductionYear,
+          posterPath: tvDetails?.poster_path || '',
+          seasons: selectedSeasons,
+        });
+        alert(request.status === 'approved'
+          ? 'Request approved, the show is being added.'
+          : 'Request sent! An admin will review it.');
+        return;
+      }
+
       // If updating existing series
       if (sonarrSeries) {
         // Determine which seasons should be monitored
@@ -393,12 +374,8 @@ const SeriesModal = ({ series, onClose, onPlay }) => {
         // Try to find the series in Jellyfin by TVDB ID
         const tvdbIdToSearch = sonarrSeries.tvdbId || tvdbId;
         if (tvdbIdToSearch) {
-          const config = await jellyfinApi.getConfig();
-          const response = await fetch(
-            `${config.jellyfinUrl}/Items?IncludeItemTypes=Series&Recursive=true&userId=${config.userId}&api_key=${config.apiKey}`
-          );
-          const data = await response.json();
-          const foundSeries = data.Items?.find(s => s.ProviderIds?.Tvdb == tvdbIdToSearch);
+          const allSeries = await jellyfinTVApi.getSeries();
+          const foundSeries = allSeries.find(s => s.ProviderIds?.Tvdb == tvdbIdToSearch);
           
           if (foundSeries) {
             onPlay(foundSeries);
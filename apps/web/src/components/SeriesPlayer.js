@@ -1,11 +1,13 @@
 import React, { useState, useEffect, useRef } from 'react';
 import Hls from 'hls.js';
-import { jellyfinApi } from '../services/api';
+import { jellyfinApi, jellyfinTVApi, streamApi } from '../services/api';
+import usePlaybackProgress from '../hooks/usePlaybackProgress';
 import '../styles/SeriesPlayer.css';
 
 const SeriesPlayer = ({ series, onClose }) => {
   const videoRef = useRef(null);
   const hlsRef = useRef(null);
+  const pendingEpisodeRef = useRef(null);
   const [config, setConfig] = useState(null);
   const [seasons, setSeasons] = useState([]);
   const [selectedSeason, setSelectedSeason] = useState(null);
@@ -21,6 +23,8 @@ const SeriesPlayer = ({ series, onClose }) => {
   const [selectedSubtitleTrack, setSelectedSubtitleTrack] = useState(-1);
   const [tracksLoaded, setTracksLoaded] = useState(false);
 
+  usePlaybackProgress(videoRef, selectedEpisode?.Id);
+
   useEffect(() => {
     const fetchConfig = async () => {
       try {
@@ -40,10 +44,7 @@ const SeriesPlayer = ({ series, onClose }) => {
 
       try {
         setLoading(true);
-        const response = await fetch(
-          `${config.jellyfinUrl}/Shows/${series.Id}/Seasons?userId=${config.userId}&api_key=${config.apiKey}`
-        );
-        const data = await response.json();
+        const data = await jellyfinTVApi.getSeasons(series.Id);
         
         const seasonList = data.Items || [];
         // Filter out specials (season 0) and sort by season number
@@ -72,16 +73,17 @@ const SeriesPlayer = ({ series, onClose }) => {
       if (!config || !selectedSeason) return;
 
       try {
-        const response = await fetch(
-          `${config.jellyfinUrl}/Shows/${series.Id}/Episodes?seasonId=${selectedSeason.Id}&userId=${config.userId}&api_key=${config.apiKey}`
-        );
-        const data = await response.json();
+        const data = await jellyfinTVApi.getEpisodes(series.Id, selectedSeason.Id);
         
         const episodeList = (data.Items || []).sort((a, b) => a.IndexNumber - b.IndexNumber);
         setEpisodes(episodeList);
         
-        // Auto-select first episode
-        if (episodeList.length > 0) {
+        // Select the episode queued by autoplay, otherwise the first one
+        const pending = episodeList.find(e => e.Id === pendingEpisodeRef.current);
+        pendingEpisodeRef.current = null;
+        if (pending) {
+          setSelectedEpisode(pending);
+        } else if (episodeList.length > 0) {
           setSelectedEpisode(episodeList[0]);
         }
       } catch (error) {
@@ -100,10 +102,7 @@ const SeriesPlayer = ({ series, onClose }) => {
       try {
         setTracksLoaded(false);
         console.log('Fetching media streams for episode:', selectedEpisode.Id);
-        const response = await fetch(
-          `${config.jellyfinUrl}/Items/${selectedEpisode.Id}/PlaybackInfo?UserId=${config.userId}&api_key=${config.apiKey}`
-        );
-        const data = await response.json();
+        const data = await streamApi.getPlaybackInfo(selectedEpisode.Id);
         
         if (data.MediaSources && data.MediaSources.length > 0) {
           const mediaSource = data.MediaSources[0];
@@ -190,7 +189,6 @@ const SeriesPlayer = ({ series, onClose }) => {
     const videoBitrate = getQualityBitrate(qualityLevel);
     
     const paramsObj = {
-      'api_key': config.apiKey,
       'DeviceId': deviceId,
       'MediaSourceId': episode.Id,
       
@@ -227,8 +225,7 @@ const SeriesPlayer = ({ series, onClose }) => {
       paramsObj['SubtitleMethod'] = 'Encode';
     }
     
-    const params = new URLSearchParams(paramsObj);
-    return `${config.jellyfinUrl}/videos/${episode.Id}/master.m3u8?${params.toString()}`;
+    return streamApi.getMediaUrl(episode.Id, 'master.m3u8', paramsObj);
   };
 
   const handleSeasonChange = (season) => {
@@ -240,6 +237,28 @@ const SeriesPlayer = ({ series, onClose }) => {
     setSelectedEpisode(episode);
   };
 
+  // Moves on to the next episode when one finishes, switching season if needed
+  const handleEpisodeEnded = async () => {
+    if (!selectedEpisode) return;
+    try {
+      const next = await jellyfinTVApi.getNextEpisode(series.Id, selectedEpisode.Id);
+      if (!next) return;
+
+      const inSeason = episodes.find(e => e.Id === next.Id);
+      if (inSeason) {
+        setSelectedEpisode(inSeason);
+        return;
+      }
+      const nextSeason = seasons.find(s => s.Id === next.SeasonId);
+      if (nextSeason) {
+        pendingEpisodeRef.current = next.Id;
+        setSelectedSeason(nextSeason);
+      }
+    } catch (error) {
+      console.error('Error fetching next episode:', error);
+    }
+  };
+
   const handleQualityChange = (newQuality) => {
     setQuality(newQuality);
     setShowSettings(false);
@@ -541,8 +560,9 @@ const SeriesPlayer = ({ series, onClose }) => {
                 ref={videoRef}
                 key={selectedEpisode.Id}
                 controls
+                onEnded={handleEpisodeEnded}
                 className="video-element"
-                poster={`${config.jellyfinUrl}/Items/${selectedEpisode.Id}/Images/Primary?api_key=${config.apiKey}`}
+                poster={streamApi.getImageUrl(selectedEpisode.Id, 'Primary')}
                 crossOrigin="anonymous"
                 playsInline
               >
@@ -1,5 +1,7 @@
 import React, { useRef, useEffect, useState } from 'react';
 import Hls from 'hls.js';
+import { streamApi } from '../services/api';
+import usePlaybackProgress from '../hooks/usePlaybackProgress';
 import './VideoPlayer.css';
 
 const VideoPlayer = ({ movie, config, onClose }) => {
@@ -15,15 +17,14 @@ const VideoPlayer = ({ movie, config, onClose }) => {
   const [selectedSubtitleTrack, setSelectedSubtitleTrack] = useState(-1); // Default to "Off"
   const [tracksLoaded, setTracksLoaded] = useState(false);
 
+  usePlaybackProgress(videoRef, movie.Id);
+
   // Fetch available audio and subtitle tracks
   useEffect(() => {
     const fetchMediaStreams = async () => {
       try {
         console.log('Fetching media streams for movie:', movie.Id);
-        const response = await fetch(
-          `${config.jellyfinUrl}/Items/${movie.Id}/PlaybackInfo?UserId=${config.userId}&api_key=${config.apiKey}`
-        );
-        const data = await response.json();
+        const data = await streamApi.getPlaybackInfo(movie.Id);
         
         console.log('PlaybackInfo response:', data);
         
@@ -104,7 +105,6 @@ const VideoPlayer = ({ movie, config, onClose }) => {
     
     // Build params object and filter out undefined values
     const paramsObj = {
-      'api_key': config.apiKey,
       'DeviceId': deviceId,
       'MediaSourceId': movie.Id,
       
@@ -145,14 +145,12 @@ const VideoPlayer = ({ movie, config, onClose }) => {
       paramsObj['SubtitleMethod'] = 'Encode';
     }
     
-    const params = new URLSearchParams(paramsObj);
-    
-    return `${config.jellyfinUrl}/videos/${movie.Id}/master.m3u8?${params.toString()}`;
+    return streamApi.getMediaUrl(movie.Id, 'master.m3u8', paramsObj);
   };
 
   const getSubtitlesUrl = () => {
     if (!movie.HasSubtitles) return null;
-    return `${config.jellyfinUrl}/Videos/${movie.Id}/Subtitles/0/Stream.vtt?api_key=${config.apiKey}`;
+    return streamApi.getMediaUrl(movie.Id, 'Subtitles/0/Stream.vtt');
   };
 
   const formatRuntime = (ticks) => {
@@ -518,7 +516,7 @@ const VideoPlayer = ({ movie, config, onClose }) => {
           ref={videoRef}
           controls
           className="video-element"
-          poster={`${config.jellyfinUrl}/Items/${movie.Id}/Images/Backdrop?api_key=${config.apiKey}`}
+          poster={streamApi.getImageUrl(movie.Id, 'Backdrop')}
           crossOrigin="anonymous"
           playsInline
         >
@@ -1,5 +1,6 @@
 import React, { createContext, useState, useContext, useEffect } from 'react';
 import { API_URL } from '../config';
+import { refreshAccessToken } from '../utils/api';
 
 const AuthContext = createContext(null);
 
@@ -17,24 +18,36 @@ export const AuthProvider = ({ children }) => {
       }
 
       try {
-        const response = await fetch(`${API_URL}/api/auth/verify`, {
+        let response = await fetch(`${API_URL}/api/auth/verify`, {
           headers: {
             'Authorization': `Bearer ${token}`
           }
         });
 
+        // The access token is short-lived; try the refresh token before giving up
+        if (response.status === 401 && await refreshAccessToken()) {
+          const refreshedToken = localStorage.getItem('token');
+          response = await fetch(`${API_URL}/api/auth/verify`, {
+            headers: {
+              'Authorization': `Bearer ${refreshedToken}`
+            }
+          });
+        }
+
         if (response.ok) {
           const userData = await response.json();
           setUser(userData);
         } else {
           // Invalid token, clear it
           localStorage.removeItem('token');
+          localStorage.removeItem('refreshToken');
           setToken(null);
           setUser(null);
         }
       } catch (error) {
         console.error('Error verifying token:', error);
         localStorage.removeItem('token');
+        localStorage.removeItem('refreshToken');
         setToken(null);
         setUser(null);
       } finally {
@@ -61,17 +74,73 @@ export const AuthProvider = ({ children }) => {
       }
 
       const data = await response.json();
-      localStorage.setItem('token', data.token);
-      setToken(data.token);
-      setUser(data.user);
+
+      // Accounts with two-factor enabled must complete a second step
+      if (data.mfaRequired) {
+        return { success: false, mfaRequired: true, mfaToken: data.mfaToken };
+      }
+
+      storeSession(data);
+      return { success: true };
+    } catch (error) {
+      return { success: false, error: error.message };
+    }
+  };
+
+  const verifyMfa = async (mfaToken, code) => {
+    try {
+      const response = await fetch(`${API_URL}/api/auth/mfa/verify`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ mfaToken, code })
+      });
+
+      if (!response.ok) {
+        const error = await response.text();
+        throw new Error(error || 'Verification failed');
+      }
+
+      storeSession(await response.json());
       return { success: true };
     } catch (error) {
       return { success: false, error: error.message };
     }
   };
 
-  const logout = () => {
+  const storeSession = (data) => {
+    localStorage.setItem('token', data.token);
+    localStorage.setItem('refreshToken', data.refreshToken);
+    setToken(data.token);
+    setUser(data.user);
+  };
+
+  const replaceAccessToken = (newToken, updatedUser) => {
+    localStorage.setItem('token', newToken);
+    setToken(newToken);
+    if (updatedUser) {
+      setUser(updatedUser);
+    }
+  };
+
+  const logout = async () => {
+    const currentToken = localStorage.getItem('token');
+    if (currentToken) {
+      try {
+        await fetch(`${API_URL}/api/auth/logout`, {
+          method: 'POST',
+          headers: {
+            'Authorization': `Bearer ${currentToken}`
+          }
+        });
+      } catch (error) {
+        console.error('Error logging out:', error);
+      }
+    }
+
     localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
     setToken(null);
     setUser(null);
   };
@@ -80,13 +149,20 @@ export const AuthProvider = ({ children }) => {
     setUser(updatedUser);
   };
 
+  // Admins hold every permission; other users get theirs resolved by the API
+  const hasPermission = (permission) =>
+    !!user && (user.isAdmin || (user.permissions || []).includes(permission));
+
   const value = {
     user,
     token,
     loading,
     login,
+    verifyMfa,
     logout,
     updateUser,
+    replaceAccessToken,
+    hasPermission,
     isAuthenticated: !!token && !!user,
     isAdmin: user?.isAdmin || false
   };
@@ -0,0 +1,112 @@
+import { useEffect } from 'react';
+import { playbackApi } from '../services/api';
+
+const TICKS_PER_SECOND = 10000000;
+const PROGRESS_INTERVAL_MS = 10000;
+
+// Reports playback of a Jellyfin item: resumes from the saved position once
+// the video is loaded, sends the position every few seconds and on pause,
+// and reports the stop when the video ends or the player closes
+const usePlaybackProgress = (videoRef, itemId) => {
+  useEffect(() => {
+    const video = videoRef.current;
+    if (!video || !itemId) return undefined;
+
+    let cancelled = false;
+    let stopped = false;
+    let resumeAt = null;
+    // Tracked separately because the player may clear the source before
+    // this effect is cleaned up
+    let position = 0;
+
+    const report = () => ({
+      itemId,
+      positionTicks: Math.floor(position * TICKS_PER_SECOND),
+      isPaused: video.paused,
+    });
+
+    const resume = () => {
+      if (resumeAt === null || video.readyState < 1) return;
+      // Leave the position alone if the user already skipped ahead
+      if (video.currentTime < 1) {
+        video.currentTime = resumeAt;
+      }
+      resumeAt = null;
+    };
+
+    const stop = () => {
+      if (stopped) return;
+      stopped = true;
+      playbackApi.stop(report()).catch((error) => {
+        console.error('Error reporting playback stop:', error);
+      });
+    };
+
+    const handleTimeUpdate = () => {
+      if (video.readyState > 0) {
+        position = video.currentTime;
+      }
+    };
+
+    const handlePause = () => {
+      if (stopped || video.ended) return;
+      playbackApi.progress(report()).catch((error) => {
+        console.error('Error reporting playback progress:', error);
+      });
+    };
+
+    const handlePlay = () => {
+      // Playing again after the end starts a new session
+      if (!stopped) return;
+      stopped = false;
+      playbackApi.start(report()).catch((error) => {
+        console.error('Error reporting playback start:', error);
+      });
+    };
+
+    const handleEnded = () => {
+      position = video.duration || position;
+      stop();
+    };
+
+    video.addEventListener('loadedmetadata', resume);
+    video.addEventListener('timeupdate', handleTimeUpdate);
+    video.addEventListener('pause', handlePause);
+    video.addEventListener('play', handlePlay);
+    video.addEventListener('ended', handleEnded);
+    window.addEventListener('pagehide', stop);
+
+    playbackApi
+      .start(report())
+      .then((state) => {
+        if (cancelled || !state || state.played || !state.positionTicks) return;
+        resumeAt = state.positionTicks / TICKS_PER_SECOND;
+        resume();
+      })
+      .catch((error) => {
+        console.error('Error reporting playback start:', error);
+      });
+
+    const interval = setInterval(() => {
+      if (!stopped && !video.paused) {
+        playbackApi.progress(report()).catch((error) => {
+          console.error('Error reporting playback progress:', error);
+        });
+      }
+    }, PROGRESS_INTERVAL_MS);
+
+    return () => {
+      cancelled = true;
+      clearInterval(interval);
+      video.removeEventListener('loadedmetadata', resume);
+      video.removeEventListener('timeupdate', handleTimeUpdate);
+      video.removeEventListener('pause', handlePause);
+      video.removeEventListener('play', handlePlay);
+      video.removeEventListener('ended', handleEnded);
+      window.removeEventListener('pagehide', stop);
+      stop();
+    };
+  }, [videoRef, itemId]);
+};
+
+export default usePlaybackProgress;
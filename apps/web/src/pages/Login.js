@@ -1,6 +1,7 @@
-import React, { useState } from 'react';
+import React, { useState, useEffect } from 'react';
 import { useAuth } from '../context/AuthContext';
 import { useNavigate } from 'react-router-dom';
+import { API_URL } from '../config';
 import '../styles/Login.css';
 
 function Login() {
@@ -8,20 +9,47 @@ function Login() {
   const [password, setPassword] = useState('');
   const [error, setError] = useState('');
   const [loading, setLoading] = useState(false);
-  const { login } = useAuth();
+  const [mfaToken, setMfaToken] = useState('');
+  const [code, setCode] = useState('');
+  const [ssoEnabled, setSsoEnabled] = useState(false);
+  const { login, verifyMfa } = useAuth();
   const navigate = useNavigate();
 
+  // Single sign-on redirects back here with tokens (or an error) in the URL fragment
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    if (params.get('token')) {
+      localStorage.setItem('token', params.get('token'));
+      localStorage.setItem('refreshToken', params.get('refreshToken'));
+      window.location.replace('/');
+      return;
+    }
+    if (params.get('error')) {
+      setError(params.get('error'));
+      window.history.replaceState(null, '', window.location.pathname);
+    }
+
+    fetch(`${API_URL}/api/auth/oidc/config`)
+      .then((response) => (response.ok ? response.json() : { enabled: false }))
+      .then((data) => setSsoEnabled(data.enabled))
+      .catch(() => setSsoEnabled(false));
+  }, []);
+
   const handleSubmit = async (e) => {
     e.preventDefault();
     setError('');
     setLoading(true);
 
-    const result = await login(username, password);
+    const result = mfaToken
+      ? await verifyMfa(mfaToken, code)
+      : await login(username, password);
     
     setLoading(false);
 
     if (result.success) {
       navigate('/');
+    } else if (result.mfaRequired) {
+      setMfaToken(result.mfaToken);
     } else {
       setError(result.error || 'Invalid username or password');
     }
@@ -42,6 +70,23 @@ function Login() {
         )}
 
         <form onSubmit={handleSubmit} className="login-form">
+          {mfaToken ? (
+          <div className="form-group">
+            <label htmlFor="code">Authentication code</label>
+            <input
+              type="text"
+              id="code"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              placeholder="6-digit code or recovery code"
+              autoComplete="one-time-code"
+              required
+              autoFocus
+              disabled={loading}
+            />
+          </div>
+          ) : (
+          <>
           <div className="form-group">
             <label htmlFor="username">Username</label>
             <input
@@ -68,14 +113,22 @@ function Login() {
               disabled={loading}
             />
           </div>
+          </>
+          )}
 
           <button 
             type="submit" 
             className="login-button"
             disabled={loading}
           >
-            {loading ? 'Signing in...' : 'Sign In'}
+            {loading ? 'Signing in...' : (mfaToken ? 'Verify' : 'Sign In')}
           </button>
+
+          {ssoEnabled && !mfaToken && (
+            <a className="login-button" href={`${API_URL}/api/auth/oidc/login`}>
+              Sign in with SSO
+            </a>
+          )}
         </form>
       </div>
     </div>
@@ -4,7 +4,7 @@ import { API_URL } from '../config';
 import '../styles/Profile.css';
 
 function Profile() {
-  const { user, token } = useAuth();
+  const { user, token, replaceAccessToken } = useAuth();
   const [currentPassword, setCurrentPassword] = useState('');
   const [newPassword, setNewPassword] = useState('');
   const [confirmPassword, setConfirmPassword] = useState('');
@@ -15,11 +15,6 @@ function Profile() {
     e.preventDefault();
     setMessage({ type: '', text: '' });
 
-    if (newPassword.length < 4) {
-      setMessage({ type: 'error', text: 'New password must be at least 4 characters' });
-      return;
-    }
-
     if (newPassword !== confirmPassword) {
       setMessage({ type: 'error', text: 'New passwords do not match' });
       return;
@@ -41,6 +36,11 @@ function Profile() {
       });
 
       if (response.ok) {
+        // The server issues a new access token without the password change restriction
+        const data = await response.json();
+        if (data.token) {
+          replaceAccessToken(data.token, data.user);
+        }
         setMessage({ type: 'success', text: 'Password changed successfully' });
         setCurrentPassword('');
         setNewPassword('');
@@ -94,6 +94,12 @@ function Profile() {
 
         <div className="profile-section">
           <h2>Change Password</h2>
+
+          {user?.mustChangePassword && (
+            <div className="message message-error">
+              You must change your password before you can continue.
+            </div>
+          )}
           
           {message.text && (
             <div className={`message message-${message.type}`}>
@@ -122,7 +128,7 @@ function Profile() {
                 id="newPassword"
                 value={newPassword}
                 onChange={(e) => setNewPassword(e.target.value)}
-                placeholder="Enter new password (min 6 characters)"
+                placeholder="Enter new password (min 8 characters)"
                 required
                 disabled={loading}
               />
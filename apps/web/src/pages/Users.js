@@ -243,7 +243,7 @@ function Users() {
                   onChange={(e) => setFormData({ ...formData, password: e.target.value })}
                   placeholder={modalMode === 'create' ? 'Enter password' : 'Enter new password'}
                   required={modalMode === 'create'}
-                  minLength="4"
+                  minLength="8"
                 />
               </div>
 
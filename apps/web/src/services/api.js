@@ -1,4 +1,5 @@
 import { API_URL } from '../config';
+import { refreshAccessToken, redirectToLogin } from '../utils/api';
 
 const TMDB_IMAGE_BASE_URL = 'https://image.tmdb.org/t/p';
 
@@ -17,7 +18,7 @@ const getAuthHeaders = () => {
 };
 
 // Helper function for authenticated fetch
-const authenticatedFetch = async (url, options = {}) => {
+const authenticatedFetch = async (url, options = {}, retry = true) => {
   const headers = getAuthHeaders();
   
   const response = await fetch(url, {
@@ -28,16 +29,44 @@ const authenticatedFetch = async (url, options = {}) => {
     },
   });
   
-  // If unauthorized, redirect to login
+  // If unauthorized, try refreshing the access token once before giving up
   if (response.status === 401) {
-    localStorage.removeItem('token');
-    window.location.href = '/login';
+    if (retry && await refreshAccessToken()) {
+      return authenticatedFetch(url, options, false);
+    }
+    redirectToLogin();
     throw new Error('Unauthorized');
   }
   
   return response;
 };
 
+// Jellyfin streaming proxy: the API key stays on the server
+export const streamApi = {
+  // Artwork is served without authentication so it works in <img> tags
+  getImageUrl: (itemId, type = 'Primary') => `${API_URL}/api/stream/${itemId}/images/${type}`,
+
+  // URLs loaded by the media element carry the access token in the query string;
+  // playlists returned by the proxy carry their own stream tokens
+  getMediaUrl: (itemId, path, params = {}) => {
+    const query = new URLSearchParams(params);
+    const token = localStorage.getItem('token');
+    if (token) {
+      query.set('access_token', token);
+    }
+    return `${API_URL}/api/stream/${itemId}/${path}?${query.toString()}`;
+  },
+
+  // Resolves a URL returned by the proxy (e.g. a subtitle DeliveryUrl)
+  resolveUrl: (path) => (path ? `${API_URL}${path}` : null),
+
+  getPlaybackInfo: async (itemId) => {
+    const response = await authenticatedFetch(`${API_URL}/api/stream/${itemId}/playbackinfo`);
+    if (!response.ok) throw new Error('Failed to fetch playback info');
+    return await response.json();
+  },
+};
+
 // TMDB API Functions
 export const tmdbApi = {
   getTrending: async (type = 'movie', timeWindow = 'week', page = 1) => {
@@ -128,20 +157,77 @@ export const tmdbApi = {
   },
 };
 
+// Fetches every page of a Jellyfin library listing by following NextCursor.
+// params are the sort and filter options of /api/jellyfin/movies and /series.
+const fetchAllLibraryPages = async (endpoint, params = {}) => {
+  const items = [];
+  let cursor = '';
+  do {
+    const queryParams = new URLSearchParams({ ...params, limit: 500 });
+    if (cursor) queryParams.set('cursor', cursor);
+    const response = await authenticatedFetch(`${API_URL}${endpoint}?${queryParams.toString()}`);
+    if (!response.ok) throw new Error('Failed to fetch library');
+    const data = await response.json();
+    items.push(...(data.Items || []));
+    cursor = data.NextCursor || '';
+  } while (cursor);
+  return items;
+};
+
 // Jellyfin API Functions
 export const jellyfinApi = {
-  getMovies: async () => {
+  getMovies: async (params = {}) => {
     try {
-      const response = await authenticatedFetch(`${API_URL}/api/jellyfin/movies`);
+      return await fetchAllLibraryPages('/api/jellyfin/movies', params);
+    } catch (error) {
+      console.error('Error fetching Jellyfin movies:', error);
+      throw error;
+    }
+  },
+
+  // Returns one page: { Items, TotalRecordCount, Page, Limit, NextCursor }
+  getMoviesPage: async (params = {}) => {
+    try {
+      const queryParams = new URLSearchParams(params);
+      const response = await authenticatedFetch(`${API_URL}/api/jellyfin/movies?${queryParams.toString()}`);
       if (!response.ok) throw new Error('Failed to fetch movies');
-      const data = await response.json();
-      return data.Items || [];
+      return await response.json();
     } catch (error) {
       console.error('Error fetching Jellyfin movies:', error);
       throw error;
     }
   },
 
+  // Libraries the movie and TV pages show; all=true lists every library
+  // (libraries.manage)
+  getLibraries: async (all = false) => {
+    try {
+      const response = await authenticatedFetch(
+        `${API_URL}/api/jellyfin/libraries${all ? '?all=true' : ''}`
+      );
+      if (!response.ok) throw new Error('Failed to fetch libraries');
+      return await response.json();
+    } catch (error) {
+      console.error('Error fetching Jellyfin libraries:', error);
+      throw error;
+    }
+  },
+
+  updateLibraries: async (movieLibraries, tvLibraries) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/jellyfin/libraries`, {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ movieLibraries, tvLibraries }),
+      });
+      if (!response.ok) throw new Error(await response.text() || 'Failed to update libraries');
+      return await response.json();
+    } catch (error) {
+      console.error('Error updating Jellyfin libraries:', error);
+      throw error;
+    }
+  },
+
   getConfig: async () => {
     try {
       const response = await authenticatedFetch(`${API_URL}/api/config`);
@@ -169,23 +255,59 @@ export const jellyfinApi = {
 
   getImageUrl: (movieId, config) => {
     if (!config || !movieId) return null;
-    return `${config.jellyfinUrl}/Items/${movieId}/Images/Primary?api_key=${config.apiKey}`;
+    return streamApi.getImageUrl(movieId, 'Primary');
   },
 
   getBackdropUrl: (movieId, config) => {
     if (!config || !movieId) return null;
-    return `${config.jellyfinUrl}/Items/${movieId}/Images/Backdrop?api_key=${config.apiKey}`;
+    return streamApi.getImageUrl(movieId, 'Backdrop');
   },
 };
 
 // Jellyfin TV Shows API Functions
 export const jellyfinTVApi = {
-  getSeries: async () => {
+  getSeries: async (params = {}) => {
+    try {
+      return await fetchAllLibraryPages('/api/jellyfin/series', params);
+    } catch (error) {
+      console.error('Error fetching Jellyfin TV shows:', error);
+      throw error;
+    }
+  },
+
+  getSeasons: async (seriesId) => {
+    const response = await authenticatedFetch(`${API_URL}/api/jellyfin/series/${seriesId}/seasons`);
+    if (!response.ok) throw new Error('Failed to fetch seasons');
+    return await response.json();
+  },
+
+  getEpisodes: async (seriesId, seasonId) => {
+    const response = await authenticatedFetch(
+      `${API_URL}/api/jellyfin/series/${seriesId}/seasons/${seasonId}/episodes`
+    );
+    if (!response.ok) throw new Error('Failed to fetch episodes');
+    return await response.json();
+  },
+
+  // Next up for the user, or the episode after afterEpisodeId; null when
+  // there is none
+  getNextEpisode: async (seriesId, afterEpisodeId = '') => {
+    const query = afterEpisodeId ? `?after=${afterEpisodeId}` : '';
+    const response = await authenticatedFetch(
+      `${API_URL}/api/jellyfin/series/${seriesId}/next-episode${query}`
+    );
+    if (response.status === 404) return null;
+    if (!response.ok) throw new Error('Failed to fetch next episode');
+    return await response.json();
+  },
+
+  // Returns one page: { Items, TotalRecordCount, Page, Limit, NextCursor }
+  getSeriesPage: async (params = {}) => {
     try {
-      const response = await authenticatedFetch(`${API_URL}/api/jellyfin/series`);
+      const queryParams = new URLSearchParams(params);
+      const response = await authenticatedFetch(`${API_URL}/api/jellyfin/series?${queryParams.toString()}`);
       if (!response.ok) throw new Error('Failed to fetch TV shows');
-      const data = await response.json();
-      return data.Items || [];
+      return await response.json();
     } catch (error) {
       console.error('Error fetching Jellyfin TV shows:', error);
       throw error;
@@ -194,12 +316,12 @@ export const jellyfinTVApi = {
 
   getImageUrl: (seriesId, config) => {
     if (!config || !seriesId) return null;
-    return `${config.jellyfinUrl}/Items/${seriesId}/Images/Primary?api_key=${config.apiKey}`;
+    return streamApi.getImageUrl(seriesId, 'Primary');
   },
 
   getBackdropUrl: (seriesId, config) => {
     if (!config || !seriesId) return null;
-    return `${config.jellyfinUrl}/Items/${seriesId}/Images/Backdrop?api_key=${config.apiKey}`;
+    return streamApi.getImageUrl(seriesId, 'Backdrop');
   },
 };
 
@@ -455,3 +577,301 @@ export const sonarrApi = {
     return { status: 'unmonitored', label: 'Non surveillé', monitored: false };
   },
 };
+
+// Media request API Functions
+export const requestsApi = {
+  create: async (requestData) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/requests`, {
+        method: 'POST',
+        body: JSON.stringify(requestData),
+      });
+      if (!response.ok) {
+        const errorText = await response.text();
+        throw new Error(errorText || 'Failed to create request');
+      }
+      return await response.json();
+    } catch (error) {
+      console.error('Error creating request:', error);
+      throw error;
+    }
+  },
+
+  list: async (params = {}) => {
+    try {
+      const query = new URLSearchParams(params).toString();
+      const response = await authenticatedFetch(`${API_URL}/api/requests${query ? `?${query}` : ''}`);
+      if (!response.ok) throw new Error('Failed to fetch requests');
+      return await response.json();
+    } catch (error) {
+      console.error('Error fetching requests:', error);
+      throw error;
+    }
+  },
+
+  approve: async (id, options = {}) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/requests/${id}/approve`, {
+        method: 'POST',
+        body: JSON.stringify(options),
+      });
+      if (!response.ok && response.status !== 502) {
+        const errorText = await response.text();
+        throw new Error(errorText || 'Failed to approve request');
+      }
+      return await response.json();
+    } catch (error) {
+      console.error('Error approving request:', error);
+      throw error;
+    }
+  },
+
+  decline: async (id, reason) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/requests/${id}/decline`, {
+        method: 'POST',
+        body: JSON.stringify({ reason }),
+      });
+      if (!response.ok) {
+        const errorText = await response.text();
+        throw new Error(errorText || 'Failed to decline request');
+      }
+      return await response.json();
+    } catch (error) {
+      console.error('Error declining request:', error);
+      throw error;
+    }
+  },
+
+  cancel: async (id) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/requests/${id}`, {
+        method: 'DELETE',
+      });
+      if (!response.ok) {
+        const errorText = await response.text();
+        throw new Error(errorText || 'Failed to cancel request');
+      }
+      return await response.json();
+    } catch (error) {
+      console.error('Error cancelling request:', error);
+      throw error;
+    }
+  },
+};
+
+// Availability API Functions
+export const availabilityApi = {
+  // Resolves Jellyfin, Radarr/Sonarr, queue and request status in one call
+  get: async (tmdbId, type) => {
+    try {
+      const query = new URLSearchParams({ tmdbId, type }).toString();
+      const response = await authenticatedFetch(`${API_URL}/api/availability?${query}`);
+      if (!response.ok) throw new Error('Failed to fetch availability');
+      return await response.json();
+    } catch (error) {
+      console.error('Error fetching availability:', error);
+      throw error;
+    }
+  },
+};
+
+// Watchlist API Functions
+export const watchlistApi = {
+  // Returns { items, autoRequest }; items carry inLibrary, jellyfinId and requested
+  get: async () => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/watchlist`);
+      if (!response.ok) throw new Error('Failed to fetch watchlist');
+      return await response.json();
+    } catch (error) {
+      console.error('Error fetching watchlist:', error);
+      throw error;
+    }
+  },
+
+  add: async (item) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/watchlist`, {
+        method: 'POST',
+        body: JSON.stringify(item),
+      });
+      if (!response.ok) {
+        const errorText = await response.text();
+        const error = new Error(errorText || 'Failed to add to watchlist');
+        error.status = response.status;
+        throw error;
+      }
+      return await response.json();
+    } catch (error) {
+      console.error('Error adding to watchlist:', error);
+      throw error;
+    }
+  },
+
+  remove: async (mediaType, tmdbId) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/watchlist/${mediaType}/${tmdbId}`, {
+        method: 'DELETE',
+      });
+      if (!response.ok) {
+        const errorText = await response.text();
+        throw new Error(errorText || 'Failed to remove from watchlist');
+      }
+      return await response.json();
+    } catch (error) {
+      console.error('Error removing from watchlist:', error);
+      throw error;
+    }
+  },
+
+  // items: [{ mediaType, tmdbId }] in the new order
+  reorder: async (items) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/watchlist/order`, {
+        method: 'PUT',
+        body: JSON.stringify({ items }),
+      });
+      if (!response.ok) {
+        const errorText = await response.text();
+        throw new Error(errorText || 'Failed to reorder watchlist');
+      }
+      return await response.json();
+    } catch (error) {
+      console.error('Error reordering watchlist:', error);
+      throw error;
+    }
+  },
+
+  updateSettings: async (settings) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/watchlist/settings`, {
+        method: 'PUT',
+        body: JSON.stringify(settings),
+      });
+      if (!response.ok) {
+        const errorText = await response.text();
+        throw new Error(errorText || 'Failed to update watchlist settings');
+      }
+      return await response.json();
+    } catch (error) {
+      console.error('Error updating watchlist settings:', error);
+      throw error;
+    }
+  },
+};
+
+// Playback reporting and the watch feeds
+export const playbackApi = {
+  // Returns { itemId, positionTicks, played } to resume from
+  start: async (report) => {
+    const response = await authenticatedFetch(`${API_URL}/api/playback/start`, {
+      method: 'POST',
+      body: JSON.stringify(report),
+    });
+    if (!response.ok) throw new Error('Failed to report playback start');
+    return await response.json();
+  },
+
+  progress: async (report) => {
+    const response = await authenticatedFetch(`${API_URL}/api/playback/progress`, {
+      method: 'POST',
+      body: JSON.stringify(report),
+    });
+    if (!response.ok) throw new Error('Failed to report playback progress');
+  },
+
+  // keepalive lets the report finish when the page is closing
+  stop: async (report) => {
+    const response = await authenticatedFetch(`${API_URL}/api/playback/stop`, {
+      method: 'POST',
+      body: JSON.stringify(report),
+      keepalive: true,
+    });
+    if (!response.ok) throw new Error('Failed to report playback stop');
+    return await response.json();
+  },
+
+  getContinueWatching: async (limit = 20) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/me/continue-watching?limit=${limit}`);
+      if (!response.ok) throw new Error('Failed to fetch continue watching');
+      return await response.json();
+    } catch (error) {
+      console.error('Error fetching continue watching:', error);
+      throw error;
+    }
+  },
+
+  getNextUp: async (limit = 20) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/me/next-up?limit=${limit}`);
+      if (!response.ok) throw new Error('Failed to fetch next up');
+      return await response.json();
+    } catch (error) {
+      console.error('Error fetching next up:', error);
+      throw error;
+    }
+  },
+};
+
+// Notification inbox API Functions
+export const notificationsApi = {
+  list: async (params = {}) => {
+    try {
+      const query = new URLSearchParams(params).toString();
+      const response = await authenticatedFetch(`${API_URL}/api/notifications${query ? `?${query}` : ''}`);
+      if (!response.ok) throw new Error('Failed to fetch notifications');
+      return await response.json();
+    } catch (error) {
+      console.error('Error fetching notifications:', error);
+      throw error;
+    }
+  },
+
+  markRead: async (id) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/notifications/${id}/read`, {
+        method: 'POST',
+      });
+      if (!response.ok) throw new Error('Failed to mark notification as read');
+      return await response.json();
+    } catch (error) {
+      console.error('Error marking notification as read:', error);
+      throw error;
+    }
+  },
+
+  markAllRead: async () => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/notifications/read-all`, {
+        method: 'POST',
+      });
+      if (!response.ok) throw new Error('Failed to mark notifications as read');
+      return await response.json();
+    } catch (error) {
+      console.error('Error marking notifications as read:', error);
+      throw error;
+    }
+  },
+
+  remove: async (id) => {
+    try {
+      const response = await authenticatedFetch(`${API_URL}/api/notifications/${id}`, {
+        method: 'DELETE',
+      });
+      if (!response.ok) throw new Error('Failed to delete notification');
+      return await response.json();
+    } catch (error) {
+      console.error('Error deleting notification:', error);
+      throw error;
+    }
+  },
+
+  // Opens the Server-Sent Events stream; EventSource cannot send headers so
+  // the access token goes in the query string
+  stream: () => {
+    const token = localStorage.getItem('token');
+    return new EventSource(`${API_URL}/api/notifications/stream?access_token=${encodeURIComponent(token || '')}`);
+  },
+};
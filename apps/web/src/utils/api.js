@@ -1,13 +1,58 @@
 // API utility for making authenticated requests
 import { API_URL } from '../config';
 
+let refreshPromise = null;
+
+/**
+ * Exchange the stored refresh token for a new token pair.
+ * Concurrent callers share a single in-flight refresh.
+ * @returns {Promise<boolean>} - Whether a new access token was obtained
+ */
+export const refreshAccessToken = () => {
+  if (!refreshPromise) {
+    refreshPromise = (async () => {
+      const refreshToken = localStorage.getItem('refreshToken');
+      if (!refreshToken) return false;
+
+      try {
+        const response = await fetch(`${API_URL}/api/auth/refresh`, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ refreshToken }),
+        });
+        if (!response.ok) return false;
+
+        const data = await response.json();
+        localStorage.setItem('token', data.token);
+        localStorage.setItem('refreshToken', data.refreshToken);
+        return true;
+      } catch (error) {
+        console.error('Error refreshing token:', error);
+        return false;
+      } finally {
+        refreshPromise = null;
+      }
+    })();
+  }
+  return refreshPromise;
+};
+
+/**
+ * Clear stored tokens and send the user back to the login page
+ */
+export const redirectToLogin = () => {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refreshToken');
+  window.location.href = '/login';
+};
+
 /**
  * Make an authenticated API request
  * @param {string} endpoint - API endpoint (e.g., '/api/jellyfin/movies')
  * @param {object} options - Fetch options (method, body, etc.)
  * @returns {Promise} - Response promise
  */
-export const apiRequest = async (endpoint, options = {}) => {
+export const apiRequest = async (endpoint, options = {}, retry = true) => {
   const token = localStorage.getItem('token');
 
   const headers = {
@@ -26,10 +71,12 @@ export const apiRequest = async (endpoint, options = {}) => {
 
   const response = await fetch(`${API_URL}${endpoint}`, config);
 
-  // If unauthorized, redirect to login
+  // If unauthorized, try refreshing the access token once before giving up
   if (response.status === 401) {
-    localStorage.removeItem('token');
-    window.location.href = '/login';
+    if (retry && await refreshAccessToken()) {
+      return apiRequest(endpoint, options, false);
+    }
+    redirectToLogin();
     throw new Error('Unauthorized');
   }
 